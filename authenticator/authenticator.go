@@ -0,0 +1,31 @@
+package authenticator
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotManaged is returned when the user is not managed by the
+// authenticator, the local password is checked instead
+var ErrNotManaged = errors.New("user is not managed by this authenticator")
+
+// User contains the profile of a user from an external directory
+type User struct {
+	Name  string
+	Email string
+}
+
+// Authenticator checks passwords against an external user directory
+type Authenticator interface {
+	// Name identifies the authenticator, it is stored as the auth source of
+	// provisioned users
+	Name() string
+
+	// Authenticate checks the password for the username and returns
+	// ErrNotManaged if the username is not in the directory
+	Authenticate(ctx context.Context, username, password string) (User, error)
+
+	// AutoProvision reports if users missing from the database should be
+	// added on their first login
+	AutoProvision() bool
+}
@@ -0,0 +1,141 @@
+package authenticator
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/1f349/tulip/password"
+	"github.com/go-ldap/ldap/v3"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const ldapTimeout = 10 * time.Second
+
+// LdapConfig configures the LDAP authenticator, users are found with a search
+// and then the password is checked by binding as the user
+type LdapConfig struct {
+	Url      string `json:"url"`
+	StartTLS bool   `json:"start_tls"`
+	// BindDN and BindPassword are used for the search, an anonymous bind is
+	// used if BindDN is empty
+	BindDN       string `json:"bind_dn"`
+	BindPassword string `json:"bind_password"`
+	BaseDN       string `json:"base_dn"`
+	// UserFilter finds the user entry, %s is replaced with the escaped username
+	UserFilter    string `json:"user_filter"`
+	NameAttribute string `json:"name_attribute"`
+	MailAttribute string `json:"mail_attribute"`
+	Provision     bool   `json:"auto_provision"`
+}
+
+// Ldap implements Authenticator using an LDAP directory
+type Ldap struct {
+	conf LdapConfig
+}
+
+var _ Authenticator = &Ldap{}
+
+// NewLdap creates an LDAP authenticator, empty attributes use the defaults
+func NewLdap(conf LdapConfig) *Ldap {
+	if conf.UserFilter == "" {
+		conf.UserFilter = "(uid=%s)"
+	}
+	if conf.NameAttribute == "" {
+		conf.NameAttribute = "cn"
+	}
+	if conf.MailAttribute == "" {
+		conf.MailAttribute = "mail"
+	}
+	return &Ldap{conf: conf}
+}
+
+func (l *Ldap) Name() string { return "ldap" }
+
+func (l *Ldap) AutoProvision() bool { return l.conf.Provision }
+
+// filter returns the search filter for the username
+func (l *Ldap) filter(username string) string {
+	return strings.ReplaceAll(l.conf.UserFilter, "%s", ldap.EscapeFilter(username))
+}
+
+func (l *Ldap) dial(ctx context.Context) (*ldap.Conn, error) {
+	timeout := ldapTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	conn, err := ldap.DialURL(l.conf.Url, ldap.DialWithDialer(&net.Dialer{Timeout: timeout}))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(timeout)
+	if l.conf.StartTLS {
+		u, err := url.Parse(l.conf.Url)
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		if err := conn.StartTLS(&tls.Config{ServerName: u.Hostname()}); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (l *Ldap) Authenticate(ctx context.Context, username, pw string) (User, error) {
+	// an empty password would be an unauthenticated bind which always succeeds
+	if username == "" || pw == "" {
+		return User{}, ErrNotManaged
+	}
+
+	conn, err := l.dial(ctx)
+	if err != nil {
+		return User{}, fmt.Errorf("ldap: failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	if l.conf.BindDN == "" {
+		err = conn.UnauthenticatedBind("")
+	} else {
+		err = conn.Bind(l.conf.BindDN, l.conf.BindPassword)
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("ldap: failed to bind for search: %w", err)
+	}
+
+	res, err := conn.SearchWithPaging(ldap.NewSearchRequest(
+		l.conf.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		l.filter(username),
+		[]string{l.conf.NameAttribute, l.conf.MailAttribute},
+		nil,
+	), 2)
+	if err != nil {
+		return User{}, fmt.Errorf("ldap: failed to search: %w", err)
+	}
+	switch len(res.Entries) {
+	case 0:
+		return User{}, ErrNotManaged
+	case 1:
+	default:
+		return User{}, errors.New("ldap: username matches multiple entries")
+	}
+	entry := res.Entries[0]
+
+	err = conn.Bind(entry.DN, pw)
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		return User{}, password.ErrMismatchedHashAndPassword
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("ldap: failed to bind as user: %w", err)
+	}
+
+	return User{
+		Name:  entry.GetAttributeValue(l.conf.NameAttribute),
+		Email: entry.GetAttributeValue(l.conf.MailAttribute),
+	}, nil
+}
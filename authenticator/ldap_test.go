@@ -0,0 +1,30 @@
+package authenticator
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewLdap(t *testing.T) {
+	l := NewLdap(LdapConfig{Url: "ldap://localhost"})
+	assert.Equal(t, "(uid=%s)", l.conf.UserFilter)
+	assert.Equal(t, "cn", l.conf.NameAttribute)
+	assert.Equal(t, "mail", l.conf.MailAttribute)
+	assert.False(t, l.AutoProvision())
+}
+
+func TestLdap_Filter(t *testing.T) {
+	l := NewLdap(LdapConfig{UserFilter: "(&(objectClass=person)(uid=%s))"})
+	assert.Equal(t, "(&(objectClass=person)(uid=alice))", l.filter("alice"))
+	assert.Equal(t, `(&(objectClass=person)(uid=\2a\29\28uid=\2a))`, l.filter("*)(uid=*"))
+}
+
+func TestLdap_AuthenticateEmpty(t *testing.T) {
+	// an empty password must not reach the directory as an unauthenticated bind
+	l := NewLdap(LdapConfig{Url: "ldap://127.0.0.1:1"})
+	_, err := l.Authenticate(context.Background(), "alice", "")
+	assert.ErrorIs(t, err, ErrNotManaged)
+	_, err = l.Authenticate(context.Background(), "", "pw")
+	assert.ErrorIs(t, err, ErrNotManaged)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -10,9 +11,12 @@ import (
 	"github.com/1f349/tulip"
 	"github.com/1f349/tulip/database"
 	"github.com/1f349/tulip/database/types"
+	"github.com/1f349/tulip/ldapserver"
 	"github.com/1f349/tulip/logger"
 	"github.com/1f349/tulip/mail/templates"
 	"github.com/1f349/tulip/pages"
+	"github.com/1f349/tulip/password"
+	"github.com/1f349/tulip/scope"
 	"github.com/1f349/tulip/server"
 	"github.com/1f349/violet/utils"
 	"github.com/google/subcommands"
@@ -79,6 +83,25 @@ func normalLoad(startUp server.Conf, wd string) {
 		logger.Logger.Fatal("Failed to open signing key file:", err)
 	}
 
+	if startUp.PairwiseSalt == "" {
+		startUp.PairwiseSalt, err = readOrCreateSalt(filepath.Join(wd, "tulip.salt"))
+		if err != nil {
+			logger.Logger.Fatal("Failed to open pairwise salt file:", err)
+		}
+	}
+
+	hasher, err := startUp.PasswordHash.Hasher()
+	if err != nil {
+		logger.Logger.Fatal("Invalid password hash config", "err", err)
+	}
+	password.SetDefault(hasher)
+
+	for _, i := range startUp.Scopes {
+		if err := scope.AddScope(i.Name, i.Description); err != nil {
+			logger.Logger.Fatal("Invalid custom scope", "scope", i.Name, "err", err)
+		}
+	}
+
 	db, err := tulip.InitDB(filepath.Join(wd, "tulip.db.sqlite"))
 	if err != nil {
 		logger.Logger.Fatal("Failed to open database:", err)
@@ -100,12 +123,48 @@ func normalLoad(startUp server.Conf, wd string) {
 	logger.Logger.Info("Starting server", "addr", srv.Addr)
 	go utils.RunBackgroundHttp(logger.Logger, srv)
 
+	var ldapSrv *ldapserver.Server
+	if startUp.Ldap.Listen != "" {
+		ldapSrv, err = server.NewLdapServer(startUp, db)
+		if err != nil {
+			logger.Logger.Fatal("Invalid LDAP config", "err", err)
+		}
+		logger.Logger.Info("Starting LDAP server", "addr", startUp.Ldap.Listen)
+		go func() {
+			if err := ldapSrv.ListenAndServe(startUp.Ldap.Listen); err != nil {
+				logger.Logger.Fatal("LDAP server failed", "err", err)
+			}
+		}()
+	}
+
 	exit_reload.ExitReload("Tulip", func() {}, func() {
 		// stop http server
 		_ = srv.Close()
+		if ldapSrv != nil {
+			_ = ldapSrv.Close()
+		}
 	})
 }
 
+// readOrCreateSalt returns the salt stored in the file, or generates a new salt
+// and saves it to the file if the file does not exist
+func readOrCreateSalt(file string) (string, error) {
+	b, err := os.ReadFile(file)
+	if err == nil {
+		return string(b), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	salt := make([]byte, 32)
+	if _, err = rand.Read(salt); err != nil {
+		return "", err
+	}
+	s := hex.EncodeToString(salt)
+	return s, os.WriteFile(file, []byte(s), 0600)
+}
+
 func checkDbHasUser(db *database.Queries) error {
 	value, err := db.HasUser(context.Background())
 	if err != nil {
@@ -113,19 +172,25 @@ func checkDbHasUser(db *database.Queries) error {
 	}
 
 	if !value {
-		_, err := db.AddUser(context.Background(), database.AddUserParams{
+		// the initial password is random and only shown in the log
+		adminPw, err := password.GenerateApiSecret(24)
+		if err != nil {
+			return fmt.Errorf("failed to generate admin password: %w", err)
+		}
+		_, err = db.AddUser(context.Background(), database.AddUserParams{
 			Name:          "Admin",
 			Username:      "admin",
-			Password:      "admin",
+			Password:      adminPw,
 			Email:         "admin@localhost",
 			EmailVerified: false,
 			Role:          types.RoleAdmin,
 			UpdatedAt:     time.Now(),
-			Active:        false,
+			Active:        true,
 		})
 		if err != nil {
 			return fmt.Errorf("failed to add user: %w", err)
 		}
+		logger.Logger.Warn("Created initial admin user, change this password after logging in", "username", "admin", "password", adminPw)
 		// continue normal operation now
 	}
 	return nil
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/1f349/tulip/authenticator"
+	"github.com/1f349/tulip/database/types"
+	"github.com/1f349/tulip/password"
+	"time"
+)
+
+// ErrExternalAccount is returned when changing or checking the local password
+// of a user managed by an external directory
+var ErrExternalAccount = errors.New("password is managed by an external directory")
+
+// ErrDirectoryUnavailable is returned when the directory managing a user can't
+// be reached or is no longer configured
+var ErrDirectoryUnavailable = errors.New("external directory is unavailable")
+
+// findAuthenticator returns the authenticator matching the auth source of a
+// user or nil if it is no longer configured
+func findAuthenticator(authenticators []authenticator.Authenticator, source string) authenticator.Authenticator {
+	for _, a := range authenticators {
+		if a.Name() == source {
+			return a
+		}
+	}
+	return nil
+}
+
+// checkExternalLogin checks the password of a user managed by a directory, the
+// local password is never used so ErrDirectoryUnavailable is returned if the
+// directory can't be reached
+func (q *Queries) checkExternalLogin(ctx context.Context, authenticators []authenticator.Authenticator, login checkLoginRow, un, pw string) (checkLoginRow, error) {
+	a := findAuthenticator(authenticators, login.AuthSource)
+	if a == nil {
+		return checkLoginRow{}, ErrDirectoryUnavailable
+	}
+	extUser, err := a.Authenticate(ctx, un, pw)
+	switch {
+	case errors.Is(err, authenticator.ErrNotManaged):
+		// the user has been removed from the directory
+		return checkLoginRow{}, password.ErrMismatchedHashAndPassword
+	case errors.Is(err, password.ErrMismatchedHashAndPassword):
+		return checkLoginRow{}, err
+	case err != nil:
+		return checkLoginRow{}, fmt.Errorf("%w: %w", ErrDirectoryUnavailable, err)
+	}
+	return q.syncExternalLogin(ctx, login, extUser)
+}
+
+// provisionExternalLogin adds a user for an unknown username found in a
+// directory which provisions users, sql.ErrNoRows is returned if no directory
+// accepts the login
+func (q *Queries) provisionExternalLogin(ctx context.Context, authenticators []authenticator.Authenticator, un, pw string) (checkLoginRow, error) {
+	for _, a := range authenticators {
+		if !a.AutoProvision() {
+			continue
+		}
+		extUser, err := a.Authenticate(ctx, un, pw)
+		if errors.Is(err, password.ErrMismatchedHashAndPassword) {
+			return checkLoginRow{}, err
+		}
+		if err != nil {
+			// the username is not in this directory or it is unavailable
+			continue
+		}
+
+		// the local password is random and can't be changed
+		randomPw, err := password.GenerateApiSecret(32)
+		if err != nil {
+			return checkLoginRow{}, err
+		}
+		name := extUser.Name
+		if name == "" {
+			name = un
+		}
+		email, err := q.directoryEmail(ctx, extUser.Email, "")
+		if err != nil {
+			return checkLoginRow{}, err
+		}
+		_, err = q.AddUser(ctx, AddUserParams{
+			Name:          name,
+			Username:      un,
+			Password:      randomPw,
+			Email:         email,
+			EmailVerified: email != "",
+			Role:          types.RoleMember,
+			UpdatedAt:     time.Now(),
+			Active:        true,
+			AuthSource:    a.Name(),
+		})
+		if err != nil {
+			return checkLoginRow{}, err
+		}
+		return q.checkLogin(ctx, un)
+	}
+	return checkLoginRow{}, sql.ErrNoRows
+}
+
+// directoryEmail returns the email from the directory or an empty string if
+// another user already has the address
+func (q *Queries) directoryEmail(ctx context.Context, email, subject string) (string, error) {
+	if email == "" {
+		return "", nil
+	}
+	inUse, err := q.emailInUse(ctx, emailInUseParams{Email: email, Subject: subject})
+	if err != nil || inUse {
+		return "", err
+	}
+	return email, nil
+}
+
+// syncExternalLogin updates the profile of a user from the directory
+func (q *Queries) syncExternalLogin(ctx context.Context, login checkLoginRow, extUser authenticator.User) (checkLoginRow, error) {
+	email, err := q.directoryEmail(ctx, extUser.Email, login.Subject)
+	if err != nil {
+		return checkLoginRow{}, err
+	}
+	if (extUser.Name == "" || extUser.Name == login.Name) && (email == "" || (email == login.Email && login.EmailVerified)) {
+		return login, nil
+	}
+
+	p := syncUserProfileParams{
+		Name:          login.Name,
+		Email:         login.Email,
+		EmailVerified: login.EmailVerified,
+		UpdatedAt:     time.Now(),
+		Subject:       login.Subject,
+	}
+	if extUser.Name != "" {
+		p.Name = extUser.Name
+	}
+	if email != "" {
+		// the directory is trusted to contain the correct email address
+		p.Email = email
+		p.EmailVerified = true
+	}
+	if err := q.syncUserProfile(ctx, p); err != nil {
+		return checkLoginRow{}, err
+	}
+	login.Name = p.Name
+	login.Email = p.Email
+	login.EmailVerified = p.EmailVerified
+	return login, nil
+}
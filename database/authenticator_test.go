@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/1f349/tulip/authenticator"
+	"github.com/1f349/tulip/database/types"
+	"github.com/1f349/tulip/password"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+var errDirectoryDown = errors.New("directory is unavailable")
+
+// fakeAuthenticator accepts the passwords in users and returns err for every
+// login if it is set
+type fakeAuthenticator struct {
+	users     map[string]string
+	profiles  map[string]authenticator.User
+	provision bool
+	err       error
+	calls     int
+}
+
+func (f *fakeAuthenticator) Name() string { return "fake" }
+
+func (f *fakeAuthenticator) AutoProvision() bool { return f.provision }
+
+func (f *fakeAuthenticator) Authenticate(_ context.Context, username, pw string) (authenticator.User, error) {
+	f.calls++
+	if f.err != nil {
+		return authenticator.User{}, f.err
+	}
+	expected, ok := f.users[username]
+	if !ok {
+		return authenticator.User{}, authenticator.ErrNotManaged
+	}
+	if expected != pw {
+		return authenticator.User{}, password.ErrMismatchedHashAndPassword
+	}
+	return f.profiles[username], nil
+}
+
+func addTestUser(t *testing.T, q *Queries, username, pw, email, source string) string {
+	sub, err := q.AddUser(context.Background(), AddUserParams{
+		Name:          username,
+		Username:      username,
+		Password:      pw,
+		Email:         email,
+		EmailVerified: email != "",
+		Role:          types.RoleMember,
+		UpdatedAt:     time.Now(),
+		Active:        true,
+		AuthSource:    source,
+	})
+	require.NoError(t, err)
+	return sub
+}
+
+func TestCheckLogin_LocalUserIgnoresDirectory(t *testing.T) {
+	q := newTestQueries(t)
+	ctx := context.Background()
+	f := &fakeAuthenticator{
+		users:     map[string]string{"admin": "directory-pw"},
+		profiles:  map[string]authenticator.User{"admin": {Name: "Mallory", Email: "mallory@example.com"}},
+		provision: true,
+	}
+	sub := addTestUser(t, q, "admin", "local-pw", "admin@example.com", "")
+
+	// a directory entry with the same username can't login as the local user
+	_, err := q.CheckLogin(ctx, []authenticator.Authenticator{f}, "admin", "directory-pw")
+	assert.ErrorIs(t, err, password.ErrMismatchedHashAndPassword)
+
+	login, err := q.CheckLogin(ctx, []authenticator.Authenticator{f}, "admin", "local-pw")
+	require.NoError(t, err)
+	assert.Equal(t, sub, login.Subject)
+	assert.Equal(t, "admin@example.com", login.Email)
+	assert.Equal(t, 0, f.calls)
+}
+
+func TestCheckLogin_Provision(t *testing.T) {
+	q := newTestQueries(t)
+	ctx := context.Background()
+	f := &fakeAuthenticator{
+		users: map[string]string{"alice": "alice-pw", "bob": "bob-pw"},
+		profiles: map[string]authenticator.User{
+			"alice": {Name: "Alice", Email: "alice@example.com"},
+			"bob":   {Name: "Bob", Email: "taken@example.com"},
+		},
+	}
+
+	// users are only provisioned if the directory allows it
+	_, err := q.CheckLogin(ctx, []authenticator.Authenticator{f}, "alice", "alice-pw")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	f.provision = true
+	_, err = q.CheckLogin(ctx, []authenticator.Authenticator{f}, "alice", "wrong")
+	assert.ErrorIs(t, err, password.ErrMismatchedHashAndPassword)
+	_, err = q.CheckLogin(ctx, []authenticator.Authenticator{f}, "carol", "carol-pw")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	login, err := q.CheckLogin(ctx, []authenticator.Authenticator{f}, "alice", "alice-pw")
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", login.Name)
+	assert.Equal(t, "alice@example.com", login.Email)
+	assert.True(t, login.EmailVerified)
+	user, err := q.GetUser(ctx, login.Subject)
+	require.NoError(t, err)
+	assert.Equal(t, "fake", user.AuthSource)
+
+	// an email address used by another account is not copied
+	addTestUser(t, q, "taken", "taken-pw", "taken@example.com", "")
+	login, err = q.CheckLogin(ctx, []authenticator.Authenticator{f}, "bob", "bob-pw")
+	require.NoError(t, err)
+	assert.Equal(t, "", login.Email)
+	assert.False(t, login.EmailVerified)
+}
+
+func TestCheckLogin_Sync(t *testing.T) {
+	q := newTestQueries(t)
+	ctx := context.Background()
+	f := &fakeAuthenticator{
+		users:    map[string]string{"alice": "alice-pw"},
+		profiles: map[string]authenticator.User{"alice": {Name: "Alice Smith", Email: "alice@example.com"}},
+	}
+	sub := addTestUser(t, q, "alice", "random", "old@example.com", "fake")
+
+	login, err := q.CheckLogin(ctx, []authenticator.Authenticator{f}, "alice", "alice-pw")
+	require.NoError(t, err)
+	assert.Equal(t, sub, login.Subject)
+	user, err := q.GetUser(ctx, sub)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice Smith", user.Name)
+	assert.Equal(t, "alice@example.com", user.Email)
+	assert.True(t, user.EmailVerified)
+
+	// the email address is kept if another account uses the new address
+	addTestUser(t, q, "other", "other-pw", "new@example.com", "")
+	f.profiles["alice"] = authenticator.User{Name: "Alice Smith", Email: "new@example.com"}
+	_, err = q.CheckLogin(ctx, []authenticator.Authenticator{f}, "alice", "alice-pw")
+	require.NoError(t, err)
+	user, err = q.GetUser(ctx, sub)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", user.Email)
+
+	// users removed from the directory can't login
+	delete(f.users, "alice")
+	_, err = q.CheckLogin(ctx, []authenticator.Authenticator{f}, "alice", "alice-pw")
+	assert.ErrorIs(t, err, password.ErrMismatchedHashAndPassword)
+}
+
+func TestCheckLogin_DirectoryUnavailable(t *testing.T) {
+	q := newTestQueries(t)
+	ctx := context.Background()
+	f := &fakeAuthenticator{provision: true, err: errDirectoryDown}
+	sub := addTestUser(t, q, "local", "local-pw", "", "")
+	addTestUser(t, q, "alice", "fallback-pw", "", "fake")
+
+	login, err := q.CheckLogin(ctx, []authenticator.Authenticator{f}, "local", "local-pw")
+	require.NoError(t, err)
+	assert.Equal(t, sub, login.Subject)
+
+	// directory users can't use the local password
+	_, err = q.CheckLogin(ctx, []authenticator.Authenticator{f}, "alice", "alice-pw")
+	assert.ErrorIs(t, err, ErrDirectoryUnavailable)
+	_, err = q.CheckLogin(ctx, []authenticator.Authenticator{f}, "alice", "fallback-pw")
+	assert.ErrorIs(t, err, ErrDirectoryUnavailable)
+	_, err = q.CheckLogin(ctx, nil, "alice", "fallback-pw")
+	assert.ErrorIs(t, err, ErrDirectoryUnavailable)
+	_, err = q.CheckLogin(ctx, []authenticator.Authenticator{f}, "bob", "bob-pw")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestCheckPassword_External(t *testing.T) {
+	q := newTestQueries(t)
+	ctx := context.Background()
+	sub := addTestUser(t, q, "alice", "random", "", "fake")
+	assert.ErrorIs(t, q.CheckPassword(ctx, sub, "random"), ErrExternalAccount)
+	assert.ErrorIs(t, q.ChangePassword(ctx, sub, "new-password"), ErrExternalAccount)
+
+	sub = addTestUser(t, q, "bob", "bob-pw", "", "")
+	assert.NoError(t, q.CheckPassword(ctx, sub, "bob-pw"))
+	assert.NoError(t, q.ChangePassword(ctx, sub, "new-password"))
+	assert.NoError(t, q.CheckPassword(ctx, sub, "new-password"))
+}
@@ -22,3 +22,7 @@ func (c *ClientStore) IsSSO() bool { return c.Sso }
 
 // IsActive is an extra field for the app manager to get the active state
 func (c *ClientStore) IsActive() bool { return c.Active }
+
+// GetSubjectType is an extra field for the oauth handler to decide if the user
+// subject is replaced with a pairwise identifier
+func (c *ClientStore) GetSubjectType() string { return c.SubjectType }
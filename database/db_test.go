@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestQueries opens an empty database with all migrations applied
+func newTestQueries(t *testing.T) *Queries {
+	dbOpen, mig := newTestMigrate(t)
+	if err := mig.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatal(err)
+	}
+	return New(dbOpen)
+}
+
+// newTestMigrate opens an empty database without applying any migrations
+func newTestMigrate(t *testing.T) (*sql.DB, *migrate.Migrate) {
+	migDrv, err := iofs.New(os.DirFS("migrations"), ".")
+	require.NoError(t, err)
+	dbOpen, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "tulip.sqlite"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = dbOpen.Close() })
+	dbDrv, err := sqlite3.WithInstance(dbOpen, &sqlite3.Config{})
+	require.NoError(t, err)
+	mig, err := migrate.NewWithInstance("iofs", migDrv, "sqlite3", dbDrv)
+	require.NoError(t, err)
+	return dbOpen, mig
+}
+
+func TestMigrationActivatesAdmins(t *testing.T) {
+	dbOpen, mig := newTestMigrate(t)
+	require.NoError(t, mig.Migrate(20240801120000))
+
+	// the initial admin was created inactive by older versions
+	_, err := dbOpen.Exec(`INSERT INTO users (subject, name, username, password, email, role, updated_at, registered, active)
+VALUES ('admin', 'Admin', 'admin', '', 'admin@localhost', 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 0),
+       ('member', 'Member', 'member', '', 'member@localhost', 0, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 0)`)
+	require.NoError(t, err)
+	require.NoError(t, mig.Up())
+
+	db := New(dbOpen)
+	admin, err := db.GetUser(context.Background(), "admin")
+	require.NoError(t, err)
+	assert.True(t, admin.Active)
+	member, err := db.GetUser(context.Background(), "member")
+	require.NoError(t, err)
+	assert.False(t, member.Active)
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/1f349/tulip/database/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// addTestGroups adds the groups staff > london > ops
+func addTestGroups(t *testing.T, db *Queries) {
+	ctx := context.Background()
+	require.NoError(t, db.AddGroup(ctx, AddGroupParams{Subject: "staff", Name: "Staff"}))
+	require.NoError(t, db.AddGroup(ctx, AddGroupParams{Subject: "london", Name: "London", Parent: sql.NullString{String: "staff", Valid: true}}))
+	require.NoError(t, db.AddGroup(ctx, AddGroupParams{Subject: "ops", Name: "Ops", Parent: sql.NullString{String: "london", Valid: true}}))
+	require.NoError(t, db.AddGroup(ctx, AddGroupParams{Subject: "other", Name: "Other"}))
+}
+
+func TestGetGroupAncestors(t *testing.T) {
+	db := newTestQueries(t)
+	addTestGroups(t, db)
+
+	ancestors, err := db.GetGroupAncestors(context.Background(), "ops")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"ops", "london", "staff"}, ancestors)
+
+	ancestors, err = db.GetGroupAncestors(context.Background(), "staff")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"staff"}, ancestors)
+}
+
+func TestGetUserGroupNames(t *testing.T) {
+	db := newTestQueries(t)
+	ctx := context.Background()
+	addTestGroups(t, db)
+	subject, err := db.AddUser(ctx, AddUserParams{
+		Name:      "Alice",
+		Username:  "alice",
+		Password:  "password",
+		Email:     "alice@example.com",
+		Role:      types.RoleMember,
+		UpdatedAt: time.Now(),
+		Active:    true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.AddGroupMemberByUsername(ctx, AddGroupMemberByUsernameParams{GroupSubject: "ops", Username: "alice"}))
+	// a direct membership of an ancestor is only listed once
+	require.NoError(t, db.AddGroupMemberByUsername(ctx, AddGroupMemberByUsernameParams{GroupSubject: "staff", Username: "alice"}))
+
+	names, err := db.GetUserGroupNames(ctx, subject)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"London", "Ops", "Staff"}, names)
+
+	subjects, err := db.GetUserGroupSubjects(ctx, subject)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"ops", "london", "staff"}, subjects)
+
+	// the recursion stops if the database contains a cycle
+	require.NoError(t, db.UpdateGroup(ctx, UpdateGroupParams{Name: "Staff", Parent: sql.NullString{String: "ops", Valid: true}, Subject: "staff"}))
+	names, err = db.GetUserGroupNames(ctx, subject)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"London", "Ops", "Staff"}, names)
+}
@@ -10,7 +10,7 @@ import (
 )
 
 const getAppList = `-- name: GetAppList :many
-SELECT subject, name, domain, owner, public, sso, active
+SELECT subject, name, domain, owner, public, sso, active, subject_type
 FROM client_store
 WHERE owner = ?
    OR ? = 1
@@ -24,13 +24,14 @@ type GetAppListParams struct {
 }
 
 type GetAppListRow struct {
-	Subject string `json:"subject"`
-	Name    string `json:"name"`
-	Domain  string `json:"domain"`
-	Owner   string `json:"owner"`
-	Public  bool   `json:"public"`
-	Sso     bool   `json:"sso"`
-	Active  bool   `json:"active"`
+	Subject     string `json:"subject"`
+	Name        string `json:"name"`
+	Domain      string `json:"domain"`
+	Owner       string `json:"owner"`
+	Public      bool   `json:"public"`
+	Sso         bool   `json:"sso"`
+	Active      bool   `json:"active"`
+	SubjectType string `json:"subject_type"`
 }
 
 func (q *Queries) GetAppList(ctx context.Context, arg GetAppListParams) ([]GetAppListRow, error) {
@@ -50,6 +51,7 @@ func (q *Queries) GetAppList(ctx context.Context, arg GetAppListParams) ([]GetAp
 			&i.Public,
 			&i.Sso,
 			&i.Active,
+			&i.SubjectType,
 		); err != nil {
 			return nil, err
 		}
@@ -65,7 +67,7 @@ func (q *Queries) GetAppList(ctx context.Context, arg GetAppListParams) ([]GetAp
 }
 
 const getClientInfo = `-- name: GetClientInfo :one
-SELECT subject, name, secret, domain, owner, public, sso, active
+SELECT subject, name, secret, domain, owner, public, sso, active, subject_type
 FROM client_store
 WHERE subject = ?
 LIMIT 1
@@ -83,24 +85,26 @@ func (q *Queries) GetClientInfo(ctx context.Context, subject string) (ClientStor
 		&i.Public,
 		&i.Sso,
 		&i.Active,
+		&i.SubjectType,
 	)
 	return i, err
 }
 
 const insertClientApp = `-- name: InsertClientApp :exec
-INSERT INTO client_store (subject, name, secret, domain, owner, public, sso, active)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+INSERT INTO client_store (subject, name, secret, domain, owner, public, sso, active, subject_type)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 `
 
 type InsertClientAppParams struct {
-	Subject string `json:"subject"`
-	Name    string `json:"name"`
-	Secret  string `json:"secret"`
-	Domain  string `json:"domain"`
-	Owner   string `json:"owner"`
-	Public  bool   `json:"public"`
-	Sso     bool   `json:"sso"`
-	Active  bool   `json:"active"`
+	Subject     string `json:"subject"`
+	Name        string `json:"name"`
+	Secret      string `json:"secret"`
+	Domain      string `json:"domain"`
+	Owner       string `json:"owner"`
+	Public      bool   `json:"public"`
+	Sso         bool   `json:"sso"`
+	Active      bool   `json:"active"`
+	SubjectType string `json:"subject_type"`
 }
 
 func (q *Queries) InsertClientApp(ctx context.Context, arg InsertClientAppParams) error {
@@ -113,6 +117,7 @@ func (q *Queries) InsertClientApp(ctx context.Context, arg InsertClientAppParams
 		arg.Public,
 		arg.Sso,
 		arg.Active,
+		arg.SubjectType,
 	)
 	return err
 }
@@ -137,23 +142,25 @@ func (q *Queries) ResetClientAppSecret(ctx context.Context, arg ResetClientAppSe
 
 const updateClientApp = `-- name: UpdateClientApp :exec
 UPDATE client_store
-SET name   = ?,
-    domain = ?,
-    public = ?,
-    sso    = ?,
-    active = ?
+SET name         = ?,
+    domain       = ?,
+    public       = ?,
+    sso          = ?,
+    active       = ?,
+    subject_type = ?
 WHERE subject = ?
   AND owner = ?
 `
 
 type UpdateClientAppParams struct {
-	Name    string `json:"name"`
-	Domain  string `json:"domain"`
-	Public  bool   `json:"public"`
-	Sso     bool   `json:"sso"`
-	Active  bool   `json:"active"`
-	Subject string `json:"subject"`
-	Owner   string `json:"owner"`
+	Name        string `json:"name"`
+	Domain      string `json:"domain"`
+	Public      bool   `json:"public"`
+	Sso         bool   `json:"sso"`
+	Active      bool   `json:"active"`
+	SubjectType string `json:"subject_type"`
+	Subject     string `json:"subject"`
+	Owner       string `json:"owner"`
 }
 
 func (q *Queries) UpdateClientApp(ctx context.Context, arg UpdateClientAppParams) error {
@@ -163,6 +170,7 @@ func (q *Queries) UpdateClientApp(ctx context.Context, arg UpdateClientAppParams
 		arg.Public,
 		arg.Sso,
 		arg.Active,
+		arg.SubjectType,
 		arg.Subject,
 		arg.Owner,
 	)
@@ -7,11 +7,69 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/1f349/tulip/database/types"
 )
 
+const getServiceAccounts = `-- name: GetServiceAccounts :many
+SELECT subject,
+       name,
+       username,
+       role,
+       updated_at,
+       active,
+       failed_logins,
+       locked_until
+FROM users
+WHERE service_account = 1
+ORDER BY username
+`
+
+type GetServiceAccountsRow struct {
+	Subject      string         `json:"subject"`
+	Name         string         `json:"name"`
+	Username     string         `json:"username"`
+	Role         types.UserRole `json:"role"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	Active       bool           `json:"active"`
+	FailedLogins int64          `json:"failed_logins"`
+	LockedUntil  sql.NullTime   `json:"locked_until"`
+}
+
+func (q *Queries) GetServiceAccounts(ctx context.Context) ([]GetServiceAccountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getServiceAccounts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetServiceAccountsRow
+	for rows.Next() {
+		var i GetServiceAccountsRow
+		if err := rows.Scan(
+			&i.Subject,
+			&i.Name,
+			&i.Username,
+			&i.Role,
+			&i.UpdatedAt,
+			&i.Active,
+			&i.FailedLogins,
+			&i.LockedUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserList = `-- name: GetUserList :many
 SELECT subject,
        name,
@@ -22,8 +80,13 @@ SELECT subject,
        email_verified,
        role,
        updated_at,
-       active
+       active,
+       failed_logins,
+       locked_until,
+       EXISTS(SELECT 1 FROM otp WHERE otp.subject = users.subject) == 1                                  AS has_otp,
+       EXISTS(SELECT 1 FROM webauthn_credentials WHERE webauthn_credentials.subject = users.subject) == 1 AS has_webauthn
 FROM users
+WHERE service_account = 0
 LIMIT 25 OFFSET ?
 `
 
@@ -38,6 +101,10 @@ type GetUserListRow struct {
 	Role          types.UserRole `json:"role"`
 	UpdatedAt     time.Time      `json:"updated_at"`
 	Active        bool           `json:"active"`
+	FailedLogins  int64          `json:"failed_logins"`
+	LockedUntil   sql.NullTime   `json:"locked_until"`
+	HasOtp        bool           `json:"has_otp"`
+	HasWebauthn   bool           `json:"has_webauthn"`
 }
 
 func (q *Queries) GetUserList(ctx context.Context, offset int64) ([]GetUserListRow, error) {
@@ -60,6 +127,10 @@ func (q *Queries) GetUserList(ctx context.Context, offset int64) ([]GetUserListR
 			&i.Role,
 			&i.UpdatedAt,
 			&i.Active,
+			&i.FailedLogins,
+			&i.LockedUntil,
+			&i.HasOtp,
+			&i.HasWebauthn,
 		); err != nil {
 			return nil, err
 		}
@@ -103,6 +174,17 @@ func (q *Queries) UserEmailExists(ctx context.Context, email string) (bool, erro
 	return email_exists, err
 }
 
+const usernameExists = `-- name: UsernameExists :one
+SELECT EXISTS(SELECT 1 FROM users WHERE username = ?) == 1 AS username_exists
+`
+
+func (q *Queries) UsernameExists(ctx context.Context, username string) (bool, error) {
+	row := q.db.QueryRowContext(ctx, usernameExists, username)
+	var username_exists bool
+	err := row.Scan(&username_exists)
+	return username_exists, err
+}
+
 const verifyUserEmail = `-- name: VerifyUserEmail :exec
 UPDATE users
 SET email_verified = 1
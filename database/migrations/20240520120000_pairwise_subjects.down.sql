@@ -0,0 +1,3 @@
+DROP TABLE pairwise_subjects;
+ALTER TABLE client_store
+    DROP COLUMN subject_type;
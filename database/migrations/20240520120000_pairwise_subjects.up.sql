@@ -0,0 +1,10 @@
+ALTER TABLE client_store
+    ADD COLUMN subject_type TEXT DEFAULT 'public' NOT NULL;
+
+CREATE TABLE pairwise_subjects
+(
+    pairwise TEXT PRIMARY KEY UNIQUE NOT NULL,
+    sector   TEXT                    NOT NULL,
+    subject  TEXT                    NOT NULL,
+    FOREIGN KEY (subject) REFERENCES users (subject)
+);
@@ -0,0 +1,17 @@
+CREATE TABLE groups
+(
+    subject     TEXT PRIMARY KEY UNIQUE NOT NULL,
+    name        TEXT UNIQUE             NOT NULL,
+    description TEXT DEFAULT ''         NOT NULL,
+    parent      TEXT,
+    FOREIGN KEY (parent) REFERENCES groups (subject)
+);
+
+CREATE TABLE group_members
+(
+    group_subject TEXT NOT NULL,
+    user_subject  TEXT NOT NULL,
+    PRIMARY KEY (group_subject, user_subject),
+    FOREIGN KEY (group_subject) REFERENCES groups (subject),
+    FOREIGN KEY (user_subject) REFERENCES users (subject)
+);
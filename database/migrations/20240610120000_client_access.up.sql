@@ -0,0 +1,8 @@
+CREATE TABLE client_access
+(
+    client_subject TEXT NOT NULL,
+    kind           TEXT NOT NULL,
+    value          TEXT NOT NULL,
+    PRIMARY KEY (client_subject, kind, value),
+    FOREIGN KEY (client_subject) REFERENCES client_store (subject)
+);
@@ -0,0 +1,20 @@
+ALTER TABLE users
+    DROP COLUMN address;
+
+ALTER TABLE users
+    DROP COLUMN phone_number;
+
+ALTER TABLE users
+    DROP COLUMN gender;
+
+ALTER TABLE users
+    DROP COLUMN nickname;
+
+ALTER TABLE users
+    DROP COLUMN middle_name;
+
+ALTER TABLE users
+    DROP COLUMN family_name;
+
+ALTER TABLE users
+    DROP COLUMN given_name;
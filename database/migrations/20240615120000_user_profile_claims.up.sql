@@ -0,0 +1,20 @@
+ALTER TABLE users
+    ADD COLUMN given_name TEXT DEFAULT '' NOT NULL;
+
+ALTER TABLE users
+    ADD COLUMN family_name TEXT DEFAULT '' NOT NULL;
+
+ALTER TABLE users
+    ADD COLUMN middle_name TEXT DEFAULT '' NOT NULL;
+
+ALTER TABLE users
+    ADD COLUMN nickname TEXT DEFAULT '' NOT NULL;
+
+ALTER TABLE users
+    ADD COLUMN gender TEXT DEFAULT '' NOT NULL;
+
+ALTER TABLE users
+    ADD COLUMN phone_number TEXT DEFAULT '' NOT NULL;
+
+ALTER TABLE users
+    ADD COLUMN address TEXT DEFAULT '{}' NOT NULL;
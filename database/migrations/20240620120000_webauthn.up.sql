@@ -0,0 +1,10 @@
+CREATE TABLE webauthn_credentials
+(
+    id         TEXT PRIMARY KEY UNIQUE NOT NULL,
+    subject    TEXT                    NOT NULL,
+    name       TEXT                    NOT NULL,
+    credential TEXT                    NOT NULL,
+    created_at DATETIME                NOT NULL,
+    last_used  DATETIME,
+    FOREIGN KEY (subject) REFERENCES users (subject)
+);
@@ -0,0 +1,7 @@
+CREATE TABLE otp_recovery_codes
+(
+    subject   TEXT NOT NULL,
+    code_hash TEXT NOT NULL,
+    PRIMARY KEY (subject, code_hash),
+    FOREIGN KEY (subject) REFERENCES users (subject)
+);
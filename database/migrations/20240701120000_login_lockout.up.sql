@@ -0,0 +1,5 @@
+ALTER TABLE users
+    ADD COLUMN failed_logins INTEGER DEFAULT 0 NOT NULL;
+
+ALTER TABLE users
+    ADD COLUMN locked_until DATETIME;
@@ -0,0 +1,2 @@
+ALTER TABLE users
+    ADD COLUMN sessions_revoked_at DATETIME;
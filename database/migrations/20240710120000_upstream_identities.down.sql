@@ -0,0 +1 @@
+DROP TABLE upstream_identities;
@@ -0,0 +1,10 @@
+CREATE TABLE upstream_identities
+(
+    issuer           TEXT     NOT NULL,
+    upstream_subject TEXT     NOT NULL,
+    subject          TEXT     NOT NULL,
+    email            TEXT     NOT NULL,
+    linked_at        DATETIME NOT NULL,
+    PRIMARY KEY (issuer, upstream_subject),
+    FOREIGN KEY (subject) REFERENCES users (subject)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE app_passwords
+(
+    id            TEXT PRIMARY KEY UNIQUE NOT NULL,
+    subject       TEXT                    NOT NULL,
+    name          TEXT                    NOT NULL,
+    password_hash TEXT UNIQUE             NOT NULL,
+    scopes        TEXT                    NOT NULL,
+    created_at    DATETIME                NOT NULL,
+    last_used     DATETIME,
+    FOREIGN KEY (subject) REFERENCES users (subject)
+);
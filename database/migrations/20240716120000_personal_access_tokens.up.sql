@@ -0,0 +1,12 @@
+CREATE TABLE personal_access_tokens
+(
+    id         TEXT PRIMARY KEY UNIQUE NOT NULL,
+    subject    TEXT                    NOT NULL,
+    name       TEXT                    NOT NULL,
+    token_hash TEXT UNIQUE             NOT NULL,
+    scope      TEXT                    NOT NULL,
+    created_at DATETIME                NOT NULL,
+    expires_at DATETIME                NOT NULL,
+    last_used  DATETIME,
+    FOREIGN KEY (subject) REFERENCES users (subject)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE users
+    ADD COLUMN service_account BOOLEAN DEFAULT 0 NOT NULL;
@@ -0,0 +1,10 @@
+CREATE TABLE trusted_devices
+(
+    id         TEXT PRIMARY KEY UNIQUE NOT NULL,
+    subject    TEXT                    NOT NULL,
+    name       TEXT                    NOT NULL,
+    created_at DATETIME                NOT NULL,
+    expires_at DATETIME                NOT NULL,
+    last_used  DATETIME,
+    FOREIGN KEY (subject) REFERENCES users (subject)
+);
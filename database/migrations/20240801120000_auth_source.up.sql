@@ -0,0 +1,2 @@
+ALTER TABLE users
+    ADD COLUMN auth_source TEXT DEFAULT '' NOT NULL;
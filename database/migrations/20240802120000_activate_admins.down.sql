@@ -0,0 +1 @@
+-- admins activated by the up migration are left active
@@ -0,0 +1,5 @@
+-- the initial admin used to be created inactive, which was never checked before
+-- registration approval started using the active flag
+UPDATE users
+SET active = 1
+WHERE role = 1;
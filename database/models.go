@@ -5,6 +5,7 @@
 package database
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/1f349/tulip/database/types"
@@ -12,15 +13,44 @@ import (
 	"github.com/hardfinhq/go-date"
 )
 
+type AppPassword struct {
+	ID           string       `json:"id"`
+	Subject      string       `json:"subject"`
+	Name         string       `json:"name"`
+	PasswordHash string       `json:"password_hash"`
+	Scopes       string       `json:"scopes"`
+	CreatedAt    time.Time    `json:"created_at"`
+	LastUsed     sql.NullTime `json:"last_used"`
+}
+
+type ClientAccess struct {
+	ClientSubject string `json:"client_subject"`
+	Kind          string `json:"kind"`
+	Value         string `json:"value"`
+}
+
 type ClientStore struct {
-	Subject string `json:"subject"`
-	Name    string `json:"name"`
-	Secret  string `json:"secret"`
-	Domain  string `json:"domain"`
-	Owner   string `json:"owner"`
-	Public  bool   `json:"public"`
-	Sso     bool   `json:"sso"`
-	Active  bool   `json:"active"`
+	Subject     string `json:"subject"`
+	Name        string `json:"name"`
+	Secret      string `json:"secret"`
+	Domain      string `json:"domain"`
+	Owner       string `json:"owner"`
+	Public      bool   `json:"public"`
+	Sso         bool   `json:"sso"`
+	Active      bool   `json:"active"`
+	SubjectType string `json:"subject_type"`
+}
+
+type Group struct {
+	Subject     string         `json:"subject"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parent      sql.NullString `json:"parent"`
+}
+
+type GroupMember struct {
+	GroupSubject string `json:"group_subject"`
+	UserSubject  string `json:"user_subject"`
 }
 
 type Otp struct {
@@ -29,21 +59,82 @@ type Otp struct {
 	Digits  int64  `json:"digits"`
 }
 
+type OtpRecoveryCode struct {
+	Subject  string `json:"subject"`
+	CodeHash string `json:"code_hash"`
+}
+
+type PairwiseSubject struct {
+	Pairwise string `json:"pairwise"`
+	Sector   string `json:"sector"`
+	Subject  string `json:"subject"`
+}
+
+type PersonalAccessToken struct {
+	ID        string       `json:"id"`
+	Subject   string       `json:"subject"`
+	Name      string       `json:"name"`
+	TokenHash string       `json:"token_hash"`
+	Scope     string       `json:"scope"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	LastUsed  sql.NullTime `json:"last_used"`
+}
+
+type TrustedDevice struct {
+	ID        string       `json:"id"`
+	Subject   string       `json:"subject"`
+	Name      string       `json:"name"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	LastUsed  sql.NullTime `json:"last_used"`
+}
+
+type UpstreamIdentity struct {
+	Issuer          string    `json:"issuer"`
+	UpstreamSubject string    `json:"upstream_subject"`
+	Subject         string    `json:"subject"`
+	Email           string    `json:"email"`
+	LinkedAt        time.Time `json:"linked_at"`
+}
+
 type User struct {
-	Subject       string              `json:"subject"`
-	Name          string              `json:"name"`
-	Username      string              `json:"username"`
-	Password      password.HashString `json:"password"`
-	Picture       string              `json:"picture"`
-	Website       string              `json:"website"`
-	Email         string              `json:"email"`
-	EmailVerified bool                `json:"email_verified"`
-	Pronouns      types.UserPronoun   `json:"pronouns"`
-	Birthdate     date.NullDate       `json:"birthdate"`
-	Zoneinfo      types.UserZone      `json:"zoneinfo"`
-	Locale        types.UserLocale    `json:"locale"`
-	Role          types.UserRole      `json:"role"`
-	UpdatedAt     time.Time           `json:"updated_at"`
-	Registered    time.Time           `json:"registered"`
-	Active        bool                `json:"active"`
+	Subject           string              `json:"subject"`
+	Name              string              `json:"name"`
+	Username          string              `json:"username"`
+	Password          password.HashString `json:"password"`
+	Picture           string              `json:"picture"`
+	Website           string              `json:"website"`
+	Email             string              `json:"email"`
+	EmailVerified     bool                `json:"email_verified"`
+	Pronouns          types.UserPronoun   `json:"pronouns"`
+	Birthdate         date.NullDate       `json:"birthdate"`
+	Zoneinfo          types.UserZone      `json:"zoneinfo"`
+	Locale            types.UserLocale    `json:"locale"`
+	Role              types.UserRole      `json:"role"`
+	UpdatedAt         time.Time           `json:"updated_at"`
+	Registered        time.Time           `json:"registered"`
+	Active            bool                `json:"active"`
+	GivenName         string              `json:"given_name"`
+	FamilyName        string              `json:"family_name"`
+	MiddleName        string              `json:"middle_name"`
+	Nickname          string              `json:"nickname"`
+	Gender            string              `json:"gender"`
+	PhoneNumber       string              `json:"phone_number"`
+	Address           types.UserAddress   `json:"address"`
+	FailedLogins      int64               `json:"failed_logins"`
+	LockedUntil       sql.NullTime        `json:"locked_until"`
+	SessionsRevokedAt sql.NullTime        `json:"sessions_revoked_at"`
+	ServiceAccount    bool                `json:"service_account"`
+	TwoFactorDeadline sql.NullTime        `json:"two_factor_deadline"`
+	AuthSource        string              `json:"auth_source"`
+}
+
+type WebauthnCredential struct {
+	ID         string       `json:"id"`
+	Subject    string       `json:"subject"`
+	Name       string       `json:"name"`
+	Credential string       `json:"credential"`
+	CreatedAt  time.Time    `json:"created_at"`
+	LastUsed   sql.NullTime `json:"last_used"`
 }
@@ -2,6 +2,9 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"github.com/1f349/tulip/authenticator"
 	"github.com/1f349/tulip/database/types"
 	"github.com/1f349/tulip/password"
 	"github.com/google/uuid"
@@ -17,6 +20,9 @@ type AddUserParams struct {
 	Role          types.UserRole `json:"role"`
 	UpdatedAt     time.Time      `json:"updated_at"`
 	Active        bool           `json:"active"`
+	// AuthSource is the name of the directory managing the user, local users
+	// have an empty auth source
+	AuthSource string `json:"auth_source"`
 }
 
 func (q *Queries) AddUser(ctx context.Context, arg AddUserParams) (string, error) {
@@ -33,35 +39,109 @@ func (q *Queries) AddUser(ctx context.Context, arg AddUserParams) (string, error
 		EmailVerified: arg.EmailVerified,
 		Role:          arg.Role,
 		UpdatedAt:     arg.UpdatedAt,
+		Registered:    time.Now(),
 		Active:        arg.Active,
+		AuthSource:    arg.AuthSource,
 	}
 	return a.Subject, q.addUser(ctx, a)
 }
 
+// AddServiceAccount adds a non-interactive user, the random password is never
+// shown and service accounts are excluded from the login queries
+func (q *Queries) AddServiceAccount(ctx context.Context, name, username string) (string, error) {
+	pw, err := password.GenerateApiSecret(32)
+	if err != nil {
+		return "", err
+	}
+	pwHash, err := password.HashPassword(pw)
+	if err != nil {
+		return "", err
+	}
+	a := addServiceAccountParams{
+		Subject:    uuid.NewString(),
+		Name:       name,
+		Username:   username,
+		Password:   pwHash,
+		UpdatedAt:  time.Now(),
+		Registered: time.Now(),
+	}
+	return a.Subject, q.addServiceAccount(ctx, a)
+}
+
 type CheckLoginResult struct {
 	Subject       string `json:"subject"`
 	Name          string `json:"name"`
 	HasOtp        bool   `json:"hasTwoFactor"`
+	HasWebauthn   bool   `json:"has_webauthn"`
 	Email         string `json:"email"`
 	EmailVerified bool   `json:"email_verified"`
+	Active        bool   `json:"active"`
 }
 
-func (q *Queries) CheckLogin(ctx context.Context, un, pw string) (CheckLoginResult, error) {
+// CheckLogin checks the password of a user, unknown usernames are checked
+// against the authenticators which provision users, users provisioned by an
+// authenticator are checked against it and local users only use the local
+// password
+func (q *Queries) CheckLogin(ctx context.Context, authenticators []authenticator.Authenticator, un, pw string) (CheckLoginResult, error) {
 	login, err := q.checkLogin(ctx, un)
+	if errors.Is(err, sql.ErrNoRows) {
+		login, err = q.provisionExternalLogin(ctx, authenticators, un, pw)
+		if err != nil {
+			return CheckLoginResult{}, err
+		}
+		return newCheckLoginResult(login), nil
+	}
 	if err != nil {
 		return CheckLoginResult{}, err
 	}
+
+	if login.AuthSource != "" {
+		login, err = q.checkExternalLogin(ctx, authenticators, login, un, pw)
+		if err != nil {
+			return CheckLoginResult{}, err
+		}
+		return newCheckLoginResult(login), nil
+	}
+
 	err = password.CheckPasswordHash(login.Password, pw)
 	if err != nil {
 		return CheckLoginResult{}, err
 	}
+	if password.NeedsRehash(login.Password) {
+		// failing to upgrade the hash should not prevent the login
+		newHash, err := password.HashPassword(pw)
+		if err == nil {
+			_ = q.rehashUserPassword(ctx, rehashUserPasswordParams{
+				Password:   newHash,
+				Subject:    login.Subject,
+				Password_2: login.Password,
+			})
+		}
+	}
+	return newCheckLoginResult(login), nil
+}
+
+func newCheckLoginResult(login checkLoginRow) CheckLoginResult {
 	return CheckLoginResult{
 		Subject:       login.Subject,
 		Name:          login.Name,
 		HasOtp:        login.HasOtp,
+		HasWebauthn:   login.HasWebauthn,
 		Email:         login.Email,
 		EmailVerified: login.EmailVerified,
-	}, nil
+		Active:        login.Active,
+	}
+}
+
+func (q *Queries) CheckPassword(ctx context.Context, subject, pw string) error {
+	userPassword, err := q.getUserPassword(ctx, subject)
+	if err != nil {
+		return err
+	}
+	if userPassword.AuthSource != "" {
+		return ErrExternalAccount
+	}
+	return password.CheckPasswordHash(userPassword.Password, pw)
 }
 
 func (q *Queries) ChangePassword(ctx context.Context, subject, newPw string) error {
@@ -69,6 +149,9 @@ func (q *Queries) ChangePassword(ctx context.Context, subject, newPw string) err
 	if err != nil {
 		return err
 	}
+	if userPassword.AuthSource != "" {
+		return ErrExternalAccount
+	}
 	newPwHash, err := password.HashPassword(newPw)
 	if err != nil {
 		return err
@@ -77,6 +160,6 @@ func (q *Queries) ChangePassword(ctx context.Context, subject, newPw string) err
 		Password:   newPwHash,
 		UpdatedAt:  time.Now(),
 		Subject:    subject,
-		Password_2: userPassword,
+		Password_2: userPassword.Password,
 	})
 }
@@ -0,0 +1,26 @@
+-- name: GetAppPasswords :many
+SELECT id, name, scopes, created_at, last_used
+FROM app_passwords
+WHERE subject = ?
+ORDER BY created_at;
+
+-- name: AddAppPassword :exec
+INSERT INTO app_passwords (id, subject, name, password_hash, scopes, created_at)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: DeleteAppPassword :exec
+DELETE
+FROM app_passwords
+WHERE id = ?
+  AND subject = ?;
+
+-- name: GetAppPassword :one
+SELECT id, scopes
+FROM app_passwords
+WHERE subject = ?
+  AND password_hash = ?;
+
+-- name: SetAppPasswordLastUsed :exec
+UPDATE app_passwords
+SET last_used = ?
+WHERE id = ?;
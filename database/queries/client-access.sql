@@ -0,0 +1,14 @@
+-- name: GetClientAccess :many
+SELECT kind, value
+FROM client_access
+WHERE client_subject = ?
+ORDER BY kind, value;
+
+-- name: AddClientAccess :exec
+INSERT OR IGNORE INTO client_access (client_subject, kind, value)
+VALUES (?, ?, ?);
+
+-- name: ClearClientAccess :exec
+DELETE
+FROM client_access
+WHERE client_subject = ?;
@@ -0,0 +1,97 @@
+-- name: GetGroupList :many
+SELECT subject, name, description, parent
+FROM groups
+ORDER BY name
+LIMIT 25 OFFSET ?;
+
+-- name: GetAllGroups :many
+SELECT subject, name
+FROM groups
+ORDER BY name;
+
+-- name: GetGroup :one
+SELECT subject, name, description, parent
+FROM groups
+WHERE subject = ?
+LIMIT 1;
+
+-- name: AddGroup :exec
+INSERT INTO groups (subject, name, description, parent)
+VALUES (?, ?, ?, ?);
+
+-- name: UpdateGroup :exec
+UPDATE groups
+SET name        = ?,
+    description = ?,
+    parent      = ?
+WHERE subject = ?;
+
+-- name: ClearGroupParent :exec
+UPDATE groups
+SET parent = NULL
+WHERE parent = ?;
+
+-- name: DeleteGroupMembers :exec
+DELETE
+FROM group_members
+WHERE group_subject = ?;
+
+-- name: DeleteGroup :exec
+DELETE
+FROM groups
+WHERE subject = ?;
+
+-- name: GetGroupAncestors :many
+WITH RECURSIVE ancestors(subject) AS (SELECT ?
+                                      UNION
+                                      SELECT groups.parent
+                                      FROM groups
+                                               JOIN ancestors ON groups.subject = ancestors.subject
+                                      WHERE groups.parent IS NOT NULL)
+SELECT subject
+FROM ancestors;
+
+-- name: AddGroupMemberByUsername :exec
+INSERT OR IGNORE INTO group_members (group_subject, user_subject)
+SELECT ?, users.subject
+FROM users
+WHERE users.username = ?;
+
+-- name: RemoveGroupMember :exec
+DELETE
+FROM group_members
+WHERE group_subject = ?
+  AND user_subject = ?;
+
+-- name: GetGroupMembers :many
+SELECT users.subject, users.name, users.username
+FROM group_members
+         INNER JOIN users ON group_members.user_subject = users.subject
+WHERE group_members.group_subject = ?
+ORDER BY users.username;
+
+-- name: GetUserGroupNames :many
+WITH RECURSIVE user_groups(subject) AS (SELECT group_subject
+                                        FROM group_members
+                                        WHERE user_subject = ?
+                                        UNION
+                                        SELECT groups.parent
+                                        FROM groups
+                                                 JOIN user_groups ON groups.subject = user_groups.subject
+                                        WHERE groups.parent IS NOT NULL)
+SELECT groups.name
+FROM groups
+         JOIN user_groups ON groups.subject = user_groups.subject
+ORDER BY groups.name;
+
+-- name: GetUserGroupSubjects :many
+WITH RECURSIVE user_groups(subject) AS (SELECT group_subject
+                                        FROM group_members
+                                        WHERE user_subject = ?
+                                        UNION
+                                        SELECT groups.parent
+                                        FROM groups
+                                                 JOIN user_groups ON groups.subject = user_groups.subject
+                                        WHERE groups.parent IS NOT NULL)
+SELECT subject
+FROM user_groups;
@@ -0,0 +1,18 @@
+-- name: GetDirectoryUsers :many
+SELECT subject, username, name, given_name, family_name, email, email_verified
+FROM users
+WHERE active = 1
+  AND service_account = 0
+ORDER BY username;
+
+-- name: GetDirectoryGroups :many
+SELECT *
+FROM groups
+ORDER BY name;
+
+-- name: GetDirectoryGroupMembers :many
+SELECT group_members.group_subject, group_members.user_subject
+FROM group_members
+         INNER JOIN users ON group_members.user_subject = users.subject
+WHERE users.active = 1
+  AND users.service_account = 0;
@@ -0,0 +1,43 @@
+-- name: GetLoginLockout :one
+SELECT subject, failed_logins, locked_until
+FROM users
+WHERE username = ?
+LIMIT 1;
+
+-- name: GetLoginLockoutBySubject :one
+SELECT subject, failed_logins, locked_until
+FROM users
+WHERE subject = ?
+LIMIT 1;
+
+-- name: StartLoginAttempt :one
+UPDATE users
+SET failed_logins = failed_logins + 1
+WHERE subject = ?
+  AND (locked_until IS NULL OR locked_until <= ?)
+RETURNING failed_logins;
+
+-- name: ReleaseLoginAttempt :exec
+UPDATE users
+SET failed_logins = failed_logins - 1
+WHERE subject = ?
+  AND failed_logins > 0;
+
+-- name: SetLockedUntil :exec
+UPDATE users
+SET locked_until = ?
+WHERE subject = ?
+  AND failed_logins = ?;
+
+-- name: LockAccount :execrows
+UPDATE users
+SET failed_logins = 0,
+    locked_until  = ?
+WHERE subject = ?
+  AND failed_logins >= ?;
+
+-- name: ResetFailedLogins :exec
+UPDATE users
+SET failed_logins = 0,
+    locked_until  = NULL
+WHERE subject = ?;
@@ -5,23 +5,24 @@ WHERE subject = ?
 LIMIT 1;
 
 -- name: GetAppList :many
-SELECT subject, name, domain, owner, public, sso, active
+SELECT subject, name, domain, owner, public, sso, active, subject_type
 FROM client_store
 WHERE owner = ?
    OR ? = 1
 LIMIT 25 OFFSET ?;
 
 -- name: InsertClientApp :exec
-INSERT INTO client_store (subject, name, secret, domain, owner, public, sso, active)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+INSERT INTO client_store (subject, name, secret, domain, owner, public, sso, active, subject_type)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
 
 -- name: UpdateClientApp :exec
 UPDATE client_store
-SET name   = ?,
-    domain = ?,
-    public = ?,
-    sso    = ?,
-    active = ?
+SET name         = ?,
+    domain       = ?,
+    public       = ?,
+    sso          = ?,
+    active       = ?,
+    subject_type = ?
 WHERE subject = ?
   AND owner = ?;
 
@@ -8,10 +8,28 @@ SELECT subject,
        email_verified,
        role,
        updated_at,
-       active
+       active,
+       failed_logins,
+       locked_until,
+       EXISTS(SELECT 1 FROM otp WHERE otp.subject = users.subject) == 1                                  AS has_otp,
+       EXISTS(SELECT 1 FROM webauthn_credentials WHERE webauthn_credentials.subject = users.subject) == 1 AS has_webauthn
 FROM users
+WHERE service_account = 0
 LIMIT 25 OFFSET ?;
 
+-- name: GetServiceAccounts :many
+SELECT subject,
+       name,
+       username,
+       role,
+       updated_at,
+       active,
+       failed_logins,
+       locked_until
+FROM users
+WHERE service_account = 1
+ORDER BY username;
+
 -- name: UpdateUserRole :exec
 UPDATE users
 SET active = ?,
@@ -23,5 +41,8 @@ UPDATE users
 SET email_verified = 1
 WHERE subject = ?;
 
+-- name: UsernameExists :one
+SELECT EXISTS(SELECT 1 FROM users WHERE username = ?) == 1 AS username_exists;
+
 -- name: UserEmailExists :one
 SELECT EXISTS(SELECT 1 FROM users WHERE email = ? AND email_verified = 1) == 1 AS email_exists;
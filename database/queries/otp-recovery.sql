@@ -0,0 +1,19 @@
+-- name: AddOtpRecoveryCode :exec
+INSERT INTO otp_recovery_codes (subject, code_hash)
+VALUES (?, ?);
+
+-- name: CountOtpRecoveryCodes :one
+SELECT count(*)
+FROM otp_recovery_codes
+WHERE subject = ?;
+
+-- name: DeleteOtpRecoveryCodes :exec
+DELETE
+FROM otp_recovery_codes
+WHERE subject = ?;
+
+-- name: UseOtpRecoveryCode :execrows
+DELETE
+FROM otp_recovery_codes
+WHERE subject = ?
+  AND code_hash = ?;
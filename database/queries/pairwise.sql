@@ -0,0 +1,9 @@
+-- name: AddPairwiseSubject :exec
+INSERT OR IGNORE INTO pairwise_subjects (pairwise, sector, subject)
+VALUES (?, ?, ?);
+
+-- name: GetPairwiseSubjectOwner :one
+SELECT subject
+FROM pairwise_subjects
+WHERE pairwise = ?
+  AND sector = ?;
@@ -0,0 +1,32 @@
+-- name: GetPersonalTokens :many
+SELECT id, name, scope, created_at, expires_at, last_used
+FROM personal_access_tokens
+WHERE subject = ?
+ORDER BY created_at;
+
+-- name: AddPersonalToken :exec
+INSERT INTO personal_access_tokens (id, subject, name, token_hash, scope, created_at, expires_at)
+VALUES (?, ?, ?, ?, ?, ?, ?);
+
+-- name: DeletePersonalToken :exec
+DELETE
+FROM personal_access_tokens
+WHERE id = ?
+  AND subject = ?;
+
+-- name: GetPersonalToken :one
+SELECT personal_access_tokens.id,
+       personal_access_tokens.subject,
+       personal_access_tokens.scope,
+       personal_access_tokens.created_at,
+       personal_access_tokens.expires_at
+FROM personal_access_tokens
+         INNER JOIN users u ON u.subject = personal_access_tokens.subject
+WHERE personal_access_tokens.token_hash = ?
+  AND personal_access_tokens.expires_at > ?
+  AND u.active = 1;
+
+-- name: SetPersonalTokenLastUsed :exec
+UPDATE personal_access_tokens
+SET last_used = ?
+WHERE id = ?;
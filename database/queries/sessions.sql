@@ -0,0 +1,9 @@
+-- name: GetSessionsRevokedAt :one
+SELECT sessions_revoked_at
+FROM users
+WHERE subject = ?;
+
+-- name: RevokeSessions :exec
+UPDATE users
+SET sessions_revoked_at = ?
+WHERE subject = ?;
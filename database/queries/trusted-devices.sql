@@ -0,0 +1,35 @@
+-- name: GetTrustedDevices :many
+SELECT id, name, created_at, expires_at, last_used
+FROM trusted_devices
+WHERE subject = ?
+  AND expires_at > ?
+ORDER BY created_at;
+
+-- name: AddTrustedDevice :exec
+INSERT INTO trusted_devices (id, subject, name, created_at, expires_at)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: DeleteTrustedDevice :exec
+DELETE
+FROM trusted_devices
+WHERE id = ?
+  AND subject = ?;
+
+-- name: DeleteTrustedDevices :exec
+DELETE
+FROM trusted_devices
+WHERE subject = ?;
+
+-- name: DeleteExpiredTrustedDevices :exec
+DELETE
+FROM trusted_devices
+WHERE subject = ?
+  AND expires_at <= ?;
+
+-- name: HasTrustedDevice :one
+SELECT EXISTS(SELECT 1 FROM trusted_devices WHERE id = ? AND subject = ? AND expires_at > ?) == 1 as hasTrustedDevice;
+
+-- name: SetTrustedDeviceLastUsed :exec
+UPDATE trusted_devices
+SET last_used = ?
+WHERE id = ?;
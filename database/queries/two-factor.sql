@@ -0,0 +1,4 @@
+-- name: SetTwoFactorDeadline :exec
+UPDATE users
+SET two_factor_deadline = ?
+WHERE subject = ?;
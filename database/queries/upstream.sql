@@ -0,0 +1,22 @@
+-- name: GetUpstreamIdentity :one
+SELECT subject
+FROM upstream_identities
+WHERE issuer = ?
+  AND upstream_subject = ?;
+
+-- name: GetUpstreamIdentities :many
+SELECT *
+FROM upstream_identities
+WHERE subject = ?
+ORDER BY linked_at;
+
+-- name: AddUpstreamIdentity :exec
+INSERT INTO upstream_identities (issuer, upstream_subject, subject, email, linked_at)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: DeleteUpstreamIdentity :exec
+DELETE
+FROM upstream_identities
+WHERE issuer = ?
+  AND upstream_subject = ?
+  AND subject = ?;
@@ -3,13 +3,20 @@ SELECT count(subject) > 0 AS hasUser
 FROM users;
 
 -- name: addUser :exec
-INSERT INTO users (subject, name, username, password, email, email_verified, role, updated_at, active)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
+INSERT INTO users (subject, name, username, password, email, email_verified, role, updated_at, registered, active,
+                   auth_source)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: addServiceAccount :exec
+INSERT INTO users (subject, name, username, password, email, email_verified, role, updated_at, registered, active,
+                   service_account)
+VALUES (?, ?, ?, ?, '', 0, 0, ?, ?, 1, 1);
 
 -- name: checkLogin :one
-SELECT subject, name, password, EXISTS(SELECT 1 FROM otp WHERE otp.subject = users.subject) == 1 AS has_otp, email, email_verified
+SELECT subject, name, password, EXISTS(SELECT 1 FROM otp WHERE otp.subject = users.subject) == 1 AS has_otp, EXISTS(SELECT 1 FROM webauthn_credentials WHERE webauthn_credentials.subject = users.subject) == 1 AS has_webauthn, email, email_verified, active, auth_source
 FROM users
 WHERE username = ?
+  AND service_account = 0
 LIMIT 1;
 
 -- name: GetUser :one
@@ -18,6 +25,38 @@ FROM users
 WHERE subject = ?
 LIMIT 1;
 
+-- name: GetVerifiedUserByEmail :one
+SELECT subject, name
+FROM users
+WHERE email = ?
+  AND email_verified = 1
+  AND service_account = 0
+  AND auth_source = ''
+LIMIT 1;
+
+-- name: GetLinkableUsersByEmail :many
+SELECT subject
+FROM users
+WHERE email = ?
+  AND email_verified = 1
+  AND active = 1
+  AND service_account = 0
+  AND auth_source = ''
+LIMIT 2;
+
+-- name: GetEmailLoginUser :one
+SELECT subject,
+       name,
+       email,
+       email_verified,
+       active,
+       EXISTS(SELECT 1 FROM otp WHERE otp.subject = users.subject) == 1                                  AS has_otp,
+       EXISTS(SELECT 1 FROM webauthn_credentials WHERE webauthn_credentials.subject = users.subject) == 1 AS has_webauthn
+FROM users
+WHERE (username = ? OR (email = ? AND email_verified = 1))
+  AND service_account = 0
+LIMIT 1;
+
 -- name: GetUserRole :one
 SELECT role
 FROM users
@@ -28,8 +67,14 @@ SELECT name
 FROM users
 WHERE subject = ?;
 
+-- name: rehashUserPassword :exec
+UPDATE users
+SET password = ?
+WHERE subject = ?
+  AND password = ?;
+
 -- name: getUserPassword :one
-SELECT password
+SELECT password, auth_source
 FROM users
 WHERE subject = ?;
 
@@ -49,6 +94,13 @@ SET name      = ?,
     birthdate=?,
     zoneinfo=?,
     locale=?,
+    given_name=?,
+    family_name=?,
+    middle_name=?,
+    nickname=?,
+    gender=?,
+    phone_number=?,
+    address=?,
     updated_at=?
 WHERE subject = ?;
 
@@ -75,3 +127,14 @@ SELECT EXISTS(SELECT 1 FROM otp WHERE subject = ?) == 1 as hasOtp;
 SELECT email
 FROM users
 WHERE subject = ?;
+
+-- name: emailInUse :one
+SELECT EXISTS(SELECT 1 FROM users WHERE email = ? AND subject != ?) == 1 AS in_use;
+
+-- name: syncUserProfile :exec
+UPDATE users
+SET name           = ?,
+    email          = ?,
+    email_verified = ?,
+    updated_at     = ?
+WHERE subject = ?;
@@ -0,0 +1,25 @@
+-- name: GetWebauthnCredentials :many
+SELECT *
+FROM webauthn_credentials
+WHERE subject = ?
+ORDER BY created_at;
+
+-- name: AddWebauthnCredential :exec
+INSERT INTO webauthn_credentials (id, subject, name, credential, created_at)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: UpdateWebauthnCredential :exec
+UPDATE webauthn_credentials
+SET credential = ?,
+    last_used  = ?
+WHERE id = ?
+  AND subject = ?;
+
+-- name: DeleteWebauthnCredential :exec
+DELETE
+FROM webauthn_credentials
+WHERE id = ?
+  AND subject = ?;
+
+-- name: HasWebauthn :one
+SELECT EXISTS(SELECT 1 FROM webauthn_credentials WHERE subject = ?) == 1 AS hasWebauthn;
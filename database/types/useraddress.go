@@ -0,0 +1,61 @@
+package types
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+var (
+	_ sql.Scanner   = &UserAddress{}
+	_ driver.Valuer = &UserAddress{}
+)
+
+// UserAddress contains the fields of the OpenID address claim and is stored as
+// a JSON string
+type UserAddress struct {
+	StreetAddress string `json:"street_address,omitempty"`
+	Locality      string `json:"locality,omitempty"`
+	Region        string `json:"region,omitempty"`
+	PostalCode    string `json:"postal_code,omitempty"`
+	Country       string `json:"country,omitempty"`
+}
+
+func (a *UserAddress) Scan(src any) error {
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported Scan, storing driver.Value type %T into type %T", src, a)
+	}
+	*a = UserAddress{}
+	if s == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(s), a)
+}
+
+func (a UserAddress) Value() (driver.Value, error) {
+	b, err := json.Marshal(a)
+	return string(b), err
+}
+
+// Formatted returns the full address with each part on a separate line
+func (a UserAddress) Formatted() string {
+	var lines []string
+	addLine := func(parts ...string) {
+		var b []string
+		for _, i := range parts {
+			if i != "" {
+				b = append(b, i)
+			}
+		}
+		if len(b) > 0 {
+			lines = append(lines, strings.Join(b, " "))
+		}
+	}
+	addLine(a.StreetAddress)
+	addLine(a.Locality, a.Region, a.PostalCode)
+	addLine(a.Country)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,31 @@
+package types
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUserAddress_Scan(t *testing.T) {
+	var a UserAddress
+	assert.NoError(t, a.Scan(`{"street_address":"1 Main Street","country":"GB"}`))
+	assert.Equal(t, UserAddress{StreetAddress: "1 Main Street", Country: "GB"}, a)
+	assert.NoError(t, a.Scan(""))
+	assert.Equal(t, UserAddress{}, a)
+	assert.Error(t, a.Scan(1))
+}
+
+func TestUserAddress_Value(t *testing.T) {
+	v, err := UserAddress{Locality: "London"}.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"locality":"London"}`, v)
+}
+
+func TestUserAddress_Formatted(t *testing.T) {
+	assert.Equal(t, "", UserAddress{}.Formatted())
+	assert.Equal(t, "1 Main Street\nLondon SW1A 1AA\nGB", UserAddress{
+		StreetAddress: "1 Main Street",
+		Locality:      "London",
+		PostalCode:    "SW1A 1AA",
+		Country:       "GB",
+	}.Formatted())
+}
@@ -7,19 +7,31 @@ import (
 	"github.com/mrmelon54/pronouns"
 	"golang.org/x/text/language"
 	"net/url"
+	"regexp"
+	"strings"
 	"time"
 )
 
 type UserPatch struct {
-	Name      string
-	Picture   string
-	Website   string
-	Pronouns  types.UserPronoun
-	Birthdate date.NullDate
-	ZoneInfo  types.UserZone
-	Locale    types.UserLocale
+	Name        string
+	Picture     string
+	Website     string
+	Pronouns    types.UserPronoun
+	Birthdate   date.NullDate
+	ZoneInfo    types.UserZone
+	Locale      types.UserLocale
+	GivenName   string
+	FamilyName  string
+	MiddleName  string
+	Nickname    string
+	Gender      string
+	PhoneNumber string
+	Address     types.UserAddress
 }
 
+// phoneNumberRegex matches an E.164 phone number
+var phoneNumberRegex = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
 func (u *UserPatch) ParseFromForm(v url.Values) (safeErrs []error) {
 	var err error
 	u.Name = v.Get("name")
@@ -58,5 +70,21 @@ func (u *UserPatch) ParseFromForm(v url.Values) (safeErrs []error) {
 			safeErrs = append(safeErrs, fmt.Errorf("invalid language selected"))
 		}
 	}
+	u.GivenName = v.Get("given_name")
+	u.FamilyName = v.Get("family_name")
+	u.MiddleName = v.Get("middle_name")
+	u.Nickname = v.Get("nickname")
+	u.Gender = v.Get("gender")
+	u.PhoneNumber = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(v.Get("phone_number"))
+	if u.PhoneNumber != "" && !phoneNumberRegex.MatchString(u.PhoneNumber) {
+		safeErrs = append(safeErrs, fmt.Errorf("invalid phone number, use the international format starting with +"))
+	}
+	u.Address = types.UserAddress{
+		StreetAddress: v.Get("address_street"),
+		Locality:      v.Get("address_locality"),
+		Region:        v.Get("address_region"),
+		PostalCode:    v.Get("address_postal_code"),
+		Country:       v.Get("address_country"),
+	}
 	return
 }
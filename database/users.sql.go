@@ -25,6 +25,84 @@ func (q *Queries) DeleteOtp(ctx context.Context, subject string) error {
 	return err
 }
 
+const getEmailLoginUser = `-- name: GetEmailLoginUser :one
+SELECT subject,
+       name,
+       email,
+       email_verified,
+       active,
+       EXISTS(SELECT 1 FROM otp WHERE otp.subject = users.subject) == 1                                  AS has_otp,
+       EXISTS(SELECT 1 FROM webauthn_credentials WHERE webauthn_credentials.subject = users.subject) == 1 AS has_webauthn
+FROM users
+WHERE (username = ? OR (email = ? AND email_verified = 1))
+  AND service_account = 0
+LIMIT 1
+`
+
+type GetEmailLoginUserParams struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+type GetEmailLoginUserRow struct {
+	Subject       string `json:"subject"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Active        bool   `json:"active"`
+	HasOtp        bool   `json:"has_otp"`
+	HasWebauthn   bool   `json:"has_webauthn"`
+}
+
+func (q *Queries) GetEmailLoginUser(ctx context.Context, arg GetEmailLoginUserParams) (GetEmailLoginUserRow, error) {
+	row := q.db.QueryRowContext(ctx, getEmailLoginUser, arg.Username, arg.Email)
+	var i GetEmailLoginUserRow
+	err := row.Scan(
+		&i.Subject,
+		&i.Name,
+		&i.Email,
+		&i.EmailVerified,
+		&i.Active,
+		&i.HasOtp,
+		&i.HasWebauthn,
+	)
+	return i, err
+}
+
+const getLinkableUsersByEmail = `-- name: GetLinkableUsersByEmail :many
+SELECT subject
+FROM users
+WHERE email = ?
+  AND email_verified = 1
+  AND active = 1
+  AND service_account = 0
+  AND auth_source = ''
+LIMIT 2
+`
+
+func (q *Queries) GetLinkableUsersByEmail(ctx context.Context, email string) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getLinkableUsersByEmail, email)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var subject string
+		if err := rows.Scan(&subject); err != nil {
+			return nil, err
+		}
+		items = append(items, subject)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getOtp = `-- name: GetOtp :one
 SELECT secret, digits
 FROM otp
@@ -44,7 +122,7 @@ func (q *Queries) GetOtp(ctx context.Context, subject string) (GetOtpRow, error)
 }
 
 const getUser = `-- name: GetUser :one
-SELECT subject, name, username, password, picture, website, email, email_verified, pronouns, birthdate, zoneinfo, locale, role, updated_at, registered, active
+SELECT subject, name, username, password, picture, website, email, email_verified, pronouns, birthdate, zoneinfo, locale, role, updated_at, registered, active, given_name, family_name, middle_name, nickname, gender, phone_number, address, failed_logins, locked_until, sessions_revoked_at, service_account, two_factor_deadline, auth_source
 FROM users
 WHERE subject = ?
 LIMIT 1
@@ -70,6 +148,19 @@ func (q *Queries) GetUser(ctx context.Context, subject string) (User, error) {
 		&i.UpdatedAt,
 		&i.Registered,
 		&i.Active,
+		&i.GivenName,
+		&i.FamilyName,
+		&i.MiddleName,
+		&i.Nickname,
+		&i.Gender,
+		&i.PhoneNumber,
+		&i.Address,
+		&i.FailedLogins,
+		&i.LockedUntil,
+		&i.SessionsRevokedAt,
+		&i.ServiceAccount,
+		&i.TwoFactorDeadline,
+		&i.AuthSource,
 	)
 	return i, err
 }
@@ -113,6 +204,28 @@ func (q *Queries) GetUserRole(ctx context.Context, subject string) (types.UserRo
 	return role, err
 }
 
+const getVerifiedUserByEmail = `-- name: GetVerifiedUserByEmail :one
+SELECT subject, name
+FROM users
+WHERE email = ?
+  AND email_verified = 1
+  AND service_account = 0
+  AND auth_source = ''
+LIMIT 1
+`
+
+type GetVerifiedUserByEmailRow struct {
+	Subject string `json:"subject"`
+	Name    string `json:"name"`
+}
+
+func (q *Queries) GetVerifiedUserByEmail(ctx context.Context, email string) (GetVerifiedUserByEmailRow, error) {
+	row := q.db.QueryRowContext(ctx, getVerifiedUserByEmail, email)
+	var i GetVerifiedUserByEmailRow
+	err := row.Scan(&i.Subject, &i.Name)
+	return i, err
+}
+
 const hasOtp = `-- name: HasOtp :one
 SELECT EXISTS(SELECT 1 FROM otp WHERE subject = ?) == 1 as hasOtp
 `
@@ -145,20 +258,34 @@ SET name      = ?,
     birthdate=?,
     zoneinfo=?,
     locale=?,
+    given_name=?,
+    family_name=?,
+    middle_name=?,
+    nickname=?,
+    gender=?,
+    phone_number=?,
+    address=?,
     updated_at=?
 WHERE subject = ?
 `
 
 type ModifyUserParams struct {
-	Name      string            `json:"name"`
-	Picture   string            `json:"picture"`
-	Website   string            `json:"website"`
-	Pronouns  types.UserPronoun `json:"pronouns"`
-	Birthdate date.NullDate     `json:"birthdate"`
-	Zoneinfo  types.UserZone    `json:"zoneinfo"`
-	Locale    types.UserLocale  `json:"locale"`
-	UpdatedAt time.Time         `json:"updated_at"`
-	Subject   string            `json:"subject"`
+	Name        string            `json:"name"`
+	Picture     string            `json:"picture"`
+	Website     string            `json:"website"`
+	Pronouns    types.UserPronoun `json:"pronouns"`
+	Birthdate   date.NullDate     `json:"birthdate"`
+	Zoneinfo    types.UserZone    `json:"zoneinfo"`
+	Locale      types.UserLocale  `json:"locale"`
+	GivenName   string            `json:"given_name"`
+	FamilyName  string            `json:"family_name"`
+	MiddleName  string            `json:"middle_name"`
+	Nickname    string            `json:"nickname"`
+	Gender      string            `json:"gender"`
+	PhoneNumber string            `json:"phone_number"`
+	Address     types.UserAddress `json:"address"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	Subject     string            `json:"subject"`
 }
 
 func (q *Queries) ModifyUser(ctx context.Context, arg ModifyUserParams) error {
@@ -170,6 +297,13 @@ func (q *Queries) ModifyUser(ctx context.Context, arg ModifyUserParams) error {
 		arg.Birthdate,
 		arg.Zoneinfo,
 		arg.Locale,
+		arg.GivenName,
+		arg.FamilyName,
+		arg.MiddleName,
+		arg.Nickname,
+		arg.Gender,
+		arg.PhoneNumber,
+		arg.Address,
 		arg.UpdatedAt,
 		arg.Subject,
 	)
@@ -194,9 +328,37 @@ func (q *Queries) SetOtp(ctx context.Context, arg SetOtpParams) error {
 	return err
 }
 
+const addServiceAccount = `-- name: addServiceAccount :exec
+INSERT INTO users (subject, name, username, password, email, email_verified, role, updated_at, registered, active,
+                   service_account)
+VALUES (?, ?, ?, ?, '', 0, 0, ?, ?, 1, 1)
+`
+
+type addServiceAccountParams struct {
+	Subject    string              `json:"subject"`
+	Name       string              `json:"name"`
+	Username   string              `json:"username"`
+	Password   password.HashString `json:"password"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+	Registered time.Time           `json:"registered"`
+}
+
+func (q *Queries) addServiceAccount(ctx context.Context, arg addServiceAccountParams) error {
+	_, err := q.db.ExecContext(ctx, addServiceAccount,
+		arg.Subject,
+		arg.Name,
+		arg.Username,
+		arg.Password,
+		arg.UpdatedAt,
+		arg.Registered,
+	)
+	return err
+}
+
 const addUser = `-- name: addUser :exec
-INSERT INTO users (subject, name, username, password, email, email_verified, role, updated_at, active)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+INSERT INTO users (subject, name, username, password, email, email_verified, role, updated_at, registered, active,
+                   auth_source)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 `
 
 type addUserParams struct {
@@ -208,7 +370,9 @@ type addUserParams struct {
 	EmailVerified bool                `json:"email_verified"`
 	Role          types.UserRole      `json:"role"`
 	UpdatedAt     time.Time           `json:"updated_at"`
+	Registered    time.Time           `json:"registered"`
 	Active        bool                `json:"active"`
+	AuthSource    string              `json:"auth_source"`
 }
 
 func (q *Queries) addUser(ctx context.Context, arg addUserParams) error {
@@ -221,7 +385,9 @@ func (q *Queries) addUser(ctx context.Context, arg addUserParams) error {
 		arg.EmailVerified,
 		arg.Role,
 		arg.UpdatedAt,
+		arg.Registered,
 		arg.Active,
+		arg.AuthSource,
 	)
 	return err
 }
@@ -252,9 +418,10 @@ func (q *Queries) changeUserPassword(ctx context.Context, arg changeUserPassword
 }
 
 const checkLogin = `-- name: checkLogin :one
-SELECT subject, name, password, EXISTS(SELECT 1 FROM otp WHERE otp.subject = users.subject) == 1 AS has_otp, email, email_verified
+SELECT subject, name, password, EXISTS(SELECT 1 FROM otp WHERE otp.subject = users.subject) == 1 AS has_otp, EXISTS(SELECT 1 FROM webauthn_credentials WHERE webauthn_credentials.subject = users.subject) == 1 AS has_webauthn, email, email_verified, active, auth_source
 FROM users
 WHERE username = ?
+  AND service_account = 0
 LIMIT 1
 `
 
@@ -263,8 +430,11 @@ type checkLoginRow struct {
 	Name          string              `json:"name"`
 	Password      password.HashString `json:"password"`
 	HasOtp        bool                `json:"has_otp"`
+	HasWebauthn   bool                `json:"has_webauthn"`
 	Email         string              `json:"email"`
 	EmailVerified bool                `json:"email_verified"`
+	Active        bool                `json:"active"`
+	AuthSource    string              `json:"auth_source"`
 }
 
 func (q *Queries) checkLogin(ctx context.Context, username string) (checkLoginRow, error) {
@@ -275,21 +445,91 @@ func (q *Queries) checkLogin(ctx context.Context, username string) (checkLoginRo
 		&i.Name,
 		&i.Password,
 		&i.HasOtp,
+		&i.HasWebauthn,
 		&i.Email,
 		&i.EmailVerified,
+		&i.Active,
+		&i.AuthSource,
 	)
 	return i, err
 }
 
+const emailInUse = `-- name: emailInUse :one
+SELECT EXISTS(SELECT 1 FROM users WHERE email = ? AND subject != ?) == 1 AS in_use
+`
+
+type emailInUseParams struct {
+	Email   string `json:"email"`
+	Subject string `json:"subject"`
+}
+
+func (q *Queries) emailInUse(ctx context.Context, arg emailInUseParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, emailInUse, arg.Email, arg.Subject)
+	var in_use bool
+	err := row.Scan(&in_use)
+	return in_use, err
+}
+
+const rehashUserPassword = `-- name: rehashUserPassword :exec
+UPDATE users
+SET password = ?
+WHERE subject = ?
+  AND password = ?
+`
+
+type rehashUserPasswordParams struct {
+	Password   password.HashString `json:"password"`
+	Subject    string              `json:"subject"`
+	Password_2 password.HashString `json:"password_2"`
+}
+
+func (q *Queries) rehashUserPassword(ctx context.Context, arg rehashUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, rehashUserPassword, arg.Password, arg.Subject, arg.Password_2)
+	return err
+}
+
 const getUserPassword = `-- name: getUserPassword :one
-SELECT password
+SELECT password, auth_source
 FROM users
 WHERE subject = ?
 `
 
-func (q *Queries) getUserPassword(ctx context.Context, subject string) (password.HashString, error) {
+type getUserPasswordRow struct {
+	Password   password.HashString `json:"password"`
+	AuthSource string              `json:"auth_source"`
+}
+
+func (q *Queries) getUserPassword(ctx context.Context, subject string) (getUserPasswordRow, error) {
 	row := q.db.QueryRowContext(ctx, getUserPassword, subject)
-	var password password.HashString
-	err := row.Scan(&password)
-	return password, err
+	var i getUserPasswordRow
+	err := row.Scan(&i.Password, &i.AuthSource)
+	return i, err
+}
+
+const syncUserProfile = `-- name: syncUserProfile :exec
+UPDATE users
+SET name           = ?,
+    email          = ?,
+    email_verified = ?,
+    updated_at     = ?
+WHERE subject = ?
+`
+
+type syncUserProfileParams struct {
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Subject       string    `json:"subject"`
+}
+
+func (q *Queries) syncUserProfile(ctx context.Context, arg syncUserProfileParams) error {
+	_, err := q.db.ExecContext(ctx, syncUserProfile,
+		arg.Name,
+		arg.Email,
+		arg.EmailVerified,
+		arg.UpdatedAt,
+		arg.Subject,
+	)
+	return err
 }
@@ -0,0 +1,83 @@
+package ldapserver
+
+import (
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"strings"
+)
+
+// Attribute is a named attribute with one or more values
+type Attribute struct {
+	Name   string
+	Values []string
+}
+
+// Entry is a directory entry returned from a search
+type Entry struct {
+	DN         string
+	Attributes []Attribute
+}
+
+// NewEntry creates an entry, attributes without values are left out
+func NewEntry(dn string, attributes ...Attribute) Entry {
+	e := Entry{DN: dn}
+	for _, i := range attributes {
+		if len(i.Values) > 0 {
+			e.Attributes = append(e.Attributes, i)
+		}
+	}
+	return e
+}
+
+// Get returns the values of an attribute, attribute names are case-insensitive
+func (e Entry) Get(name string) []string {
+	for _, i := range e.Attributes {
+		if strings.EqualFold(i.Name, name) {
+			return i.Values
+		}
+	}
+	return nil
+}
+
+// selected returns the attributes included in the search result
+func (e Entry) selected(requested []string) []Attribute {
+	all := len(requested) == 0
+	for _, i := range requested {
+		if i == "*" {
+			all = true
+		}
+	}
+	if all {
+		return e.Attributes
+	}
+	var out []Attribute
+	for _, i := range e.Attributes {
+		for _, j := range requested {
+			if strings.EqualFold(i.Name, j) {
+				out = append(out, i)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (e Entry) packet(requested []string, typesOnly bool) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.DN, "Object Name"))
+	attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for _, i := range e.selected(requested) {
+		a := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		a.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, i.Name, "Type"))
+		vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		if !typesOnly {
+			for _, v := range i.Values {
+				vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Value"))
+			}
+		}
+		a.AppendChild(vals)
+		attrs.AppendChild(a)
+	}
+	p.AppendChild(attrs)
+	return p
+}
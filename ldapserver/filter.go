@@ -0,0 +1,116 @@
+package ldapserver
+
+import (
+	"errors"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"strings"
+)
+
+var errInvalidFilter = errors.New("invalid filter")
+
+// packetString returns the string value of a primitive packet, values with a
+// context-specific tag are not decoded by ReadPacket
+func packetString(p *ber.Packet) string {
+	if s, ok := p.Value.(string); ok {
+		return s
+	}
+	if p.Data == nil {
+		return ""
+	}
+	return p.Data.String()
+}
+
+// matchFilter checks the entry against a search filter, values are compared
+// case-insensitively
+func matchFilter(e Entry, f *ber.Packet) (bool, error) {
+	if f.ClassType != ber.ClassContext {
+		return false, errInvalidFilter
+	}
+	switch f.Tag {
+	case ldap.FilterAnd:
+		for _, i := range f.Children {
+			ok, err := matchFilter(e, i)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case ldap.FilterOr:
+		for _, i := range f.Children {
+			ok, err := matchFilter(e, i)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	case ldap.FilterNot:
+		if len(f.Children) != 1 {
+			return false, errInvalidFilter
+		}
+		ok, err := matchFilter(e, f.Children[0])
+		return !ok, err
+	case ldap.FilterPresent:
+		// every entry has an object class, (objectClass=*) is used to match
+		// any entry
+		name := packetString(f)
+		return strings.EqualFold(name, "objectClass") || len(e.Get(name)) > 0, nil
+	case ldap.FilterEqualityMatch, ldap.FilterApproxMatch, ldap.FilterGreaterOrEqual, ldap.FilterLessOrEqual:
+		if len(f.Children) != 2 {
+			return false, errInvalidFilter
+		}
+		want := strings.ToLower(packetString(f.Children[1]))
+		for _, v := range e.Get(packetString(f.Children[0])) {
+			c := strings.Compare(strings.ToLower(v), want)
+			switch {
+			case f.Tag == ldap.FilterGreaterOrEqual && c >= 0,
+				f.Tag == ldap.FilterLessOrEqual && c <= 0,
+				(f.Tag == ldap.FilterEqualityMatch || f.Tag == ldap.FilterApproxMatch) && c == 0:
+				return true, nil
+			}
+		}
+		return false, nil
+	case ldap.FilterSubstrings:
+		if len(f.Children) != 2 {
+			return false, errInvalidFilter
+		}
+		for _, v := range e.Get(packetString(f.Children[0])) {
+			if matchSubstrings(strings.ToLower(v), f.Children[1].Children) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case ldap.FilterExtensibleMatch:
+		// matching rules are not supported so nothing matches
+		return false, nil
+	}
+	return false, errInvalidFilter
+}
+
+func matchSubstrings(v string, parts []*ber.Packet) bool {
+	for _, i := range parts {
+		s := strings.ToLower(packetString(i))
+		switch i.Tag {
+		case ldap.FilterSubstringsInitial:
+			if !strings.HasPrefix(v, s) {
+				return false
+			}
+			v = v[len(s):]
+		case ldap.FilterSubstringsAny:
+			n := strings.Index(v, s)
+			if n == -1 {
+				return false
+			}
+			v = v[n+len(s):]
+		case ldap.FilterSubstringsFinal:
+			if !strings.HasSuffix(v, s) {
+				return false
+			}
+			v = ""
+		}
+	}
+	return true
+}
@@ -0,0 +1,327 @@
+package ldapserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// maxMessageSize limits the size of each request, the directory is read-only
+	// so requests are small
+	maxMessageSize = 1 << 20
+	idleTimeout    = 5 * time.Minute
+)
+
+// Error is returned from a Handler to send a specific LDAP result code
+type Error struct {
+	Code    uint16
+	Message string
+}
+
+func NewError(code uint16, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ldap result %d: %s", e.Code, e.Message)
+}
+
+// Handler provides the directory served by a Server
+type Handler interface {
+	// Bind checks the password for a simple bind and returns the identity
+	// used for later searches on the connection
+	Bind(ctx context.Context, remote net.Addr, dn, password string) (string, error)
+
+	// Search returns the entries visible to the bound identity, the base,
+	// scope and filter are applied by the Server
+	Search(ctx context.Context, bound string) ([]Entry, error)
+}
+
+// Server is a read-only LDAPv3 server supporting simple bind and search
+type Server struct {
+	Handler   Handler
+	TLSConfig *tls.Config
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	var l net.Listener
+	var err error
+	if s.TLSConfig != nil {
+		l, err = tls.Listen("tcp", addr, s.TLSConfig)
+	} else {
+		l, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	s.conns = make(map[net.Conn]struct{})
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.handleConn(conn)
+	}
+}
+
+// Close stops the listener and closes all connections
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	err := s.listener.Close()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	return err
+}
+
+// readMessage reads a single LDAPMessage, the length is checked before the
+// message is decoded so a large length can't be used to allocate memory
+func readMessage(r *bufio.Reader) (*ber.Packet, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if tag != 0x30 {
+		return nil, errors.New("ldap message must be a sequence")
+	}
+	header := []byte{tag}
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	header = append(header, b)
+	length := int(b)
+	if b&0x80 != 0 {
+		n := int(b & 0x7f)
+		if n == 0 || n > 4 {
+			return nil, errors.New("invalid ldap message length")
+		}
+		length = 0
+		for i := 0; i < n; i++ {
+			b, err = r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			header = append(header, b)
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxMessageSize {
+		return nil, errors.New("ldap message too large")
+	}
+	buf := make([]byte, len(header)+length)
+	copy(buf, header)
+	if _, err := io.ReadFull(r, buf[len(header):]); err != nil {
+		return nil, err
+	}
+	return ber.DecodePacketErr(buf)
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := bufio.NewReader(conn)
+	var bound string
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		p, err := readMessage(r)
+		if err != nil || len(p.Children) < 2 {
+			return
+		}
+		msgId, ok := p.Children[0].Value.(int64)
+		if !ok {
+			return
+		}
+		op := p.Children[1]
+		if op.ClassType != ber.ClassApplication {
+			return
+		}
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			bound, err = s.bind(ctx, conn, msgId, op)
+		case ldap.ApplicationUnbindRequest:
+			return
+		case ldap.ApplicationSearchRequest:
+			err = s.search(ctx, conn, msgId, op, bound)
+		case ldap.ApplicationAbandonRequest:
+			// requests are handled in order so there is nothing to abandon
+		case ldap.ApplicationExtendedRequest:
+			err = writeResult(conn, msgId, ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError, "extended operations are not supported")
+		case ldap.ApplicationModifyRequest, ldap.ApplicationAddRequest, ldap.ApplicationDelRequest, ldap.ApplicationModifyDNRequest, ldap.ApplicationCompareRequest:
+			// each response tag follows the request tag
+			err = writeResult(conn, msgId, op.Tag+1, ldap.LDAPResultUnwillingToPerform, "the directory is read-only")
+		default:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) bind(ctx context.Context, conn net.Conn, msgId int64, op *ber.Packet) (string, error) {
+	if len(op.Children) != 3 {
+		return "", writeResult(conn, msgId, ldap.ApplicationBindResponse, ldap.LDAPResultProtocolError, "invalid bind request")
+	}
+	if v, _ := op.Children[0].Value.(int64); v != 3 {
+		return "", writeResult(conn, msgId, ldap.ApplicationBindResponse, ldap.LDAPResultProtocolError, "only LDAPv3 is supported")
+	}
+	auth := op.Children[2]
+	if auth.ClassType != ber.ClassContext || auth.Tag != 0 {
+		return "", writeResult(conn, msgId, ldap.ApplicationBindResponse, ldap.LDAPResultAuthMethodNotSupported, "only simple bind is supported")
+	}
+	dn := packetString(op.Children[1])
+	pw := packetString(auth)
+
+	switch {
+	case dn == "" && pw == "":
+		// anonymous bind
+		return "", writeResult(conn, msgId, ldap.ApplicationBindResponse, ldap.LDAPResultSuccess, "")
+	case pw == "":
+		return "", writeResult(conn, msgId, ldap.ApplicationBindResponse, ldap.LDAPResultUnwillingToPerform, "unauthenticated bind is not allowed")
+	}
+
+	bound, err := s.Handler.Bind(ctx, conn.RemoteAddr(), dn, pw)
+	if err != nil {
+		return "", writeError(conn, msgId, ldap.ApplicationBindResponse, err)
+	}
+	return bound, writeResult(conn, msgId, ldap.ApplicationBindResponse, ldap.LDAPResultSuccess, "")
+}
+
+func (s *Server) search(ctx context.Context, conn net.Conn, msgId int64, op *ber.Packet, bound string) error {
+	if len(op.Children) != 8 {
+		return writeResult(conn, msgId, ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError, "invalid search request")
+	}
+	base, err := ldap.ParseDN(packetString(op.Children[0]))
+	if err != nil {
+		return writeResult(conn, msgId, ldap.ApplicationSearchResultDone, ldap.LDAPResultInvalidDNSyntax, "invalid base DN")
+	}
+	scope, _ := op.Children[1].Value.(int64)
+	sizeLimit, _ := op.Children[3].Value.(int64)
+	typesOnly, _ := op.Children[5].Value.(bool)
+	filter := op.Children[6]
+	var attributes []string
+	for _, i := range op.Children[7].Children {
+		attributes = append(attributes, packetString(i))
+	}
+
+	entries, err := s.Handler.Search(ctx, bound)
+	if err != nil {
+		return writeError(conn, msgId, ldap.ApplicationSearchResultDone, err)
+	}
+
+	var found bool
+	var sent int64
+	for _, e := range entries {
+		dn, err := ldap.ParseDN(e.DN)
+		if err != nil {
+			continue
+		}
+		if dn.EqualFold(base) {
+			found = true
+		}
+		if !inScope(base, dn, scope) {
+			continue
+		}
+		ok, err := matchFilter(e, filter)
+		if err != nil {
+			return writeResult(conn, msgId, ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError, "invalid filter")
+		}
+		if !ok {
+			continue
+		}
+		if sizeLimit > 0 && sent >= sizeLimit {
+			return writeResult(conn, msgId, ldap.ApplicationSearchResultDone, ldap.LDAPResultSizeLimitExceeded, "")
+		}
+		if err := writePacket(conn, msgId, e.packet(attributes, typesOnly)); err != nil {
+			return err
+		}
+		sent++
+	}
+	if !found {
+		return writeResult(conn, msgId, ldap.ApplicationSearchResultDone, ldap.LDAPResultNoSuchObject, "")
+	}
+	return writeResult(conn, msgId, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess, "")
+}
+
+// inScope checks if the entry is within the search scope, the root DSE is only
+// returned by a base search
+func inScope(base, dn *ldap.DN, scope int64) bool {
+	switch scope {
+	case ldap.ScopeBaseObject:
+		return dn.EqualFold(base)
+	case ldap.ScopeSingleLevel:
+		return len(dn.RDNs) == len(base.RDNs)+1 && base.AncestorOfFold(dn)
+	case ldap.ScopeWholeSubtree:
+		if len(dn.RDNs) == 0 {
+			return false
+		}
+		return dn.EqualFold(base) || base.AncestorOfFold(dn)
+	}
+	return false
+}
+
+func writePacket(w io.Writer, msgId int64, op *ber.Packet) error {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, msgId, "Message ID"))
+	p.AppendChild(op)
+	_, err := w.Write(p.Bytes())
+	return err
+}
+
+func writeResult(w io.Writer, msgId int64, tag ber.Tag, code uint16, message string) error {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, "Diagnostic Message"))
+	return writePacket(w, msgId, op)
+}
+
+// writeError sends the result code from an *Error, other errors are not
+// shown to the client
+func writeError(w io.Writer, msgId int64, tag ber.Tag, err error) error {
+	var e *Error
+	if errors.As(err, &e) {
+		return writeResult(w, msgId, tag, e.Code, e.Message)
+	}
+	return writeResult(w, msgId, tag, ldap.LDAPResultOther, "internal error")
+}
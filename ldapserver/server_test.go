@@ -0,0 +1,121 @@
+package ldapserver
+
+import (
+	"context"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+type testHandler struct{}
+
+func (testHandler) Bind(_ context.Context, _ net.Addr, dn, password string) (string, error) {
+	if dn == "uid=alice,ou=users,dc=example,dc=com" && password == "secret" {
+		return "alice", nil
+	}
+	return "", NewError(ldap.LDAPResultInvalidCredentials, "invalid credentials")
+}
+
+func (testHandler) Search(_ context.Context, bound string) ([]Entry, error) {
+	if bound == "" {
+		return nil, NewError(ldap.LDAPResultInsufficientAccessRights, "bind required")
+	}
+	return []Entry{
+		NewEntry("", Attribute{"namingContexts", []string{"dc=example,dc=com"}}),
+		NewEntry("dc=example,dc=com", Attribute{"objectClass", []string{"top"}}, Attribute{"dc", []string{"example"}}),
+		NewEntry("ou=users,dc=example,dc=com", Attribute{"objectClass", []string{"top", "organizationalUnit"}}, Attribute{"ou", []string{"users"}}),
+		NewEntry("uid=alice,ou=users,dc=example,dc=com",
+			Attribute{"objectClass", []string{"top", "inetOrgPerson"}},
+			Attribute{"uid", []string{"alice"}},
+			Attribute{"cn", []string{"Alice Example"}},
+			Attribute{"mail", []string{"alice@example.com"}},
+		),
+		NewEntry("uid=bob,ou=users,dc=example,dc=com",
+			Attribute{"objectClass", []string{"top", "inetOrgPerson"}},
+			Attribute{"uid", []string{"bob"}},
+			Attribute{"cn", []string{"Bob Example"}},
+			Attribute{"mail", nil},
+		),
+	}, nil
+}
+
+func startTestServer(t *testing.T) *ldap.Conn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := &Server{Handler: testHandler{}}
+	go func() { _ = s.Serve(l) }()
+	t.Cleanup(func() { _ = s.Close() })
+
+	conn, err := ldap.DialURL("ldap://" + l.Addr().String())
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func searchDNs(t *testing.T, conn *ldap.Conn, base string, scope int, filter string) []string {
+	res, err := conn.Search(ldap.NewSearchRequest(base, scope, ldap.NeverDerefAliases, 0, 0, false, filter, nil, nil))
+	assert.NoError(t, err)
+	var dns []string
+	for _, i := range res.Entries {
+		dns = append(dns, i.DN)
+	}
+	return dns
+}
+
+func TestServer_Bind(t *testing.T) {
+	conn := startTestServer(t)
+
+	assert.NoError(t, conn.UnauthenticatedBind(""))
+	assert.True(t, ldap.IsErrorWithCode(conn.Bind("uid=alice,ou=users,dc=example,dc=com", "wrong"), ldap.LDAPResultInvalidCredentials))
+	assert.True(t, ldap.IsErrorWithCode(conn.UnauthenticatedBind("uid=alice,ou=users,dc=example,dc=com"), ldap.LDAPResultUnwillingToPerform))
+
+	// searches need a successful bind
+	_, err := conn.Search(ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(uid=*)", nil, nil))
+	assert.True(t, ldap.IsErrorWithCode(err, ldap.LDAPResultInsufficientAccessRights))
+
+	assert.NoError(t, conn.Bind("uid=alice,ou=users,dc=example,dc=com", "secret"))
+	assert.Len(t, searchDNs(t, conn, "dc=example,dc=com", ldap.ScopeWholeSubtree, "(uid=alice)"), 1)
+
+	// the directory is read-only
+	err = conn.Del(ldap.NewDelRequest("uid=bob,ou=users,dc=example,dc=com", nil))
+	assert.True(t, ldap.IsErrorWithCode(err, ldap.LDAPResultUnwillingToPerform))
+}
+
+func TestServer_Search(t *testing.T) {
+	conn := startTestServer(t)
+	assert.NoError(t, conn.Bind("uid=alice,ou=users,dc=example,dc=com", "secret"))
+
+	alice := "uid=alice,ou=users,dc=example,dc=com"
+	bob := "uid=bob,ou=users,dc=example,dc=com"
+
+	// scopes
+	assert.Equal(t, []string{"dc=example,dc=com"}, searchDNs(t, conn, "dc=example,dc=com", ldap.ScopeBaseObject, "(objectClass=*)"))
+	assert.Equal(t, []string{alice, bob}, searchDNs(t, conn, "ou=users,dc=example,dc=com", ldap.ScopeSingleLevel, "(objectClass=*)"))
+	assert.Equal(t, []string{"ou=users,dc=example,dc=com"}, searchDNs(t, conn, "dc=example,dc=com", ldap.ScopeSingleLevel, "(objectClass=*)"))
+	assert.Equal(t, []string{""}, searchDNs(t, conn, "", ldap.ScopeBaseObject, "(objectClass=*)"))
+
+	// filters
+	base := "dc=example,dc=com"
+	assert.Equal(t, []string{alice}, searchDNs(t, conn, base, ldap.ScopeWholeSubtree, "(UID=ALICE)"))
+	assert.Equal(t, []string{alice}, searchDNs(t, conn, base, ldap.ScopeWholeSubtree, "(mail=*)"))
+	assert.Equal(t, []string{bob}, searchDNs(t, conn, base, ldap.ScopeWholeSubtree, "(&(objectClass=inetOrgPerson)(!(mail=*)))"))
+	assert.Equal(t, []string{alice, bob}, searchDNs(t, conn, base, ldap.ScopeWholeSubtree, "(|(uid=alice)(cn=bob*))"))
+	assert.Equal(t, []string{alice, bob}, searchDNs(t, conn, base, ldap.ScopeWholeSubtree, "(cn=*Exam*le)"))
+	assert.Empty(t, searchDNs(t, conn, base, ldap.ScopeWholeSubtree, "(cn=Alice*Bob)"))
+
+	// attribute selection
+	res, err := conn.Search(ldap.NewSearchRequest(alice, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"MAIL"}, nil))
+	assert.NoError(t, err)
+	assert.Len(t, res.Entries, 1)
+	assert.Len(t, res.Entries[0].Attributes, 1)
+	assert.Equal(t, "alice@example.com", res.Entries[0].GetAttributeValue("mail"))
+
+	// size limit
+	_, err = conn.Search(ldap.NewSearchRequest(base, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 1, 0, false, "(objectClass=inetOrgPerson)", nil, nil))
+	assert.True(t, ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded))
+
+	// missing base
+	_, err = conn.Search(ldap.NewSearchRequest("ou=missing,dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	assert.True(t, ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject))
+}
@@ -4,31 +4,93 @@ import (
 	"strings"
 )
 
+// Config contains the OpenID Connect discovery document, this is a superset of
+// the RFC 8414 authorization server metadata so the same document is served for
+// both
 type Config struct {
-	Issuer                 string   `json:"issuer"`
-	AuthorizationEndpoint  string   `json:"authorization_endpoint"`
-	TokenEndpoint          string   `json:"token_endpoint"`
-	UserInfoEndpoint       string   `json:"userinfo_endpoint"`
-	ResponseTypesSupported []string `json:"response_types_supported"`
-	ScopesSupported        []string `json:"scopes_supported"`
-	ClaimsSupported        []string `json:"claims_supported"`
-	GrantTypesSupported    []string `json:"grant_types_supported"`
+	Issuer                                    string   `json:"issuer"`
+	AuthorizationEndpoint                     string   `json:"authorization_endpoint"`
+	TokenEndpoint                             string   `json:"token_endpoint"`
+	UserInfoEndpoint                          string   `json:"userinfo_endpoint"`
+	JwksUri                                   string   `json:"jwks_uri"`
+	RevocationEndpoint                        string   `json:"revocation_endpoint,omitempty"`
+	IntrospectionEndpoint                     string   `json:"introspection_endpoint,omitempty"`
+	EndSessionEndpoint                        string   `json:"end_session_endpoint,omitempty"`
+	ResponseTypesSupported                    []string `json:"response_types_supported"`
+	ResponseModesSupported                    []string `json:"response_modes_supported"`
+	ScopesSupported                           []string `json:"scopes_supported"`
+	ClaimsSupported                           []string `json:"claims_supported"`
+	GrantTypesSupported                       []string `json:"grant_types_supported"`
+	SubjectTypesSupported                     []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported          []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported         []string `json:"token_endpoint_auth_methods_supported"`
+	RevocationEndpointAuthMethodsSupported    []string `json:"revocation_endpoint_auth_methods_supported,omitempty"`
+	IntrospectionEndpointAuthMethodsSupported []string `json:"introspection_endpoint_auth_methods_supported,omitempty"`
+	CodeChallengeMethodsSupported             []string `json:"code_challenge_methods_supported,omitempty"`
 }
 
-func GenConfig(baseUrl string, scopes, claims []string) Config {
+// Features describes the features enabled in the authorization server, the
+// discovery document is generated from these values
+type Features struct {
+	Scopes               []string
+	Claims               []string
+	ResponseTypes        []string
+	ResponseModes        []string
+	GrantTypes           []string
+	SubjectTypes         []string
+	SigningAlgs          []string
+	ClientAuthMethods    []string
+	CodeChallengeMethods []string
+
+	// optional endpoints
+	Revocation    bool
+	Introspection bool
+	EndSession    bool
+}
+
+// Paths of the endpoints relative to the base url
+const (
+	PathAuthorize     = "authorize"
+	PathToken         = "token"
+	PathUserInfo      = "userinfo"
+	PathJwks          = ".well-known/jwks.json"
+	PathRevocation    = "revoke"
+	PathIntrospection = "introspect"
+	PathEndSession    = "logout"
+)
+
+func GenConfig(baseUrl string, features Features) Config {
 	baseUrlRaw := baseUrl
 	if !strings.HasSuffix(baseUrl, "/") {
 		baseUrl += "/"
 	}
 
-	return Config{
-		Issuer:                 baseUrlRaw,
-		AuthorizationEndpoint:  baseUrl + "authorize",
-		TokenEndpoint:          baseUrl + "token",
-		UserInfoEndpoint:       baseUrl + "userinfo",
-		ResponseTypesSupported: []string{"code"},
-		ScopesSupported:        scopes,
-		ClaimsSupported:        claims,
-		GrantTypesSupported:    []string{"authorization_code", "refresh_token"},
+	c := Config{
+		Issuer:                            baseUrlRaw,
+		AuthorizationEndpoint:             baseUrl + PathAuthorize,
+		TokenEndpoint:                     baseUrl + PathToken,
+		UserInfoEndpoint:                  baseUrl + PathUserInfo,
+		JwksUri:                           baseUrl + PathJwks,
+		ResponseTypesSupported:            features.ResponseTypes,
+		ResponseModesSupported:            features.ResponseModes,
+		ScopesSupported:                   features.Scopes,
+		ClaimsSupported:                   features.Claims,
+		GrantTypesSupported:               features.GrantTypes,
+		SubjectTypesSupported:             features.SubjectTypes,
+		IdTokenSigningAlgValuesSupported:  features.SigningAlgs,
+		TokenEndpointAuthMethodsSupported: features.ClientAuthMethods,
+		CodeChallengeMethodsSupported:     features.CodeChallengeMethods,
+	}
+	if features.Revocation {
+		c.RevocationEndpoint = baseUrl + PathRevocation
+		c.RevocationEndpointAuthMethodsSupported = features.ClientAuthMethods
+	}
+	if features.Introspection {
+		c.IntrospectionEndpoint = baseUrl + PathIntrospection
+		c.IntrospectionEndpointAuthMethodsSupported = features.ClientAuthMethods
+	}
+	if features.EndSession {
+		c.EndSessionEndpoint = baseUrl + PathEndSession
 	}
+	return c
 }
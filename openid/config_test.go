@@ -7,13 +7,40 @@ import (
 
 func TestGenConfig(t *testing.T) {
 	assert.Equal(t, Config{
-		Issuer:                 "https://example.com",
-		AuthorizationEndpoint:  "https://example.com/authorize",
-		TokenEndpoint:          "https://example.com/token",
-		UserInfoEndpoint:       "https://example.com/userinfo",
-		ResponseTypesSupported: []string{"code"},
-		ScopesSupported:        []string{"openid", "email"},
-		ClaimsSupported:        []string{"name", "email", "preferred_username"},
-		GrantTypesSupported:    []string{"authorization_code", "refresh_token"},
-	}, GenConfig("https://example.com", []string{"openid", "email"}, []string{"name", "email", "preferred_username"}))
+		Issuer:                            "https://example.com",
+		AuthorizationEndpoint:             "https://example.com/authorize",
+		TokenEndpoint:                     "https://example.com/token",
+		UserInfoEndpoint:                  "https://example.com/userinfo",
+		JwksUri:                           "https://example.com/.well-known/jwks.json",
+		ResponseTypesSupported:            []string{"code"},
+		ResponseModesSupported:            []string{"query"},
+		ScopesSupported:                   []string{"openid", "email"},
+		ClaimsSupported:                   []string{"name", "email", "preferred_username"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token"},
+		SubjectTypesSupported:             []string{"public"},
+		IdTokenSigningAlgValuesSupported:  []string{"RS512"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic"},
+	}, GenConfig("https://example.com", Features{
+		Scopes:            []string{"openid", "email"},
+		Claims:            []string{"name", "email", "preferred_username"},
+		ResponseTypes:     []string{"code"},
+		ResponseModes:     []string{"query"},
+		GrantTypes:        []string{"authorization_code", "refresh_token"},
+		SubjectTypes:      []string{"public"},
+		SigningAlgs:       []string{"RS512"},
+		ClientAuthMethods: []string{"client_secret_basic"},
+	}))
+
+	c := GenConfig("https://example.com/", Features{
+		ClientAuthMethods: []string{"client_secret_basic"},
+		Revocation:        true,
+		Introspection:     true,
+		EndSession:        true,
+	})
+	assert.Equal(t, "https://example.com/", c.Issuer)
+	assert.Equal(t, "https://example.com/revoke", c.RevocationEndpoint)
+	assert.Equal(t, []string{"client_secret_basic"}, c.RevocationEndpointAuthMethodsSupported)
+	assert.Equal(t, "https://example.com/introspect", c.IntrospectionEndpoint)
+	assert.Equal(t, []string{"client_secret_basic"}, c.IntrospectionEndpointAuthMethodsSupported)
+	assert.Equal(t, "https://example.com/logout", c.EndSessionEndpoint)
 }
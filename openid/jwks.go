@@ -0,0 +1,50 @@
+package openid
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+)
+
+// Jwks contains the JSON Web Key Set served at the jwks_uri
+type Jwks struct {
+	Keys []Jwk `json:"keys"`
+}
+
+// Jwk contains a single RSA public key
+type Jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// GenJwks creates a key set containing the public signing key
+func GenJwks(pub *rsa.PublicKey, alg string) Jwks {
+	n := base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+	e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	return Jwks{Keys: []Jwk{{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: alg,
+		Kid: rsaThumbprint(n, e),
+		N:   n,
+		E:   e,
+	}}}
+}
+
+// rsaThumbprint generates the RFC 7638 thumbprint of an RSA key, the required
+// members are ordered lexicographically
+func rsaThumbprint(n, e string) string {
+	b, _ := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{E: e, Kty: "RSA", N: n})
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,34 @@
+package openid
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+)
+
+// Subject identifier types
+const (
+	SubjectPublic   = "public"
+	SubjectPairwise = "pairwise"
+)
+
+// SectorIdentifier returns the host of the redirect uri, clients with the same
+// sector identifier receive the same pairwise subject
+func SectorIdentifier(redirectUri string) (string, error) {
+	u, err := url.Parse(redirectUri)
+	if err != nil {
+		return "", err
+	}
+	return u.Host, nil
+}
+
+// PairwiseSubject derives a stable subject identifier for the sector, the
+// salt prevents clients from calculating the subject of other sectors
+func PairwiseSubject(salt []byte, sector, subject string) string {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(sector))
+	mac.Write([]byte{0})
+	mac.Write([]byte(subject))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,25 @@
+package openid
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSectorIdentifier(t *testing.T) {
+	s, err := SectorIdentifier("https://example.com/callback")
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", s)
+	s, err = SectorIdentifier("https://example.com:8080/callback?a=b")
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com:8080", s)
+}
+
+func TestPairwiseSubject(t *testing.T) {
+	salt := []byte("salt")
+	a := PairwiseSubject(salt, "a.example.com", "1234")
+	assert.Equal(t, a, PairwiseSubject(salt, "a.example.com", "1234"))
+	assert.Len(t, a, 43)
+	assert.NotEqual(t, a, PairwiseSubject(salt, "b.example.com", "1234"))
+	assert.NotEqual(t, a, PairwiseSubject(salt, "a.example.com", "5678"))
+	assert.NotEqual(t, a, PairwiseSubject([]byte("pepper"), "a.example.com", "1234"))
+}
@@ -0,0 +1,78 @@
+// Helpers for the passkey ceremonies, the server sends and receives binary
+// fields as base64url strings
+function tulipB64ToBuf(s) {
+    s = s.replace(/-/g, "+").replace(/_/g, "/");
+    while (s.length % 4) s += "=";
+    return Uint8Array.from(atob(s), c => c.charCodeAt(0)).buffer;
+}
+
+function tulipBufToB64(b) {
+    let s = "";
+    new Uint8Array(b).forEach(c => s += String.fromCharCode(c));
+    return btoa(s).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+async function tulipPost(url, body) {
+    const res = await fetch(url, {
+        method: "POST",
+        credentials: "same-origin",
+        headers: {"Content-Type": "application/json"},
+        body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    if (!res.ok) throw new Error(await res.text());
+    return res.json();
+}
+
+async function tulipWebauthnRegister(name) {
+    try {
+        const options = await tulipPost("/edit/webauthn/begin");
+        const pk = options.publicKey;
+        pk.challenge = tulipB64ToBuf(pk.challenge);
+        pk.user.id = tulipB64ToBuf(pk.user.id);
+        (pk.excludeCredentials || []).forEach(c => c.id = tulipB64ToBuf(c.id));
+
+        const cred = await navigator.credentials.create({publicKey: pk});
+        const out = await tulipPost("/edit/webauthn/finish?" + new URLSearchParams({name: name}), {
+            id: cred.id,
+            rawId: tulipBufToB64(cred.rawId),
+            type: cred.type,
+            response: {
+                attestationObject: tulipBufToB64(cred.response.attestationObject),
+                clientDataJSON: tulipBufToB64(cred.response.clientDataJSON),
+                transports: cred.response.getTransports ? cred.response.getTransports() : [],
+            },
+        });
+        window.location = out.redirect;
+    } catch (e) {
+        alert("Failed to register passkey: " + e.message);
+    }
+}
+
+async function tulipWebauthnLogin(redirect) {
+    try {
+        const options = await tulipPost("/login/webauthn/begin");
+        const pk = options.publicKey;
+        pk.challenge = tulipB64ToBuf(pk.challenge);
+        (pk.allowCredentials || []).forEach(c => c.id = tulipB64ToBuf(c.id));
+
+        const cred = await navigator.credentials.get({publicKey: pk});
+        const params = new URLSearchParams({redirect: redirect});
+        // the remember me choice from the login form is used for passwordless logins
+        const remember = document.querySelector("input[name=remember_me]");
+        if (remember && remember.checked) params.set("remember_me", "1");
+        const out = await tulipPost("/login/webauthn/finish?" + params, {
+            id: cred.id,
+            rawId: tulipBufToB64(cred.rawId),
+            type: cred.type,
+            response: {
+                authenticatorData: tulipBufToB64(cred.response.authenticatorData),
+                clientDataJSON: tulipBufToB64(cred.response.clientDataJSON),
+                signature: tulipBufToB64(cred.response.signature),
+                userHandle: cred.response.userHandle ? tulipBufToB64(cred.response.userHandle) : null,
+            },
+        });
+        window.location = out.redirect;
+    } catch (e) {
+        alert("Failed to login with passkey: " + e.message);
+    }
+}
@@ -16,7 +16,7 @@ import (
 )
 
 var (
-	//go:embed *.go.html assets/*.css
+	//go:embed *.go.html assets/*.css assets/*.js
 	wwwPages     embed.FS
 	wwwTemplates *template.Template
 	loadOnce     utils.Once[error]
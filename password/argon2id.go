@@ -0,0 +1,111 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strings"
+)
+
+// Argon2idParams are the cost parameters, memory is in KiB
+type Argon2idParams struct {
+	Memory      uint32 `json:"memory"`
+	Iterations  uint32 `json:"iterations"`
+	Parallelism uint8  `json:"parallelism"`
+	SaltLength  uint32 `json:"salt_length"`
+	KeyLength   uint32 `json:"key_length"`
+}
+
+// DefaultArgon2idParams follows the OWASP recommendation
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+const argon2idPrefix = "$argon2id$"
+
+type argon2idHasher struct {
+	params Argon2idParams
+}
+
+var _ Hasher = argon2idHasher{}
+
+func NewArgon2id(params Argon2idParams) Hasher {
+	return argon2idHasher{params: params}
+}
+
+// Hash generates a PHC formatted hash
+func (a argon2idHasher) Hash(password string) (HashString, error) {
+	salt := make([]byte, a.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.params.Iterations, a.params.Memory, a.params.Parallelism, a.params.KeyLength)
+	return HashString(fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version,
+		a.params.Memory, a.params.Iterations, a.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)), nil
+}
+
+func (a argon2idHasher) Verify(hash HashString, password string) error {
+	p, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return err
+	}
+	other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+func (a argon2idHasher) Handles(hash HashString) bool {
+	return strings.HasPrefix(string(hash), argon2idPrefix)
+}
+
+func (a argon2idHasher) NeedsRehash(hash HashString) bool {
+	p, _, _, err := decodeArgon2id(hash)
+	return err != nil || p != a.params
+}
+
+// MaxLength is only limited to keep the policy checks on new passwords fast
+func (a argon2idHasher) MaxLength() int {
+	return 256
+}
+
+// decodeArgon2id parses a hash in the format
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>
+func decodeArgon2id(hash HashString) (p Argon2idParams, salt, key []byte, err error) {
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, nil, nil, ErrUnknownHashFormat
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, nil, nil, ErrUnknownHashFormat
+	}
+	if version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("unsupported argon2id version: %d", version)
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrUnknownHashFormat
+	}
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrUnknownHashFormat
+	}
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, ErrUnknownHashFormat
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	return p, salt, key, nil
+}
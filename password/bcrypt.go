@@ -0,0 +1,41 @@
+package password
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const DefaultBcryptCost = 14
+
+type bcryptHasher struct {
+	cost int
+}
+
+var _ Hasher = bcryptHasher{}
+
+func NewBcrypt(cost int) Hasher {
+	return bcryptHasher{cost: cost}
+}
+
+func (b bcryptHasher) Hash(password string) (HashString, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
+	return HashString(bytes), err
+}
+
+func (b bcryptHasher) Verify(hash HashString, password string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+}
+
+func (b bcryptHasher) Handles(hash HashString) bool {
+	return strings.HasPrefix(string(hash), "$2a$") || strings.HasPrefix(string(hash), "$2b$") || strings.HasPrefix(string(hash), "$2y$")
+}
+
+func (b bcryptHasher) NeedsRehash(hash HashString) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != b.cost
+}
+
+// MaxLength is limited by bcrypt only using the first 72 bytes
+func (b bcryptHasher) MaxLength() int {
+	return 72
+}
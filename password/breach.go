@@ -0,0 +1,93 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+)
+
+// BreachList searches a file of SHA-1 password hashes sorted by hash, each line
+// is in the format used by Have I Been Pwned: <HASH>:<COUNT>
+type BreachList struct {
+	f    *os.File
+	size int64
+}
+
+func OpenBreachList(name string) (*BreachList, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &BreachList{f: f, size: stat.Size()}, nil
+}
+
+func (b *BreachList) Close() error {
+	return b.f.Close()
+}
+
+// Contains uses a binary search to find the password hash without reading
+// the whole file
+func (b *BreachList) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	target := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	lo, hi := int64(0), b.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := b.lineFrom(mid)
+		if err != nil {
+			return false, err
+		}
+		if start >= hi {
+			hi = mid
+			continue
+		}
+		hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+		switch strings.Compare(strings.ToUpper(hash), target) {
+		case 0:
+			return true, nil
+		case -1:
+			lo = start + int64(len(line))
+		default:
+			hi = mid
+		}
+	}
+	return false, nil
+}
+
+// lineFrom returns the first line starting at or after the offset, the line
+// includes the trailing newline
+func (b *BreachList) lineFrom(off int64) (int64, string, error) {
+	start := off
+	if off > 0 {
+		start = off - 1
+	}
+	r := bufio.NewReaderSize(io.NewSectionReader(b.f, start, b.size-start), 128)
+	if off > 0 {
+		// skip the rest of the line containing the byte before the offset
+		skip, err := r.ReadString('\n')
+		if err == io.EOF {
+			return b.size, "", nil
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		start += int64(len(skip))
+	}
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return 0, "", err
+	}
+	if line == "" {
+		return b.size, "", nil
+	}
+	return start, line, nil
+}
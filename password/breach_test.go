@@ -0,0 +1,54 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBreachList_Contains(t *testing.T) {
+	var breached []string
+	var lines []string
+	for i := 0; i < 500; i++ {
+		pw := fmt.Sprintf("breached-%d", i)
+		breached = append(breached, pw)
+		sum := sha1.Sum([]byte(pw))
+		lines = append(lines, fmt.Sprintf("%s:%d\r\n", strings.ToUpper(hex.EncodeToString(sum[:])), i+1))
+	}
+	slices.Sort(lines)
+
+	name := filepath.Join(t.TempDir(), "pwned.txt")
+	assert.NoError(t, os.WriteFile(name, []byte(strings.Join(lines, "")), 0600))
+	b, err := OpenBreachList(name)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = b.Close() })
+
+	for _, pw := range breached {
+		found, err := b.Contains(pw)
+		assert.NoError(t, err)
+		assert.True(t, found, pw)
+	}
+	for i := 0; i < 100; i++ {
+		found, err := b.Contains(fmt.Sprintf("safe-%d", i))
+		assert.NoError(t, err)
+		assert.False(t, found)
+	}
+}
+
+func TestBreachList_Empty(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "pwned.txt")
+	assert.NoError(t, os.WriteFile(name, nil, 0600))
+	b, err := OpenBreachList(name)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = b.Close() })
+
+	found, err := b.Contains("password")
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
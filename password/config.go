@@ -0,0 +1,45 @@
+package password
+
+import "fmt"
+
+// Config selects the algorithm used for new password hashes, zero values use
+// the defaults
+type Config struct {
+	Algorithm  string         `json:"algorithm"`
+	Argon2id   Argon2idParams `json:"argon2id"`
+	BcryptCost int            `json:"bcrypt_cost"`
+}
+
+// Hasher creates the hasher described by the config
+func (c Config) Hasher() (Hasher, error) {
+	switch c.Algorithm {
+	case "", "argon2id":
+		p := DefaultArgon2idParams
+		if c.Argon2id.Memory != 0 {
+			p.Memory = c.Argon2id.Memory
+		}
+		if c.Argon2id.Iterations != 0 {
+			p.Iterations = c.Argon2id.Iterations
+		}
+		if c.Argon2id.Parallelism != 0 {
+			p.Parallelism = c.Argon2id.Parallelism
+		}
+		if c.Argon2id.SaltLength != 0 {
+			p.SaltLength = c.Argon2id.SaltLength
+		}
+		if c.Argon2id.KeyLength != 0 {
+			p.KeyLength = c.Argon2id.KeyLength
+		}
+		return NewArgon2id(p), nil
+	case "bcrypt":
+		cost := c.BcryptCost
+		if cost == 0 {
+			cost = DefaultBcryptCost
+		}
+		if cost < 4 || cost > 31 {
+			return nil, fmt.Errorf("invalid bcrypt cost: %d", cost)
+		}
+		return NewBcrypt(cost), nil
+	}
+	return nil, fmt.Errorf("unknown password hash algorithm: %s", c.Algorithm)
+}
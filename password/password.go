@@ -1,17 +1,88 @@
 package password
 
 import (
+	"errors"
 	"golang.org/x/crypto/bcrypt"
+	"sync"
 )
 
 // HashString is used to represent a string containing a password hash
 type HashString string
 
+// ErrMismatchedHashAndPassword is returned when the password does not match
+// the hash, this is the same error as bcrypt for compatibility
+var ErrMismatchedHashAndPassword = bcrypt.ErrMismatchedHashAndPassword
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// Hasher creates and verifies password hashes for a single algorithm
+type Hasher interface {
+	// Hash generates a new hash of the password
+	Hash(password string) (HashString, error)
+	// Verify returns ErrMismatchedHashAndPassword if the password does not match
+	Verify(hash HashString, password string) error
+	// Handles reports if the hash was created by this algorithm
+	Handles(hash HashString) bool
+	// NeedsRehash reports if the hash uses different parameters
+	NeedsRehash(hash HashString) bool
+	// MaxLength is the maximum password length in bytes
+	MaxLength() int
+}
+
+var (
+	hasherMu      sync.RWMutex
+	defaultHasher Hasher = NewArgon2id(DefaultArgon2idParams)
+)
+
+// SetDefault changes the algorithm used for new hashes, existing hashes can
+// still be verified by any supported algorithm
+func SetDefault(h Hasher) {
+	hasherMu.Lock()
+	defaultHasher = h
+	hasherMu.Unlock()
+}
+
+func getDefault() Hasher {
+	hasherMu.RLock()
+	defer hasherMu.RUnlock()
+	return defaultHasher
+}
+
+// findHasher returns the hasher for an existing hash, the default hasher is
+// preferred so the configured parameters are used
+func findHasher(hash HashString) (Hasher, error) {
+	h := getDefault()
+	if h.Handles(hash) {
+		return h, nil
+	}
+	for _, i := range []Hasher{NewArgon2id(DefaultArgon2idParams), NewBcrypt(DefaultBcryptCost)} {
+		if i.Handles(hash) {
+			return i, nil
+		}
+	}
+	return nil, ErrUnknownHashFormat
+}
+
 func HashPassword(password string) (HashString, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return HashString(bytes), err
+	return getDefault().Hash(password)
 }
 
 func CheckPasswordHash(hash HashString, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	h, err := findHasher(hash)
+	if err != nil {
+		return err
+	}
+	return h.Verify(hash, password)
+}
+
+// NeedsRehash reports if the hash should be replaced by a new hash from the
+// default algorithm
+func NeedsRehash(hash HashString) bool {
+	h := getDefault()
+	return !h.Handles(hash) || h.NeedsRehash(hash)
+}
+
+// MaxLength is the maximum password length supported by the default algorithm
+func MaxLength() int {
+	return getDefault().MaxLength()
 }
@@ -0,0 +1,72 @@
+package password
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var testArgon2idParams = Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2id(t *testing.T) {
+	h := NewArgon2id(testArgon2idParams)
+	hash, err := h.Hash("hunter2")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^\$argon2id\$v=19\$m=64,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`, string(hash))
+	assert.True(t, h.Handles(hash))
+	assert.NoError(t, h.Verify(hash, "hunter2"))
+	assert.ErrorIs(t, h.Verify(hash, "hunter3"), ErrMismatchedHashAndPassword)
+	assert.False(t, h.NeedsRehash(hash))
+	assert.True(t, NewArgon2id(DefaultArgon2idParams).NeedsRehash(hash))
+}
+
+func TestBcrypt(t *testing.T) {
+	h := NewBcrypt(4)
+	hash, err := h.Hash("hunter2")
+	assert.NoError(t, err)
+	assert.True(t, h.Handles(hash))
+	assert.False(t, NewArgon2id(DefaultArgon2idParams).Handles(hash))
+	assert.NoError(t, h.Verify(hash, "hunter2"))
+	assert.ErrorIs(t, h.Verify(hash, "hunter3"), ErrMismatchedHashAndPassword)
+	assert.False(t, h.NeedsRehash(hash))
+	assert.True(t, NewBcrypt(5).NeedsRehash(hash))
+}
+
+func TestCheckPasswordHash(t *testing.T) {
+	SetDefault(NewArgon2id(testArgon2idParams))
+	t.Cleanup(func() { SetDefault(NewArgon2id(DefaultArgon2idParams)) })
+
+	// existing bcrypt hashes are still verified and marked for upgrade
+	oldHash, err := NewBcrypt(4).Hash("hunter2")
+	assert.NoError(t, err)
+	assert.NoError(t, CheckPasswordHash(oldHash, "hunter2"))
+	assert.ErrorIs(t, CheckPasswordHash(oldHash, "hunter3"), ErrMismatchedHashAndPassword)
+	assert.True(t, NeedsRehash(oldHash))
+
+	newHash, err := HashPassword("hunter2")
+	assert.NoError(t, err)
+	assert.NoError(t, CheckPasswordHash(newHash, "hunter2"))
+	assert.False(t, NeedsRehash(newHash))
+
+	assert.ErrorIs(t, CheckPasswordHash("plaintext", "plaintext"), ErrUnknownHashFormat)
+}
+
+func TestConfig_Hasher(t *testing.T) {
+	h, err := Config{}.Hasher()
+	assert.NoError(t, err)
+	assert.Equal(t, NewArgon2id(DefaultArgon2idParams), h)
+
+	h, err = Config{Argon2id: Argon2idParams{Memory: 1024}}.Hasher()
+	assert.NoError(t, err)
+	p := DefaultArgon2idParams
+	p.Memory = 1024
+	assert.Equal(t, NewArgon2id(p), h)
+
+	h, err = Config{Algorithm: "bcrypt"}.Hasher()
+	assert.NoError(t, err)
+	assert.Equal(t, NewBcrypt(DefaultBcryptCost), h)
+
+	_, err = Config{Algorithm: "bcrypt", BcryptCost: 50}.Hasher()
+	assert.Error(t, err)
+	_, err = Config{Algorithm: "md5"}.Hasher()
+	assert.Error(t, err)
+}
@@ -0,0 +1,115 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nbutton23/zxcvbn-go"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	DefaultMinLength = 8
+	DefaultMinScore  = 2
+
+	// strengthCheckLength limits the runes passed to zxcvbn, the time taken
+	// grows quickly with the length and longer passwords are strong enough
+	strengthCheckLength = 100
+)
+
+var (
+	ErrPasswordTooShort         = errors.New("password is too short")
+	ErrPasswordTooWeak          = errors.New("password is too easy to guess")
+	ErrPasswordContainsUserInfo = errors.New("password must not contain your username or email address")
+	ErrPasswordBreached         = errors.New("password has appeared in a known data breach")
+)
+
+// PolicyConfig controls the requirements for new passwords, zero values use
+// the defaults and a negative MinScore disables the strength check
+type PolicyConfig struct {
+	MinLength  int    `json:"min_length"`
+	MinScore   int    `json:"min_score"`
+	BreachList string `json:"breach_list"`
+}
+
+// Policy checks new passwords against the configured requirements
+type Policy struct {
+	minLength int
+	minScore  int
+	breaches  *BreachList
+}
+
+// NewPolicy creates the policy and opens the breach list if configured
+func NewPolicy(conf PolicyConfig) (*Policy, error) {
+	p := &Policy{minLength: conf.MinLength, minScore: conf.MinScore}
+	if p.minLength <= 0 {
+		p.minLength = DefaultMinLength
+	}
+	if p.minScore == 0 {
+		p.minScore = DefaultMinScore
+	}
+	if p.minScore > 4 {
+		return nil, fmt.Errorf("invalid minimum password score: %d", p.minScore)
+	}
+	if conf.BreachList != "" {
+		b, err := OpenBreachList(conf.BreachList)
+		if err != nil {
+			return nil, err
+		}
+		p.breaches = b
+	}
+	return p, nil
+}
+
+// Check validates the password, userInputs contains values such as the
+// username and email address which must not be used in the password
+func (p *Policy) Check(password string, userInputs ...string) error {
+	if utf8.RuneCountInString(password) < p.minLength {
+		return ErrPasswordTooShort
+	}
+
+	lower := strings.ToLower(password)
+	var inputs []string
+	for _, i := range userInputs {
+		i = strings.ToLower(i)
+		if i == "" {
+			continue
+		}
+		inputs = append(inputs, i)
+		// the local part of an email address is checked separately
+		if n := strings.IndexByte(i, '@'); n != -1 {
+			inputs = append(inputs, i[:n])
+		}
+	}
+	for _, i := range inputs {
+		if len(i) >= 3 && strings.Contains(lower, i) {
+			return ErrPasswordContainsUserInfo
+		}
+	}
+
+	if p.minScore > 0 && zxcvbn.PasswordStrength(truncateRunes(password, strengthCheckLength), inputs).Score < p.minScore {
+		return ErrPasswordTooWeak
+	}
+
+	if p.breaches != nil {
+		found, err := p.breaches.Contains(password)
+		if err != nil {
+			return err
+		}
+		if found {
+			return ErrPasswordBreached
+		}
+	}
+	return nil
+}
+
+// truncateRunes returns the first n runes of s
+func truncateRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
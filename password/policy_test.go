@@ -0,0 +1,46 @@
+package password
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	p, err := NewPolicy(PolicyConfig{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.Check("correct horse battery staple", "melon", "melon@example.com"))
+	assert.ErrorIs(t, p.Check("short"), ErrPasswordTooShort)
+	assert.ErrorIs(t, p.Check("password"), ErrPasswordTooWeak)
+	assert.ErrorIs(t, p.Check("12345678"), ErrPasswordTooWeak)
+	assert.ErrorIs(t, p.Check("Melon-horse-battery", "melon"), ErrPasswordContainsUserInfo)
+	assert.ErrorIs(t, p.Check("staple-jmelon21-horse", "x", "jmelon21@example.com"), ErrPasswordContainsUserInfo)
+
+	// the strength check can be disabled
+	p, err = NewPolicy(PolicyConfig{MinScore: -1})
+	assert.NoError(t, err)
+	assert.NoError(t, p.Check("password"))
+
+	_, err = NewPolicy(PolicyConfig{MinScore: 5})
+	assert.Error(t, err)
+}
+
+func TestPolicy_CheckLong(t *testing.T) {
+	p, err := NewPolicy(PolicyConfig{})
+	assert.NoError(t, err)
+
+	// only the start of long passwords is scored
+	start := time.Now()
+	assert.NoError(t, p.Check(strings.Repeat("correct horse battery staple ", 40)))
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.ErrorIs(t, p.Check(strings.Repeat("a", 100)+"correct horse battery staple"), ErrPasswordTooWeak)
+}
+
+func TestTruncateRunes(t *testing.T) {
+	assert.Equal(t, "", truncateRunes("abc", 0))
+	assert.Equal(t, "ab", truncateRunes("abc", 2))
+	assert.Equal(t, "abc", truncateRunes("abc", 5))
+	assert.Equal(t, "éé", truncateRunes("ééé", 2))
+}
@@ -2,10 +2,15 @@ package scope
 
 import (
 	"errors"
+	"sort"
 	"strings"
 )
 
-var ErrInvalidScope = errors.New("Invalid scope")
+var (
+	ErrInvalidScope    = errors.New("Invalid scope")
+	ErrScopeExists     = errors.New("scope already exists")
+	ErrInvalidScopeDef = errors.New("scope requires a name without spaces or commas and a description")
+)
 
 var scopeDescription = map[string]string{
 	"openid":    "Verify your user identity",
@@ -17,6 +22,32 @@ var scopeDescription = map[string]string{
 	"age":       "Access your current age",
 	"zoneinfo":  "Access time zone setting",
 	"locale":    "Access your language setting",
+	"groups":    "Access your group memberships",
+	"address":   "Access your postal address",
+	"phone":     "Access your phone number",
+}
+
+// AddScope registers a custom scope, this must be called before the server is
+// started
+func AddScope(name, description string) error {
+	if name == "" || strings.ContainsAny(name, ", ") || description == "" {
+		return ErrInvalidScopeDef
+	}
+	if _, ok := scopeDescription[name]; ok {
+		return ErrScopeExists
+	}
+	scopeDescription[name] = description
+	return nil
+}
+
+// ScopeNames returns a sorted list of the supported scopes
+func ScopeNames() []string {
+	names := make([]string, 0, len(scopeDescription))
+	for k := range scopeDescription {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func ScopesExist(scope string) bool {
@@ -40,3 +40,32 @@ func TestFancyScopeList(t *testing.T) {
 
 	scopeDescription = desc
 }
+
+func TestScopeNames(t *testing.T) {
+	desc := scopeDescription
+	scopeDescription = map[string]string{
+		"c": "C",
+		"a": "A",
+		"b": "B",
+	}
+
+	assert.Equal(t, []string{"a", "b", "c"}, ScopeNames())
+
+	scopeDescription = desc
+}
+
+func TestAddScope(t *testing.T) {
+	desc := scopeDescription
+	scopeDescription = map[string]string{
+		"a": "A",
+	}
+
+	assert.NoError(t, AddScope("b", "B"))
+	assert.Equal(t, ErrScopeExists, AddScope("a", "Other A"))
+	assert.Equal(t, ErrInvalidScopeDef, AddScope("c d", "CD"))
+	assert.Equal(t, ErrInvalidScopeDef, AddScope("e", ""))
+	assert.True(t, ScopesExist("a b"))
+	assert.Equal(t, []string{"A", "B"}, FancyScopeList("a b"))
+
+	scopeDescription = desc
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/pages"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	appPasswordLength  = 16
+	appPasswordMaxName = 64
+
+	appPasswordScopeLdap        = "ldap"
+	appPasswordScopeForwardAuth = "forward-auth"
+)
+
+// appPasswordScope is a non-interactive login which can accept app passwords,
+// the interactive login form never accepts them
+type appPasswordScope struct {
+	Name        string
+	Description string
+}
+
+var appPasswordScopes = []appPasswordScope{
+	{Name: appPasswordScopeLdap, Description: "LDAP directory bind"},
+	{Name: appPasswordScopeForwardAuth, Description: "Basic authentication for services behind a reverse proxy"},
+}
+
+func validAppPasswordScope(scope string) bool {
+	return slices.ContainsFunc(appPasswordScopes, func(s appPasswordScope) bool {
+		return s.Name == scope
+	})
+}
+
+// generateAppPassword returns a random password in the format
+// xxxx-xxxx-xxxx-xxxx using the same characters as recovery codes
+func generateAppPassword() (string, error) {
+	c, err := randomRecoveryChars(appPasswordLength)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for i := 0; i < len(c); i += 4 {
+		if i > 0 {
+			sb.WriteByte('-')
+		}
+		sb.WriteString(c[i : i+4])
+	}
+	return sb.String(), nil
+}
+
+// hashAppPassword uses the recovery code normalisation so the password can be
+// typed with or without the dashes
+func hashAppPassword(pw string) string {
+	return hashRecoveryCode(pw)
+}
+
+// looksLikeAppPassword is used to skip the database lookup for normal
+// passwords, the same normalisation as hashAppPassword is applied first
+func looksLikeAppPassword(pw string) bool {
+	return isRecoveryChars(normaliseRecoveryCode(pw), appPasswordLength)
+}
+
+// checkAppPassword returns true if the password is an app password for the
+// user which allows the scope, the last used time is updated on success
+func (g *loginGuard) checkAppPassword(ctx context.Context, subject, pw, scope string) (bool, error) {
+	if !looksLikeAppPassword(pw) {
+		return false, nil
+	}
+	var ok bool
+	err := dbTxError(g.db, func(tx *database.Queries) error {
+		row, err := tx.GetAppPassword(ctx, database.GetAppPasswordParams{
+			Subject:      subject,
+			PasswordHash: hashAppPassword(pw),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(strings.Fields(row.Scopes), scope) {
+			return nil
+		}
+		ok = true
+		return tx.SetAppPasswordLastUsed(ctx, database.SetAppPasswordLastUsedParams{
+			LastUsed: sql.NullTime{Time: time.Now(), Valid: true},
+			ID:       row.ID,
+		})
+	})
+	return ok, err
+}
+
+func (h *HttpServer) EditAppPasswordCreatePost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(rw, "400 Bad Request: Failed to parse form", http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(req.Form.Get("name"))
+	if name == "" || len(name) > appPasswordMaxName {
+		http.Error(rw, "400 Bad Request: Invalid app password name", http.StatusBadRequest)
+		return
+	}
+	var scopes []string
+	for _, i := range req.Form["scope"] {
+		if !validAppPasswordScope(i) {
+			http.Error(rw, "400 Bad Request: Invalid app password scope", http.StatusBadRequest)
+			return
+		}
+		if !slices.Contains(scopes, i) {
+			scopes = append(scopes, i)
+		}
+	}
+	if len(scopes) == 0 {
+		http.Error(rw, "400 Bad Request: At least one scope is required", http.StatusBadRequest)
+		return
+	}
+
+	pw, err := generateAppPassword()
+	if err != nil {
+		http.Error(rw, "500 Internal Server Error: Failed to generate app password", http.StatusInternalServerError)
+		return
+	}
+	if h.DbTx(rw, func(tx *database.Queries) error {
+		return tx.AddAppPassword(req.Context(), database.AddAppPasswordParams{
+			ID:           uuid.NewString(),
+			Subject:      auth.Subject,
+			Name:         name,
+			PasswordHash: hashAppPassword(pw),
+			Scopes:       strings.Join(scopes, " "),
+			CreatedAt:    time.Now(),
+		})
+	}) {
+		return
+	}
+
+	pages.RenderPageTemplate(rw, "app-password-created", map[string]any{
+		"ServiceName": h.conf.ServiceName,
+		"Name":        name,
+		"Password":    pw,
+	})
+}
+
+func (h *HttpServer) EditAppPasswordDeletePost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	if h.DbTx(rw, func(tx *database.Queries) error {
+		return tx.DeleteAppPassword(req.Context(), database.DeleteAppPasswordParams{
+			ID:      req.PostFormValue("id"),
+			Subject: auth.Subject,
+		})
+	}) {
+		return
+	}
+	http.Redirect(rw, req, "/edit", http.StatusFound)
+}
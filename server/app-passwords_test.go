@@ -0,0 +1,26 @@
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGenerateAppPassword(t *testing.T) {
+	a, err := generateAppPassword()
+	assert.NoError(t, err)
+	assert.True(t, looksLikeAppPassword(a), a)
+	b, err := generateAppPassword()
+	assert.NoError(t, err)
+	assert.NotEqual(t, a, b)
+
+	assert.False(t, looksLikeAppPassword("hunter2"))
+	assert.False(t, looksLikeAppPassword("abcde-fghjk-mnpqr-st"))
+	assert.True(t, looksLikeAppPassword("ABCD EFGH JKMN PQRS"))
+	assert.True(t, looksLikeAppPassword("abcdefghjkmnpqrs"))
+	assert.Equal(t, hashAppPassword("abcd-efgh-jkmn-pqrs"), hashAppPassword("ABCD EFGH JKMN PQRS"))
+}
+
+func TestValidAppPasswordScope(t *testing.T) {
+	assert.True(t, validAppPasswordScope(appPasswordScopeLdap))
+	assert.False(t, validAppPasswordScope("login"))
+}
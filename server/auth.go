@@ -15,12 +15,31 @@ type UserHandler func(rw http.ResponseWriter, req *http.Request, params httprout
 type UserAuth struct {
 	Subject string
 	NeedOtp bool
+	// Persistent is true if the user chose to stay logged in after closing
+	// the browser
+	Persistent bool
+	// NeedTwoFactorSetup is true if the two-factor policy requires the user
+	// to add an OTP or passkey before continuing
+	NeedTwoFactorSetup bool
+}
+
+// twoFactorSetupPaths can be used while the user is adding a second factor
+var twoFactorSetupPaths = map[string]bool{
+	"/login/two-factor":      true,
+	"/login/two-factor/skip": true,
+	"/edit/otp":              true,
+	"/edit/webauthn/begin":   true,
+	"/edit/webauthn/finish":  true,
+	"/logout":                true,
 }
 
 func (u UserAuth) NextFlowUrl(origin *url.URL) *url.URL {
 	if u.NeedOtp {
 		return PrepareRedirectUrl("/login/otp", origin)
 	}
+	if u.NeedTwoFactorSetup && !twoFactorSetupPaths[origin.Path] {
+		return PrepareRedirectUrl("/login/two-factor", origin)
+	}
 	return nil
 }
 
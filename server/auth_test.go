@@ -17,6 +17,13 @@ func TestUserAuth_NextFlowUrl(t *testing.T) {
 	assert.Equal(t, url.URL{Path: "/login/otp", RawQuery: url.Values{"redirect": {"/hello?a=A"}}.Encode()}, *u.NextFlowUrl(&url.URL{Path: "/hello", RawQuery: url.Values{"a": {"A"}}.Encode()}))
 	u.NeedOtp = false
 	assert.Nil(t, u.NextFlowUrl(&url.URL{}))
+
+	u.NeedTwoFactorSetup = true
+	assert.Equal(t, url.URL{Path: "/login/two-factor", RawQuery: url.Values{"redirect": {"/hello"}}.Encode()}, *u.NextFlowUrl(&url.URL{Path: "/hello"}))
+	assert.Nil(t, u.NextFlowUrl(&url.URL{Path: "/edit/otp"}))
+	assert.Nil(t, u.NextFlowUrl(&url.URL{Path: "/login/two-factor"}))
+	u.NeedOtp = true
+	assert.Equal(t, url.URL{Path: "/login/otp"}, *u.NextFlowUrl(&url.URL{}))
 }
 
 func TestUserAuth_IsGuest(t *testing.T) {
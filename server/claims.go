@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/scope"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// sources of claim values in a ClaimMapping
+const (
+	claimSourceUser   = "user"
+	claimSourceGroups = "groups"
+	claimSourceStatic = "static"
+)
+
+// reservedClaims are set by the token generator and cannot be mapped
+var reservedClaims = []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti", "azp", "mct", "per"}
+
+// ClaimMapping adds a claim to the userinfo response, ID tokens and access
+// tokens when the scope is granted, an empty scope means the claim is always
+// added
+//
+// The user source copies the userinfo claim named by Value, the groups source
+// outputs the group names of the user and the static source outputs Value.
+type ClaimMapping struct {
+	Claim  string `json:"claim"`
+	Scope  string `json:"scope"`
+	Source string `json:"source"`
+	Value  any    `json:"value"`
+}
+
+// claimsLookup returns the extra claims for tokens issued to the client
+type claimsLookup func(ctx context.Context, clientId, userId, scope string) (map[string]any, error)
+
+// validateClaimMappings checks the mappings only reference known scopes and
+// user claims, the user source can't copy reserved claims as the subject
+// would skip the pairwise identifier of the client
+func validateClaimMappings(mappings []ClaimMapping) error {
+	for _, m := range mappings {
+		if m.Claim == "" || slices.Contains(reservedClaims, m.Claim) {
+			return fmt.Errorf("claim mapping: invalid claim name '%s'", m.Claim)
+		}
+		if strings.ContainsAny(m.Scope, ", ") || !scope.ScopesExist(m.Scope) {
+			return fmt.Errorf("claim mapping: unknown scope '%s' for claim '%s'", m.Scope, m.Claim)
+		}
+		switch m.Source {
+		case claimSourceUser:
+			v, ok := m.Value.(string)
+			if !ok || slices.Contains(reservedClaims, v) || !slices.Contains(claimsSupported(nil), v) {
+				return fmt.Errorf("claim mapping: unknown user claim '%v' for claim '%s'", m.Value, m.Claim)
+			}
+		case claimSourceGroups:
+		case claimSourceStatic:
+			if m.Value == nil {
+				return fmt.Errorf("claim mapping: missing static value for claim '%s'", m.Claim)
+			}
+		default:
+			return fmt.Errorf("claim mapping: unknown source '%s' for claim '%s'", m.Source, m.Claim)
+		}
+	}
+	return nil
+}
+
+// mappedClaimNames returns the sorted names of every mapped claim
+func mappedClaimNames(conf Conf) []string {
+	var names []string
+	add := func(mappings []ClaimMapping) {
+		for _, m := range mappings {
+			if !slices.Contains(names, m.Claim) {
+				names = append(names, m.Claim)
+			}
+		}
+	}
+	add(conf.Claims)
+	for _, v := range conf.ClientClaims {
+		add(v)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mapClaims outputs the mapped claims allowed by the scopes, user contains the
+// userinfo claims for every scope, user and groups are nil for tokens without
+// a user
+func mapClaims(mappings []ClaimMapping, scopes map[string]bool, user map[string]any, groups []string) map[string]any {
+	m := make(map[string]any)
+	for _, i := range mappings {
+		if i.Scope != "" && !scopes[i.Scope] {
+			continue
+		}
+		switch i.Source {
+		case claimSourceUser:
+			if slices.Contains(reservedClaims, i.Value.(string)) {
+				continue
+			}
+			if v, ok := user[i.Value.(string)]; ok {
+				m[i.Claim] = v
+			}
+		case claimSourceGroups:
+			if groups != nil {
+				m[i.Claim] = groups
+			}
+		case claimSourceStatic:
+			m[i.Claim] = i.Value
+		}
+	}
+	return m
+}
+
+// allScopes returns a claims map with every scope enabled
+func allScopes() map[string]bool {
+	m := make(map[string]bool)
+	for _, s := range scope.ScopeNames() {
+		m[s] = true
+	}
+	return m
+}
+
+// claimMappings returns the global mappings followed by the mappings for the
+// client
+func (h *HttpServer) claimMappings(clientId string) []ClaimMapping {
+	return slices.Concat(h.conf.Claims, h.conf.ClientClaims[clientId])
+}
+
+// loadUserClaims loads the user and the names of the groups the user is a
+// member of, this includes the parents of nested groups
+func (h *HttpServer) loadUserClaims(ctx context.Context, userId string) (user database.User, groups []string, err error) {
+	err = h.DbTxError(func(tx *database.Queries) (err error) {
+		user, err = tx.GetUser(ctx, userId)
+		if err != nil {
+			return
+		}
+		groups, err = tx.GetUserGroupNames(ctx, userId)
+		return
+	})
+	if groups == nil {
+		groups = []string{}
+	}
+	return
+}
+
+// tokenClaims returns the groups claim and mapped claims for ID tokens and
+// access tokens
+func (h *HttpServer) tokenClaims(ctx context.Context, clientId, userId, scope string) (map[string]any, error) {
+	scopes := ParseClaims(scope)
+	mappings := h.claimMappings(clientId)
+
+	// client credentials tokens have no user
+	if userId == "" {
+		return mapClaims(mappings, scopes, nil, nil), nil
+	}
+
+	user, groups, err := h.loadUserClaims(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]any)
+	if scopes["groups"] {
+		m["groups"] = groups
+	}
+	maps.Copy(m, mapClaims(mappings, scopes, genUserInfoClaims(h.conf.BaseUrl, clientId, user, groups, allScopes()), groups))
+	return m, nil
+}
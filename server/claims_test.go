@@ -0,0 +1,75 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/1f349/mjwt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateClaimMappings(t *testing.T) {
+	assert.NoError(t, validateClaimMappings([]ClaimMapping{
+		{Claim: "mail", Scope: "email", Source: claimSourceUser, Value: "email"},
+		{Claim: "roles", Scope: "groups", Source: claimSourceGroups},
+		{Claim: "tenant", Source: claimSourceStatic, Value: "example"},
+	}))
+	assert.Error(t, validateClaimMappings([]ClaimMapping{{Claim: "sub", Source: claimSourceStatic, Value: "a"}}))
+	assert.Error(t, validateClaimMappings([]ClaimMapping{{Claim: "a", Scope: "missing", Source: claimSourceStatic, Value: "a"}}))
+	assert.Error(t, validateClaimMappings([]ClaimMapping{{Claim: "a", Scope: "email name", Source: claimSourceStatic, Value: "a"}}))
+	assert.Error(t, validateClaimMappings([]ClaimMapping{{Claim: "a", Source: claimSourceUser, Value: "password"}}))
+	assert.Error(t, validateClaimMappings([]ClaimMapping{{Claim: "uid", Source: claimSourceUser, Value: "sub"}}))
+	assert.Error(t, validateClaimMappings([]ClaimMapping{{Claim: "client", Source: claimSourceUser, Value: "aud"}}))
+	assert.Error(t, validateClaimMappings([]ClaimMapping{{Claim: "a", Source: claimSourceStatic}}))
+	assert.Error(t, validateClaimMappings([]ClaimMapping{{Claim: "a", Source: "other"}}))
+}
+
+func TestMapClaims(t *testing.T) {
+	mappings := []ClaimMapping{
+		{Claim: "mail", Scope: "email", Source: claimSourceUser, Value: "email"},
+		{Claim: "roles", Scope: "groups", Source: claimSourceGroups},
+		{Claim: "tenant", Source: claimSourceStatic, Value: "example"},
+	}
+	user := map[string]any{"email": "user@example.com"}
+	groups := []string{"admins"}
+
+	assert.Equal(t, map[string]any{"tenant": "example"}, mapClaims(mappings, map[string]bool{"openid": true}, user, groups))
+	assert.Equal(t, map[string]any{
+		"mail":   "user@example.com",
+		"roles":  groups,
+		"tenant": "example",
+	}, mapClaims(mappings, map[string]bool{"email": true, "groups": true}, user, groups))
+
+	// tokens without a user only contain static claims
+	assert.Equal(t, map[string]any{"tenant": "example"}, mapClaims(mappings, map[string]bool{"email": true, "groups": true}, nil, nil))
+}
+
+func TestTokenClaimsEncoding(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	signer := mjwt.NewMJwtSigner("Test", key)
+
+	decode := func(token string) map[string]any {
+		b, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(b, &m))
+		return m
+	}
+
+	access, err := signer.GenerateJwt("1234", "", jwt.ClaimStrings{"5678"}, time.Minute, AccessTokenClaims{Claims: map[string]any{"tenant": "example"}})
+	assert.NoError(t, err)
+	m := decode(access)
+	assert.Equal(t, "example", m["tenant"])
+	assert.Contains(t, m, "per")
+	assert.Equal(t, "access-token", m["mct"])
+
+	id, err := signer.GenerateJwt("1234", "", jwt.ClaimStrings{"5678"}, time.Minute, IdTokenClaims{"azp": "5678"})
+	assert.NoError(t, err)
+	assert.Equal(t, "5678", decode(id)["azp"])
+}
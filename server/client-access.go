@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/database/types"
+	"net/url"
+	"slices"
+)
+
+// kinds of entries in the client access list
+const (
+	accessKindRole  = "role"
+	accessKindGroup = "group"
+)
+
+var (
+	errInvalidAccessRole  = errors.New("invalid role")
+	errInvalidAccessGroup = errors.New("invalid group")
+)
+
+// userCanAccessClient checks the user is allowed to use the client, clients
+// without an access list are available to every user
+func (h *HttpServer) userCanAccessClient(ctx context.Context, clientId, userId string) (allowed bool, err error) {
+	// client credentials tokens have no user
+	if userId == "" {
+		return true, nil
+	}
+
+	err = h.DbTxError(func(tx *database.Queries) error {
+		access, err := tx.GetClientAccess(ctx, clientId)
+		if err != nil {
+			return err
+		}
+		if len(access) == 0 {
+			allowed = true
+			return nil
+		}
+		role, err := tx.GetUserRole(ctx, userId)
+		if err != nil {
+			return err
+		}
+		groups, err := tx.GetUserGroupSubjects(ctx, userId)
+		if err != nil {
+			return err
+		}
+		allowed = clientAccessAllowed(access, role, groups)
+		return nil
+	})
+	return
+}
+
+// clientAccessAllowed returns true if the role or any of the groups match an
+// entry in the access list
+func clientAccessAllowed(access []database.GetClientAccessRow, role types.UserRole, groups []string) bool {
+	for _, i := range access {
+		switch i.Kind {
+		case accessKindRole:
+			r, err := parseRoleValue(i.Value)
+			if err == nil && r == role {
+				return true
+			}
+		case accessKindGroup:
+			for _, g := range groups {
+				if g == i.Value {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// parseClientAccess reads the allowed roles and groups from the app
+// management form, the groups must be in the list of existing groups
+func parseClientAccess(form url.Values, groups []database.GetAllGroupsRow) ([]database.GetClientAccessRow, error) {
+	var access []database.GetClientAccessRow
+	for _, i := range form["access_role"] {
+		if _, err := parseRoleValue(i); err != nil {
+			return nil, errInvalidAccessRole
+		}
+		access = append(access, database.GetClientAccessRow{Kind: accessKindRole, Value: i})
+	}
+	for _, i := range form["access_group"] {
+		if !slices.ContainsFunc(groups, func(g database.GetAllGroupsRow) bool { return g.Subject == i }) {
+			return nil, errInvalidAccessGroup
+		}
+		access = append(access, database.GetClientAccessRow{Kind: accessKindGroup, Value: i})
+	}
+	return access, nil
+}
+
+// keepGroupAccess adds the groups currently allowed to use the client to the
+// access list, this keeps the groups when the client is edited by a user who
+// can't choose them
+func keepGroupAccess(ctx context.Context, tx *database.Queries, clientId string, access []database.GetClientAccessRow) ([]database.GetClientAccessRow, error) {
+	current, err := tx.GetClientAccess(ctx, clientId)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range current {
+		if i.Kind == accessKindGroup {
+			access = append(access, i)
+		}
+	}
+	return access, nil
+}
+
+// setClientAccess replaces the access list of the client
+func setClientAccess(ctx context.Context, tx *database.Queries, clientId string, access []database.GetClientAccessRow) error {
+	err := tx.ClearClientAccess(ctx, clientId)
+	if err != nil {
+		return err
+	}
+	for _, i := range access {
+		err = tx.AddClientAccess(ctx, database.AddClientAccessParams{
+			ClientSubject: clientId,
+			Kind:          i.Kind,
+			Value:         i.Value,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/database/types"
+	"github.com/1f349/tulip/openid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestClientAccessAllowed(t *testing.T) {
+	access := []database.GetClientAccessRow{
+		{Kind: accessKindRole, Value: "admin"},
+		{Kind: accessKindGroup, Value: "staff"},
+	}
+	assert.True(t, clientAccessAllowed(access, types.RoleAdmin, nil))
+	assert.True(t, clientAccessAllowed(access, types.RoleMember, []string{"other", "staff"}))
+	assert.False(t, clientAccessAllowed(access, types.RoleMember, []string{"other"}))
+	assert.False(t, clientAccessAllowed(access, types.RoleToDelete, nil))
+}
+
+func TestParseClientAccess(t *testing.T) {
+	groups := []database.GetAllGroupsRow{{Subject: "a", Name: "A"}, {Subject: "b", Name: "B"}}
+	access, err := parseClientAccess(url.Values{
+		"access_role":  {"member", "admin"},
+		"access_group": {"a", "b"},
+	}, groups)
+	assert.NoError(t, err)
+	assert.Equal(t, []database.GetClientAccessRow{
+		{Kind: accessKindRole, Value: "member"},
+		{Kind: accessKindRole, Value: "admin"},
+		{Kind: accessKindGroup, Value: "a"},
+		{Kind: accessKindGroup, Value: "b"},
+	}, access)
+
+	_, err = parseClientAccess(url.Values{"access_role": {"owner"}}, groups)
+	assert.ErrorIs(t, err, errInvalidAccessRole)
+	_, err = parseClientAccess(url.Values{"access_group": {"c"}}, groups)
+	assert.ErrorIs(t, err, errInvalidAccessGroup)
+}
+
+func TestHttpServer_ManageAppsPost_AccessGroups(t *testing.T) {
+	h := newTestServer(t)
+	ctx := context.Background()
+	admin := addTestUser(t, h, "admin")
+	require.NoError(t, h.db.UpdateUserRole(ctx, database.UpdateUserRoleParams{Active: true, Role: types.RoleAdmin, Subject: admin}))
+	addTestClient(t, h, "app", admin, openid.SubjectPublic)
+	require.NoError(t, h.db.AddGroup(ctx, database.AddGroupParams{Subject: "staff", Name: "Staff"}))
+
+	postApp := func(subject, client string, form url.Values) *httptest.ResponseRecorder {
+		form.Set("action", "edit")
+		form.Set("subject", client)
+		form.Set("name", client)
+		form.Set("domain", "https://"+client+".example.com/callback")
+		form.Set("active", "1")
+		return postEditForm(h.ManageAppsPost, subject, form)
+	}
+	editPage := func(subject, client string) string {
+		rec := httptest.NewRecorder()
+		h.ManageAppsGet(rec, httptest.NewRequest(http.MethodGet, "/manage/apps?edit="+client, nil), nil, UserAuth{Subject: subject})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+
+	rec := postApp(admin, "app", url.Values{"access_group": {"missing"}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	access, err := h.db.GetClientAccess(ctx, "app")
+	require.NoError(t, err)
+	assert.Empty(t, access)
+
+	rec = postApp(admin, "app", url.Values{"access_group": {"staff"}})
+	assert.Equal(t, http.StatusFound, rec.Code)
+	access, err = h.db.GetClientAccess(ctx, "app")
+	require.NoError(t, err)
+	assert.Equal(t, []database.GetClientAccessRow{{Kind: accessKindGroup, Value: "staff"}}, access)
+
+	// the edit page ticks the allowed groups
+	assert.Contains(t, editPage(admin, "app"), `value="staff" id="field_access_group_staff" checked`)
+
+	// other users can't see or choose the groups
+	alice := addTestUser(t, h, "alice")
+	addTestClient(t, h, "alice-app", alice, openid.SubjectPublic)
+	require.NoError(t, setClientAccess(ctx, h.db, "alice-app", []database.GetClientAccessRow{{Kind: accessKindGroup, Value: "staff"}}))
+	assert.NotContains(t, editPage(alice, "alice-app"), "Staff")
+
+	rec = postApp(alice, "alice-app", url.Values{"access_group": {"staff"}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// editing the app keeps the groups chosen by an admin
+	rec = postApp(alice, "alice-app", url.Values{"access_role": {"admin"}})
+	assert.Equal(t, http.StatusFound, rec.Code)
+	access, err = h.db.GetClientAccess(ctx, "alice-app")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []database.GetClientAccessRow{
+		{Kind: accessKindRole, Value: "admin"},
+		{Kind: accessKindGroup, Value: "staff"},
+	}, access)
+}
@@ -1,12 +1,45 @@
 package server
 
-import "github.com/1f349/tulip/mail"
+import (
+	"github.com/1f349/tulip/authenticator"
+	"github.com/1f349/tulip/mail"
+	"github.com/1f349/tulip/password"
+)
 
 type Conf struct {
-	Listen      string    `json:"listen"`
-	BaseUrl     string    `json:"base_url"`
-	OtpIssuer   string    `json:"otp_issuer"`
-	ServiceName string    `json:"service_name"`
-	Namespace   string    `json:"namespace"`
-	Mail        mail.Mail `json:"mail"`
+	Listen         string                       `json:"listen"`
+	BaseUrl        string                       `json:"base_url"`
+	OtpIssuer      string                       `json:"otp_issuer"`
+	ServiceName    string                       `json:"service_name"`
+	Namespace      string                       `json:"namespace"`
+	Mail           mail.Mail                    `json:"mail"`
+	PairwiseSalt   string                       `json:"pairwise_salt"`
+	Scopes         []ScopeConf                  `json:"scopes"`
+	Claims         []ClaimMapping               `json:"claims"`
+	ClientClaims   map[string][]ClaimMapping    `json:"client_claims"`
+	Lockout        LockoutConf                  `json:"lockout"`
+	Registration   RegistrationConf             `json:"registration"`
+	PasswordHash   password.Config              `json:"password_hash"`
+	PasswordPolicy password.PolicyConfig        `json:"password_policy"`
+	EmailLogin     bool                         `json:"email_login"`
+	Upstreams      []UpstreamConf               `json:"upstreams"`
+	LdapAuth       authenticator.LdapConfig     `json:"ldap_auth"`
+	Ldap           LdapConf                     `json:"ldap"`
+	ForwardAuth    map[string]ForwardAuthPolicy `json:"forward_auth"`
+	Session        SessionConf                  `json:"session"`
+	TwoFactor      TwoFactorConf                `json:"two_factor"`
+}
+
+// ScopeConf defines a custom scope which can be requested by clients
+type ScopeConf struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// authenticators returns the external directories used to check passwords
+func (c Conf) authenticators() []authenticator.Authenticator {
+	if c.LdapAuth.Url == "" {
+		return nil
+	}
+	return []authenticator.Authenticator{authenticator.NewLdap(c.LdapAuth)}
 }
@@ -24,7 +24,12 @@ func (h *HttpServer) DbTx(rw http.ResponseWriter, action func(tx *database.Queri
 
 func (h *HttpServer) DbTxError(action func(tx *database.Queries) error) error {
 	logger.Logger.Helper()
-	err := action(h.db)
+	return dbTxError(h.db, action)
+}
+
+func dbTxError(db *database.Queries, action func(tx *database.Queries) error) error {
+	logger.Logger.Helper()
+	err := action(db)
 	if err != nil {
 		logger.Logger.Warn("Database action error", "err", err)
 		return ErrDatabaseActionFailed
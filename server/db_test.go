@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/1f349/cache"
+	"github.com/1f349/mjwt"
+	"github.com/1f349/tulip"
+	clientStore "github.com/1f349/tulip/client-store"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/database/types"
+	"github.com/1f349/tulip/mail"
+	"github.com/1f349/tulip/mail/templates"
+	"github.com/1f349/tulip/pages"
+	"github.com/1f349/tulip/password"
+	mail2 "github.com/emersion/go-message/mail"
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/manage"
+	"github.com/go-oauth2/oauth2/v4/server"
+	"github.com/go-oauth2/oauth2/v4/store"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestServer returns a server using a migrated database in a temporary
+// directory and an in-memory token store, routes are not registered
+func newTestServer(t *testing.T) *HttpServer {
+	db, err := tulip.InitDB(filepath.Join(t.TempDir(), "tulip.db.sqlite"))
+	require.NoError(t, err)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	sessions, err := newSessionSettings(SessionConf{})
+	require.NoError(t, err)
+	policy, err := password.NewPolicy(password.PolicyConfig{})
+	require.NoError(t, err)
+	require.NoError(t, templates.LoadMailTemplates(""))
+	require.NoError(t, pages.LoadPages(""))
+
+	oauthManager := manage.NewDefaultManager()
+	h := &HttpServer{
+		oauthSrv: server.NewServer(server.NewConfig(), oauthManager),
+		oauthMgr: oauthManager,
+		db:       db,
+		conf: Conf{
+			BaseUrl:     "https://tulip.example.com",
+			ServiceName: "Tulip",
+			// nothing listens on this port so sending emails fails quickly
+			Mail: mail.Mail{
+				Name:   "Tulip",
+				Server: "127.0.0.1:1",
+				From:   mail.FromAddress{Address: &mail2.Address{Address: "tulip@example.com"}},
+			},
+		},
+		signingKey: mjwt.NewMJwtSigner("Test", key),
+
+		mailLinkCache:      cache.New[mailLinkKey, string](),
+		passwordResetLimit: cache.New[string, struct{}](),
+		emailLogins:        cache.New[string, emailLogin](),
+		emailLoginLimit:    cache.New[string, struct{}](),
+
+		policy:   policy,
+		sessions: sessions,
+	}
+	h.guard, err = newLoginGuard(db, h.conf)
+	require.NoError(t, err)
+	oauthManager.MustTokenStorage(store.NewMemoryTokenStore())
+	oauthManager.MapAccessGenerate(NewJWTAccessGenerate(h.signingKey, h.issueClientSubject, h.tokenClaims))
+	oauthManager.MapClientStorage(clientStore.New(db))
+	return h
+}
+
+// addTestUser adds an active user with a verified email address and returns
+// the subject
+func addTestUser(t *testing.T, h *HttpServer, username string) string {
+	subject, err := h.db.AddUser(context.Background(), database.AddUserParams{
+		Name:          username,
+		Username:      username,
+		Password:      "correct horse battery staple",
+		Email:         username + "@example.com",
+		EmailVerified: true,
+		Role:          types.RoleMember,
+		UpdatedAt:     time.Now(),
+		Active:        true,
+	})
+	require.NoError(t, err)
+	return subject
+}
+
+// addTestClient adds an active client owned by the subject, the client ID is
+// used as the secret
+func addTestClient(t *testing.T, h *HttpServer, id, owner, subjectType string) {
+	require.NoError(t, h.db.InsertClientApp(context.Background(), database.InsertClientAppParams{
+		Subject:     id,
+		Name:        id,
+		Secret:      id,
+		Domain:      "https://" + id + ".example.com/callback",
+		Owner:       owner,
+		Active:      true,
+		SubjectType: subjectType,
+	}))
+}
+
+// issueTestToken issues an access token from the client to the user
+func issueTestToken(t *testing.T, h *HttpServer, clientId, userId, scope string) oauth2.TokenInfo {
+	ti, err := h.oauthMgr.GenerateAccessToken(context.Background(), oauth2.PasswordCredentials, &oauth2.TokenGenerateRequest{
+		ClientID:     clientId,
+		ClientSecret: clientId,
+		UserID:       userId,
+		Scope:        scope,
+	})
+	require.NoError(t, err)
+	return ti
+}
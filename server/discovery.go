@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/1f349/tulip/openid"
+	"github.com/1f349/tulip/scope"
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/server"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// clientAuthMethods lists the client authentication methods accepted by
+// authenticateClient and the oauth token endpoint
+var clientAuthMethods = []string{"client_secret_basic", "client_secret_post"}
+
+// genOpenIdConfig generates the discovery document from the features enabled in
+// the oauth server, mappedClaims contains the names of the claims added by claim
+// mappings
+func genOpenIdConfig(baseUrl string, srv *server.Server, mappedClaims []string) openid.Config {
+	return openid.GenConfig(baseUrl, openid.Features{
+		Scopes:               scope.ScopeNames(),
+		Claims:               claimsSupported(mappedClaims),
+		ResponseTypes:        stringList(srv.Config.AllowedResponseTypes),
+		ResponseModes:        []string{"query"},
+		GrantTypes:           stringList(supportedGrantTypes(srv)),
+		SubjectTypes:         []string{openid.SubjectPublic, openid.SubjectPairwise},
+		SigningAlgs:          []string{jwt.SigningMethodRS512.Alg()},
+		ClientAuthMethods:    clientAuthMethods,
+		CodeChallengeMethods: stringList(srv.Config.AllowedCodeChallengeMethods),
+		Revocation:           true,
+		Introspection:        true,
+		EndSession:           true,
+	})
+}
+
+// supportedGrantTypes returns the allowed grant types without the password
+// grant, the default password handler of the oauth server denies every request
+func supportedGrantTypes(srv *server.Server) []oauth2.GrantType {
+	var grants []oauth2.GrantType
+	for _, g := range srv.Config.AllowedGrantTypes {
+		if g != oauth2.PasswordCredentials {
+			grants = append(grants, g)
+		}
+	}
+	return grants
+}
+
+// claimsSupported returns the claims from scopeClaims and the extra claims
+// without duplicates
+func claimsSupported(extra []string) []string {
+	seen := make(map[string]struct{})
+	var claims []string
+	add := func(c string) {
+		if _, ok := seen[c]; ok {
+			return
+		}
+		seen[c] = struct{}{}
+		claims = append(claims, c)
+	}
+	for _, s := range scope.ScopeNames() {
+		for _, c := range scopeClaims[s] {
+			add(c)
+		}
+	}
+	for _, c := range extra {
+		add(c)
+	}
+	return claims
+}
+
+func stringList[T ~string](a []T) []string {
+	b := make([]string, len(a))
+	for i := range a {
+		b[i] = string(a[i])
+	}
+	return b
+}
+
+// serveJson creates a handler which responds with the JSON encoding of v, the
+// encoding is generated once
+func serveJson(v any) (httprouter.Handle, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return func(rw http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write(b)
+	}, nil
+}
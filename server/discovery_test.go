@@ -0,0 +1,108 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/1f349/mjwt"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/openid"
+	"github.com/1f349/tulip/scope"
+	"github.com/hardfinhq/go-date"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiscoveryDocument(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	srv := NewHttpServer(Conf{BaseUrl: "https://example.com/"}, nil, mjwt.NewMJwtSigner("Test", key))
+	r := srv.Handler.(*httprouter.Router)
+
+	fetch := func(p string) openid.Config {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, p, nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		var c openid.Config
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&c))
+		return c
+	}
+	c := fetch("/.well-known/openid-configuration")
+	assert.Equal(t, c, fetch("/.well-known/oauth-authorization-server"))
+	assert.Equal(t, "https://example.com", c.Issuer)
+
+	// every advertised endpoint must be registered in the router
+	for _, i := range []struct {
+		method, endpoint string
+	}{
+		{http.MethodGet, c.AuthorizationEndpoint},
+		{http.MethodPost, c.TokenEndpoint},
+		{http.MethodGet, c.UserInfoEndpoint},
+		{http.MethodGet, c.JwksUri},
+		{http.MethodPost, c.RevocationEndpoint},
+		{http.MethodPost, c.IntrospectionEndpoint},
+		{http.MethodGet, c.EndSessionEndpoint},
+	} {
+		assert.True(t, strings.HasPrefix(i.endpoint, c.Issuer+"/"), i.endpoint)
+		u, err := url.Parse(i.endpoint)
+		assert.NoError(t, err)
+		handle, _, _ := r.Lookup(i.method, u.Path)
+		assert.NotNil(t, handle, "%s %s", i.method, i.endpoint)
+	}
+
+	assert.Equal(t, scope.ScopeNames(), c.ScopesSupported)
+	assert.Equal(t, []string{"code", "token"}, c.ResponseTypesSupported)
+	assert.Equal(t, []string{"authorization_code", "client_credentials", "refresh_token"}, c.GrantTypesSupported)
+	assert.Equal(t, []string{"RS512"}, c.IdTokenSigningAlgValuesSupported)
+
+	// the jwks must contain the signing key
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	var jwks openid.Jwks
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&jwks))
+	assert.Equal(t, openid.GenJwks(&key.PublicKey, "RS512"), jwks)
+}
+
+func TestClaimsSupported(t *testing.T) {
+	user := database.User{
+		Subject:   "1234",
+		Birthdate: date.NullDate{Date: date.Date{Year: 2000, Month: time.January, Day: 1}, Valid: true},
+	}
+	user.Zoneinfo.Location = time.UTC
+
+	// the claims from every scope must be advertised
+	all := make(map[string]bool)
+	for _, s := range scope.ScopeNames() {
+		all[s] = true
+	}
+	supported := claimsSupported(nil)
+	m := genUserInfoClaims("https://example.com", "5678", user, []string{"admins"}, all)
+	assert.Len(t, m, len(supported))
+	for _, c := range supported {
+		assert.Contains(t, m, c)
+	}
+
+	// each scope only outputs the claims listed for it
+	for _, s := range scope.ScopeNames() {
+		m := genUserInfoClaims("https://example.com", "5678", user, nil, map[string]bool{"openid": true, s: true})
+		for k := range m {
+			assert.True(t, contains(scopeClaims["openid"], k) || contains(scopeClaims[s], k), "claim %s from scope %s", k, s)
+		}
+	}
+}
+
+func contains(a []string, s string) bool {
+	for _, i := range a {
+		if i == s {
+			return true
+		}
+	}
+	return false
+}
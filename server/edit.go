@@ -13,6 +13,10 @@ import (
 
 func (h *HttpServer) EditGet(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
 	var user database.User
+	var passkeys []database.WebauthnCredential
+	var identities []database.UpstreamIdentity
+	var appPasswords []database.GetAppPasswordsRow
+	var devices []database.GetTrustedDevicesRow
 
 	if h.DbTx(rw, func(tx *database.Queries) error {
 		var err error
@@ -20,6 +24,25 @@ func (h *HttpServer) EditGet(rw http.ResponseWriter, req *http.Request, _ httpro
 		if err != nil {
 			return fmt.Errorf("failed to read user data: %w", err)
 		}
+		passkeys, err = tx.GetWebauthnCredentials(req.Context(), auth.Subject)
+		if err != nil {
+			return fmt.Errorf("failed to read passkeys: %w", err)
+		}
+		identities, err = tx.GetUpstreamIdentities(req.Context(), auth.Subject)
+		if err != nil {
+			return fmt.Errorf("failed to read linked accounts: %w", err)
+		}
+		appPasswords, err = tx.GetAppPasswords(req.Context(), auth.Subject)
+		if err != nil {
+			return fmt.Errorf("failed to read app passwords: %w", err)
+		}
+		devices, err = tx.GetTrustedDevices(req.Context(), database.GetTrustedDevicesParams{
+			Subject:   auth.Subject,
+			ExpiresAt: time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read trusted devices: %w", err)
+		}
 		return nil
 	}) {
 		return
@@ -41,6 +64,13 @@ func (h *HttpServer) EditGet(rw http.ResponseWriter, req *http.Request, _ httpro
 		"FieldPronoun": user.Pronouns.String(),
 		"ListZoneInfo": lists.ListZoneInfo(),
 		"ListLocale":   lists.ListLocale(),
+		"Passkeys":     passkeys,
+		"Upstreams":    h.conf.Upstreams,
+		"Identities":   identities,
+		"IssuerNames":  h.upstreamNames(),
+		"AppPasswords": appPasswords,
+		"AppScopes":    appPasswordScopes,
+		"Devices":      devices,
 	})
 }
 func (h *HttpServer) EditPost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
@@ -65,15 +95,22 @@ func (h *HttpServer) EditPost(rw http.ResponseWriter, req *http.Request, _ httpr
 		return
 	}
 	m := database.ModifyUserParams{
-		Name:      patch.Name,
-		Picture:   patch.Picture,
-		Website:   patch.Website,
-		Pronouns:  patch.Pronouns,
-		Birthdate: patch.Birthdate,
-		Zoneinfo:  patch.ZoneInfo,
-		Locale:    patch.Locale,
-		UpdatedAt: time.Now(),
-		Subject:   auth.Subject,
+		Name:        patch.Name,
+		Picture:     patch.Picture,
+		Website:     patch.Website,
+		Pronouns:    patch.Pronouns,
+		Birthdate:   patch.Birthdate,
+		Zoneinfo:    patch.ZoneInfo,
+		Locale:      patch.Locale,
+		GivenName:   patch.GivenName,
+		FamilyName:  patch.FamilyName,
+		MiddleName:  patch.MiddleName,
+		Nickname:    patch.Nickname,
+		Gender:      patch.Gender,
+		PhoneNumber: patch.PhoneNumber,
+		Address:     patch.Address,
+		UpdatedAt:   time.Now(),
+		Subject:     auth.Subject,
 	}
 	if h.DbTx(rw, func(tx *database.Queries) error {
 		if err := tx.ModifyUser(req.Context(), m); err != nil {
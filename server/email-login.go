@@ -0,0 +1,247 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/logger"
+	"github.com/1f349/tulip/pages"
+	"github.com/emersion/go-message/mail"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"math/big"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	emailLoginCookie      = "tulip-email-login"
+	emailLoginLifetime    = 10 * time.Minute
+	emailLoginMaxAttempts = 5
+	// emailLoginInterval limits how often an email is sent to each user
+	emailLoginInterval = time.Minute
+)
+
+// emailLogin is a pending passwordless login, the token is sent in the login
+// link and stored in a cookie so the code can be entered instead
+type emailLogin struct {
+	subject  string
+	code     string
+	attempts int
+}
+
+// generateEmailLoginCode returns a random 6-digit code
+func generateEmailLoginCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func (h *HttpServer) LoginEmailPost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	if !h.conf.EmailLogin {
+		http.NotFound(rw, req)
+		return
+	}
+	if !auth.IsGuest() {
+		h.SafeRedirect(rw, req)
+		return
+	}
+
+	un := req.PostFormValue("username")
+	redirect := req.PostFormValue("redirect")
+
+	var userInfo database.GetEmailLoginUserRow
+	var lockout database.GetLoginLockoutBySubjectRow
+	var found bool
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		userInfo, err = tx.GetEmailLoginUser(req.Context(), database.GetEmailLoginUserParams{Username: un, Email: un})
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return
+		}
+		found = true
+		lockout, err = tx.GetLoginLockoutBySubject(req.Context(), userInfo.Subject)
+		return
+	}) {
+		return
+	}
+
+	// the client IP is checked here, the account lockout is checked below so
+	// the response does not reveal if the account exists
+	if h.loginBlocked(rw, req, sql.NullTime{}) {
+		return
+	}
+
+	token := uuid.NewString()
+	accountLocked := lockout.LockedUntil.Valid && time.Now().Before(lockout.LockedUntil.Time)
+	send := found && userInfo.EmailVerified && userInfo.Active && !accountLocked
+	if send {
+		if _, limited := h.emailLoginLimit.Get(userInfo.Subject); limited {
+			// the code from the previous email is kept while a new one can't be sent
+			send = false
+			if cookie, err := req.Cookie(emailLoginCookie); err == nil {
+				if pending, ok := h.emailLogins.Get(cookie.Value); ok && pending.subject == userInfo.Subject {
+					token = cookie.Value
+				}
+			}
+		}
+	}
+	if send {
+		code, err := generateEmailLoginCode()
+		if err != nil {
+			http.Error(rw, "500 Internal Server Error: Failed to generate code", http.StatusInternalServerError)
+			return
+		}
+		h.emailLoginLimit.Set(userInfo.Subject, struct{}{}, time.Now().Add(emailLoginInterval))
+		h.emailLogins.Set(token, emailLogin{subject: userInfo.Subject, code: code}, time.Now().Add(emailLoginLifetime))
+		go h.sendEmailLogin(userInfo, token, code, redirect)
+	}
+
+	http.SetCookie(rw, &http.Cookie{
+		Name:     emailLoginCookie,
+		Value:    token,
+		Path:     "/login/email",
+		Expires:  time.Now().Add(emailLoginLifetime),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	rw.Header().Set("Content-Type", "text/html")
+	rw.WriteHeader(http.StatusOK)
+	pages.RenderPageTemplate(rw, "login-email", map[string]any{
+		"ServiceName": h.conf.ServiceName,
+		"Redirect":    redirect,
+	})
+}
+
+func (h *HttpServer) sendEmailLogin(userInfo database.GetEmailLoginUserRow, token, code, redirect string) {
+	address, err := mail.ParseAddress(userInfo.Email)
+	if err != nil {
+		logger.Logger.Warn("Email login: Failed to parse user email address", "sub", userInfo.Subject, "err", err)
+		return
+	}
+
+	loginUrl := url.URL{Path: "/login/email/" + token}
+	if redirect != "" {
+		loginUrl.RawQuery = url.Values{"redirect": {redirect}}.Encode()
+	}
+	err = h.conf.Mail.SendEmailTemplate("mail-login", "Login", userInfo.Name, address, map[string]any{
+		"LoginUrl": h.conf.BaseUrl + loginUrl.String(),
+		"Code":     code,
+	})
+	if err != nil {
+		logger.Logger.Warn("Email login: Failed to send login email", "err", err)
+	}
+}
+
+// LoginEmailLinkGet asks the user to confirm the login, so links opened by
+// email scanners do not use up the login
+func (h *HttpServer) LoginEmailLinkGet(rw http.ResponseWriter, req *http.Request, params httprouter.Params, auth UserAuth) {
+	if !h.conf.EmailLogin {
+		http.NotFound(rw, req)
+		return
+	}
+	if !auth.IsGuest() {
+		h.SafeRedirect(rw, req)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/html")
+	rw.WriteHeader(http.StatusOK)
+	pages.RenderPageTemplate(rw, "login-email-link", map[string]any{
+		"ServiceName": h.conf.ServiceName,
+		"Token":       params.ByName("token"),
+		"Redirect":    req.URL.Query().Get("redirect"),
+	})
+}
+
+func (h *HttpServer) LoginEmailLinkPost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	if !h.conf.EmailLogin {
+		http.NotFound(rw, req)
+		return
+	}
+	if !auth.IsGuest() {
+		h.SafeRedirect(rw, req)
+		return
+	}
+
+	token := req.PostFormValue("token")
+	login, ok := h.emailLogins.Get(token)
+	if !ok {
+		http.Error(rw, "400 Bad Request: Invalid or expired login link", http.StatusBadRequest)
+		return
+	}
+	h.emailLogins.Delete(token)
+	h.finishExternalLogin(rw, req, login.subject, req.PostFormValue("redirect"))
+}
+
+func (h *HttpServer) LoginEmailCodePost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	if !h.conf.EmailLogin {
+		http.NotFound(rw, req)
+		return
+	}
+	if !auth.IsGuest() {
+		h.SafeRedirect(rw, req)
+		return
+	}
+
+	cookie, err := req.Cookie(emailLoginCookie)
+	if err != nil {
+		http.Error(rw, "400 Bad Request: Invalid or expired login code", http.StatusBadRequest)
+		return
+	}
+	login, expires, ok := h.emailLogins.GetExpires(cookie.Value)
+	if !ok {
+		if h.loginBlocked(rw, req, sql.NullTime{}) {
+			return
+		}
+		h.recordLoginFailure(req, "")
+		http.Error(rw, "400 Bad Request: Invalid or expired login code", http.StatusBadRequest)
+		return
+	}
+
+	var lockout database.GetLoginLockoutBySubjectRow
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		lockout, err = tx.GetLoginLockoutBySubject(req.Context(), login.subject)
+		return
+	}) {
+		return
+	}
+	if h.loginBlocked(rw, req, lockout.LockedUntil) || h.startLoginAttempt(rw, req, login.subject) {
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(login.code), []byte(req.PostFormValue("code"))) != 1 {
+		h.recordLoginFailure(req, login.subject)
+		login.attempts++
+		if login.attempts >= emailLoginMaxAttempts {
+			h.emailLogins.Delete(cookie.Value)
+		} else {
+			h.emailLogins.Set(cookie.Value, login, expires)
+		}
+		http.Error(rw, "400 Bad Request: Invalid or expired login code", http.StatusBadRequest)
+		return
+	}
+
+	// the OTP attempts are counted separately
+	h.guard.releaseAttempt(req.Context(), login.subject)
+	h.emailLogins.Delete(cookie.Value)
+	http.SetCookie(rw, &http.Cookie{
+		Name:     emailLoginCookie,
+		Path:     "/login/email",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	h.finishExternalLogin(rw, req, login.subject, req.PostFormValue("redirect"))
+}
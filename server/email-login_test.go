@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"github.com/1f349/cache"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGenerateEmailLoginCode(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		code, err := generateEmailLoginCode()
+		assert.NoError(t, err)
+		assert.Regexp(t, regexp.MustCompile(`^[0-9]{6}$`), code)
+	}
+}
+
+// postEmailLogin submits the form with the cookie from the previous response
+func postEmailLogin(h *HttpServer, handler func(http.ResponseWriter, *http.Request, httprouter.Params, UserAuth), form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/login/email", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req, nil, UserAuth{})
+	return rec
+}
+
+func emailLoginCookieFrom(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == emailLoginCookie {
+			return c
+		}
+	}
+	t.Fatal("missing email login cookie")
+	return nil
+}
+
+func newEmailLoginTest(t *testing.T) (*HttpServer, *http.Cookie, emailLogin) {
+	h := newTestServer(t)
+	h.conf.EmailLogin = true
+	addTestUser(t, h, "admin")
+
+	rec := postEmailLogin(h, h.LoginEmailPost, url.Values{"username": {"admin"}}, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	cookie := emailLoginCookieFrom(t, rec)
+	login, ok := h.emailLogins.Get(cookie.Value)
+	require.True(t, ok)
+	return h, cookie, login
+}
+
+func TestLoginEmailPost_RateLimited(t *testing.T) {
+	h, cookie, login := newEmailLoginTest(t)
+
+	// the pending login is kept while another email can't be sent
+	rec := postEmailLogin(h, h.LoginEmailPost, url.Values{"username": {"admin"}}, cookie)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, cookie.Value, emailLoginCookieFrom(t, rec).Value)
+	pending, ok := h.emailLogins.Get(cookie.Value)
+	assert.True(t, ok)
+	assert.Equal(t, login.code, pending.code)
+
+	// unknown users still receive a cookie
+	rec = postEmailLogin(h, h.LoginEmailPost, url.Values{"username": {"missing"}}, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	_, ok = h.emailLogins.Get(emailLoginCookieFrom(t, rec).Value)
+	assert.False(t, ok)
+}
+
+func TestLoginEmailCodePost_SingleUse(t *testing.T) {
+	h, cookie, login := newEmailLoginTest(t)
+
+	rec := postEmailLogin(h, h.LoginEmailCodePost, url.Values{"code": {login.code}}, cookie)
+	assert.Equal(t, http.StatusFound, rec.Code)
+	var loggedIn bool
+	for _, c := range rec.Result().Cookies() {
+		loggedIn = loggedIn || c.Name == "tulip-login-access"
+	}
+	assert.True(t, loggedIn)
+
+	rec = postEmailLogin(h, h.LoginEmailCodePost, url.Values{"code": {login.code}}, cookie)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestLoginEmailCodePost_MaxAttempts(t *testing.T) {
+	h, cookie, login := newEmailLoginTest(t)
+	h.guard.limiter.threshold = 100
+	h.guard.limiter.ipThreshold = 100
+	wrong := "000000"
+	if login.code == wrong {
+		wrong = "000001"
+	}
+
+	for i := 0; i < emailLoginMaxAttempts; i++ {
+		// clear the backoff so only the attempt limit applies
+		h.guard.resetFailures(context.Background(), login.subject)
+		h.guard.limiter.ips = cache.New[string, ipFailures]()
+		rec := postEmailLogin(h, h.LoginEmailCodePost, url.Values{"code": {wrong}}, cookie)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+	_, ok := h.emailLogins.Get(cookie.Value)
+	assert.False(t, ok)
+	rec := postEmailLogin(h, h.LoginEmailCodePost, url.Values{"code": {login.code}}, cookie)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestLoginEmailCodePost_Lockout(t *testing.T) {
+	h, cookie, login := newEmailLoginTest(t)
+	h.guard.limiter.threshold = 2
+	wrong := "000000"
+	if login.code == wrong {
+		wrong = "000001"
+	}
+
+	for i := 0; i < 2; i++ {
+		rec := postEmailLogin(h, h.LoginEmailCodePost, url.Values{"code": {wrong}}, cookie)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+	// the correct code is refused while the account is locked
+	rec := postEmailLogin(h, h.LoginEmailCodePost, url.Values{"code": {login.code}}, cookie)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/1f349/tulip/database"
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// ForwardAuthPolicy controls access to a host behind a reverse proxy using
+// the forward-auth endpoint, policies are keyed by the lowercase host name and
+// an empty Groups allows any active user
+//
+// Bearer tokens are only accepted from the listed Clients, or personal access
+// tokens if PersonalTokens is set, and must include Scope when it isn't empty.
+// The login cookies are only sent to the host if session.cookie_domain covers
+// it, this is checked at startup.
+type ForwardAuthPolicy struct {
+	Groups         []string `json:"groups"`
+	Clients        []string `json:"clients"`
+	Scope          string   `json:"scope"`
+	PersonalTokens bool     `json:"personal_tokens"`
+}
+
+// allowsToken returns true if a bearer token can be used for the host
+func (p ForwardAuthPolicy) allowsToken(ti oauth2.TokenInfo) bool {
+	if ti.GetClientID() == "" {
+		if !p.PersonalTokens {
+			return false
+		}
+	} else if !slices.Contains(p.Clients, ti.GetClientID()) {
+		return false
+	}
+	return p.Scope == "" || containsScope(ti.GetScope(), p.Scope)
+}
+
+// cookieDomainCovers returns true if cookies for the domain are sent to the
+// host, an empty domain means host-only cookies for the base URL host
+func cookieDomainCovers(domain, baseHost, host string) bool {
+	host = strings.ToLower(host)
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	if domain == "" {
+		return host == strings.ToLower(baseHost)
+	}
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// checkForwardAuthHosts makes sure each protected host receives the login
+// cookies, otherwise users would be redirected to the login page forever
+func checkForwardAuthHosts(baseUrl, cookieDomain string, policies map[string]ForwardAuthPolicy) error {
+	if len(policies) == 0 {
+		return nil
+	}
+	base, err := url.Parse(baseUrl)
+	if err != nil {
+		return err
+	}
+	for host := range policies {
+		if host != strings.ToLower(host) {
+			return fmt.Errorf("forward-auth host %s must be lowercase", host)
+		}
+		if !cookieDomainCovers(cookieDomain, base.Hostname(), host) {
+			return fmt.Errorf("forward-auth host %s is not covered by the session cookie domain", host)
+		}
+	}
+	return nil
+}
+
+// forwardedUrl returns the URL requested through the reverse proxy, nginx
+// sends X-Original-URL and Traefik sends the X-Forwarded-* headers
+func forwardedUrl(req *http.Request) (*url.URL, error) {
+	if orig := req.Header.Get("X-Original-URL"); orig != "" {
+		u, err := url.Parse(orig)
+		if err != nil {
+			return nil, err
+		}
+		if !u.IsAbs() || u.Host == "" {
+			return nil, errors.New("original url must be absolute")
+		}
+		return u, nil
+	}
+
+	host := req.Header.Get("X-Forwarded-Host")
+	if host == "" {
+		return nil, errors.New("missing forwarded host")
+	}
+	proto := req.Header.Get("X-Forwarded-Proto")
+	if proto == "" {
+		proto = "https"
+	}
+	uri := req.Header.Get("X-Forwarded-Uri")
+	if uri == "" {
+		uri = "/"
+	}
+	u, err := url.Parse(proto + "://" + host + uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host != host {
+		return nil, errors.New("invalid forwarded uri")
+	}
+	return u, nil
+}
+
+// forwardAuthPolicy finds the policy for the host, hosts without a policy are
+// not protected by this server
+func (h *HttpServer) forwardAuthPolicy(u *url.URL) (ForwardAuthPolicy, bool) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ForwardAuthPolicy{}, false
+	}
+	policy, ok := h.conf.ForwardAuth[strings.ToLower(u.Hostname())]
+	return policy, ok
+}
+
+// forwardAuthUser authenticates the request using a bearer token allowed by the
+// policy, a basic auth app password or the login cookies, the returned bool is
+// true if a response has been written
+func (h *HttpServer) forwardAuthUser(rw http.ResponseWriter, req *http.Request, policy ForwardAuthPolicy) (string, bool) {
+	authHeader := req.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(authHeader, "Bearer "):
+		token, err := h.validateBearerToken(req)
+		if err != nil || token.GetUserID() == "" || !policy.allowsToken(token) {
+			rw.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(rw, "401 Unauthorized", http.StatusUnauthorized)
+			return "", true
+		}
+		return token.GetUserID(), false
+	case strings.HasPrefix(authHeader, "Basic "):
+		un, pw, _ := req.BasicAuth()
+		return h.forwardAuthBasic(rw, req, un, pw)
+	}
+
+	var u UserAuth
+	if err := h.readLoginAccessCookie(rw, req, &u); err != nil {
+		return "", errors.Is(err, ErrAuthHttpError)
+	}
+	if u.NeedOtp || u.NeedTwoFactorSetup {
+		return "", false
+	}
+	return u.Subject, false
+}
+
+// forwardAuthBasic only accepts app passwords, the account password is never
+// sent to the services behind the proxy
+func (h *HttpServer) forwardAuthBasic(rw http.ResponseWriter, req *http.Request, un, pw string) (string, bool) {
+	var lockout database.GetLoginLockoutRow
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		lockout, err = tx.GetLoginLockout(req.Context(), un)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return
+	}) {
+		return "", true
+	}
+	if h.loginBlocked(rw, req, lockout.LockedUntil) || h.startLoginAttempt(rw, req, lockout.Subject) {
+		return "", true
+	}
+
+	if lockout.Subject != "" {
+		ok, err := h.guard.checkAppPassword(req.Context(), lockout.Subject, pw, appPasswordScopeForwardAuth)
+		if err != nil {
+			http.Error(rw, "500 Internal Server Error", http.StatusInternalServerError)
+			return "", true
+		}
+		if ok {
+			h.guard.resetFailures(req.Context(), lockout.Subject)
+			return lockout.Subject, false
+		}
+	}
+
+	h.recordLoginFailure(req, lockout.Subject)
+	rw.Header().Set("WWW-Authenticate", `Basic realm="`+h.conf.ServiceName+`"`)
+	http.Error(rw, "401 Unauthorized", http.StatusUnauthorized)
+	return "", true
+}
+
+// ForwardAuth is called by a reverse proxy before each request to a protected
+// host, the user details are returned in headers for the proxy to pass on
+func (h *HttpServer) ForwardAuth(rw http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	orig, err := forwardedUrl(req)
+	if err != nil {
+		http.Error(rw, "400 Bad Request: Invalid forwarded URL", http.StatusBadRequest)
+		return
+	}
+	policy, ok := h.forwardAuthPolicy(orig)
+	if !ok {
+		http.Error(rw, "403 Forbidden: Host is not configured for forward-auth", http.StatusForbidden)
+		return
+	}
+
+	subject, done := h.forwardAuthUser(rw, req, policy)
+	if done {
+		return
+	}
+	if subject == "" {
+		// the redirect endpoint sends the user to the login page first
+		redirectUrl := url.URL{Path: "/forward-auth/redirect", RawQuery: url.Values{"url": {orig.String()}}.Encode()}
+		http.Redirect(rw, req, h.conf.BaseUrl+redirectUrl.String(), http.StatusFound)
+		return
+	}
+
+	user, groups, err := h.loadUserClaims(req.Context(), subject)
+	if err != nil {
+		http.Error(rw, "500 Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !user.Active {
+		http.Error(rw, "403 Forbidden", http.StatusForbidden)
+		return
+	}
+	if len(policy.Groups) > 0 && !slices.ContainsFunc(groups, func(g string) bool { return slices.Contains(policy.Groups, g) }) {
+		http.Error(rw, "403 Forbidden", http.StatusForbidden)
+		return
+	}
+
+	rw.Header().Set("X-Tulip-Subject", user.Subject)
+	rw.Header().Set("X-Tulip-User", user.Username)
+	rw.Header().Set("X-Tulip-Name", user.Name)
+	if user.EmailVerified {
+		rw.Header().Set("X-Tulip-Email", user.Email)
+	}
+	rw.Header().Set("X-Tulip-Groups", strings.Join(groups, ","))
+	rw.WriteHeader(http.StatusOK)
+}
+
+// ForwardAuthRedirectGet sends a logged-in user back to the original URL, only
+// hosts with a forward-auth policy are allowed
+func (h *HttpServer) ForwardAuthRedirectGet(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, _ UserAuth) {
+	u, err := url.Parse(req.URL.Query().Get("url"))
+	if err != nil || u.User != nil {
+		http.Error(rw, "400 Bad Request: Invalid redirect URL", http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.forwardAuthPolicy(u); !ok {
+		http.Error(rw, "400 Bad Request: Invalid redirect URL", http.StatusBadRequest)
+		return
+	}
+	http.Redirect(rw, req, u.String(), http.StatusFound)
+}
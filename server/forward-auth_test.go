@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/openid"
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestForwardedUrl(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/forward-auth", nil)
+	req.Header.Set("X-Forwarded-Proto", "http")
+	req.Header.Set("X-Forwarded-Host", "app.example.com")
+	req.Header.Set("X-Forwarded-Uri", "/page?a=b")
+	u, err := forwardedUrl(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://app.example.com/page?a=b", u.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/forward-auth", nil)
+	req.Header.Set("X-Original-URL", "https://app.example.com/")
+	u, err = forwardedUrl(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://app.example.com/", u.String())
+
+	// the uri can't change the host
+	req = httptest.NewRequest(http.MethodGet, "/forward-auth", nil)
+	req.Header.Set("X-Forwarded-Host", "app.example.com")
+	req.Header.Set("X-Forwarded-Uri", "@evil.example.com/")
+	_, err = forwardedUrl(req)
+	assert.Error(t, err)
+
+	req = httptest.NewRequest(http.MethodGet, "/forward-auth", nil)
+	req.Header.Set("X-Original-URL", "/relative")
+	_, err = forwardedUrl(req)
+	assert.Error(t, err)
+}
+
+func TestHttpServer_ForwardAuth(t *testing.T) {
+	h := &HttpServer{conf: Conf{
+		BaseUrl:     "https://tulip.example.com",
+		ForwardAuth: map[string]ForwardAuthPolicy{"app.example.com": {Groups: []string{"staff"}}},
+	}}
+
+	// unknown hosts are rejected
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/forward-auth", nil)
+	req.Header.Set("X-Original-URL", "https://other.example.com/")
+	h.ForwardAuth(rec, req, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// guests are sent to the login page through the redirect endpoint
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/forward-auth", nil)
+	req.Header.Set("X-Original-URL", "https://App.example.com/page")
+	h.ForwardAuth(rec, req, nil)
+	assert.Equal(t, http.StatusFound, rec.Code)
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, "/forward-auth/redirect", loc.Path)
+	assert.Equal(t, "https://App.example.com/page", loc.Query().Get("url"))
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/forward-auth/redirect?url="+url.QueryEscape("https://app.example.com/page"), nil)
+	h.ForwardAuthRedirectGet(rec, req, nil, UserAuth{Subject: "user"})
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "https://app.example.com/page", rec.Header().Get("Location"))
+
+	for _, i := range []string{"https://evil.example.com/", "javascript:alert(1)", "//app.example.com/"} {
+		rec = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, "/forward-auth/redirect?url="+url.QueryEscape(i), nil)
+		h.ForwardAuthRedirectGet(rec, req, nil, UserAuth{Subject: "user"})
+		assert.Equal(t, http.StatusBadRequest, rec.Code, i)
+	}
+}
+
+func TestForwardAuthPolicy_AllowsToken(t *testing.T) {
+	policy := ForwardAuthPolicy{Clients: []string{"app"}}
+	assert.True(t, policy.allowsToken(&models.Token{ClientID: "app", Scope: "openid"}))
+	assert.False(t, policy.allowsToken(&models.Token{ClientID: "other", Scope: "openid"}))
+	assert.False(t, policy.allowsToken(&models.Token{Scope: "openid"}))
+
+	policy = ForwardAuthPolicy{Clients: []string{"app"}, Scope: "profile", PersonalTokens: true}
+	assert.True(t, policy.allowsToken(&models.Token{ClientID: "app", Scope: "openid profile"}))
+	assert.False(t, policy.allowsToken(&models.Token{ClientID: "app", Scope: "openid"}))
+	assert.True(t, policy.allowsToken(&models.Token{Scope: "profile"}))
+	assert.False(t, policy.allowsToken(&models.Token{Scope: "email"}))
+
+	// an empty policy doesn't accept any bearer tokens
+	assert.False(t, ForwardAuthPolicy{}.allowsToken(&models.Token{ClientID: "app"}))
+}
+
+func TestCheckForwardAuthHosts(t *testing.T) {
+	policies := map[string]ForwardAuthPolicy{"app.example.com": {}}
+	assert.NoError(t, checkForwardAuthHosts("https://tulip.example.com", "example.com", policies))
+	assert.NoError(t, checkForwardAuthHosts("https://tulip.example.com", ".example.com", policies))
+	assert.NoError(t, checkForwardAuthHosts("https://tulip.example.com", "", nil))
+	assert.Error(t, checkForwardAuthHosts("https://tulip.example.com", "", policies))
+	assert.Error(t, checkForwardAuthHosts("https://tulip.example.com", "tulip.example.com", policies))
+	assert.Error(t, checkForwardAuthHosts("https://tulip.example.com", "ample.com", policies))
+	assert.Error(t, checkForwardAuthHosts("https://tulip.example.com", "example.com", map[string]ForwardAuthPolicy{"App.example.com": {}}))
+
+	// host-only cookies only cover the tulip host
+	assert.NoError(t, checkForwardAuthHosts("https://tulip.example.com", "", map[string]ForwardAuthPolicy{"tulip.example.com": {}}))
+}
+
+func TestHttpServer_ForwardAuth_Bearer(t *testing.T) {
+	h := newTestServer(t)
+	subject := addTestUser(t, h, "alice")
+	addTestClient(t, h, "app", subject, openid.SubjectPublic)
+	addTestClient(t, h, "other", subject, openid.SubjectPublic)
+	h.conf.ForwardAuth = map[string]ForwardAuthPolicy{
+		"app.example.com":   {Clients: []string{"app"}, Scope: "profile"},
+		"token.example.com": {PersonalTokens: true},
+	}
+
+	pat := personalTokenPrefix + "test"
+	require.NoError(t, h.db.AddPersonalToken(context.Background(), database.AddPersonalTokenParams{
+		ID:        "pat",
+		Subject:   subject,
+		Name:      "test",
+		TokenHash: hashPersonalToken(pat),
+		Scope:     "profile",
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}))
+
+	forwardAuth := func(host, token string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/forward-auth", nil)
+		req.Header.Set("X-Original-URL", "https://"+host+"/")
+		req.Header.Set("Authorization", "Bearer "+token)
+		h.ForwardAuth(rec, req, nil)
+		return rec
+	}
+
+	rec := forwardAuth("app.example.com", issueTestToken(t, h, "app", subject, "openid profile").GetAccess())
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, subject, rec.Header().Get("X-Tulip-Subject"))
+
+	// tokens from other clients, without the scope or personal tokens are rejected
+	assert.Equal(t, http.StatusUnauthorized, forwardAuth("app.example.com", issueTestToken(t, h, "other", subject, "openid profile").GetAccess()).Code)
+	assert.Equal(t, http.StatusUnauthorized, forwardAuth("app.example.com", issueTestToken(t, h, "app", subject, "openid").GetAccess()).Code)
+	assert.Equal(t, http.StatusUnauthorized, forwardAuth("app.example.com", pat).Code)
+
+	assert.Equal(t, http.StatusOK, forwardAuth("token.example.com", pat).Code)
+	assert.Equal(t, http.StatusUnauthorized, forwardAuth("token.example.com", issueTestToken(t, h, "app", subject, "openid profile").GetAccess()).Code)
+}
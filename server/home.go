@@ -25,7 +25,8 @@ func (h *HttpServer) Home(rw http.ResponseWriter, req *http.Request, _ httproute
 
 	if auth.IsGuest() {
 		pages.RenderPageTemplate(rw, "index-guest", map[string]any{
-			"ServiceName": h.conf.ServiceName,
+			"ServiceName":     h.conf.ServiceName,
+			"RegisterEnabled": h.conf.Registration.Enabled,
 		})
 		return
 	}
@@ -33,6 +34,7 @@ func (h *HttpServer) Home(rw http.ResponseWriter, req *http.Request, _ httproute
 	var userWithName string
 	var userRole types.UserRole
 	var hasTwoFactor bool
+	var recoveryCodes int64
 	if h.DbTx(rw, func(tx *database.Queries) (err error) {
 		userWithName, err = tx.GetUserDisplayName(req.Context(), auth.Subject)
 		if err != nil {
@@ -42,6 +44,10 @@ func (h *HttpServer) Home(rw http.ResponseWriter, req *http.Request, _ httproute
 		if err != nil {
 			return fmt.Errorf("failed to get user two factor state: %w", err)
 		}
+		recoveryCodes, err = tx.CountOtpRecoveryCodes(req.Context(), auth.Subject)
+		if err != nil {
+			return fmt.Errorf("failed to count recovery codes: %w", err)
+		}
 		userRole, err = tx.GetUserRole(req.Context(), auth.Subject)
 		if err != nil {
 			return fmt.Errorf("failed to get user role: %w", err)
@@ -51,11 +57,12 @@ func (h *HttpServer) Home(rw http.ResponseWriter, req *http.Request, _ httproute
 		return
 	}
 	pages.RenderPageTemplate(rw, "index", map[string]any{
-		"ServiceName": h.conf.ServiceName,
-		"Auth":        auth,
-		"User":        database.User{Subject: auth.Subject, Name: userWithName, Role: userRole},
-		"Nonce":       lNonce,
-		"OtpEnabled":  hasTwoFactor,
-		"IsAdmin":     userRole == types.RoleAdmin,
+		"ServiceName":   h.conf.ServiceName,
+		"Auth":          auth,
+		"User":          database.User{Subject: auth.Subject, Name: userWithName, Role: userRole},
+		"Nonce":         lNonce,
+		"OtpEnabled":    hasTwoFactor,
+		"RecoveryCodes": recoveryCodes,
+		"IsAdmin":       userRole == types.RoleAdmin,
 	})
 }
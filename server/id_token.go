@@ -3,18 +3,18 @@ package server
 import (
 	"context"
 	"github.com/1f349/mjwt"
-	"github.com/1f349/tulip/database"
 	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/errors"
 	"github.com/go-oauth2/oauth2/v4/server"
 	"github.com/golang-jwt/jwt/v4"
 	"strings"
 )
 
-func addIdTokenSupport(srv *server.Server, db *database.Queries, key mjwt.Signer) {
+func addIdTokenSupport(srv *server.Server, key mjwt.Signer, subject subjectMapper, claims claimsLookup) {
 	srv.SetExtensionFieldsHandler(func(ti oauth2.TokenInfo) (fieldsValue map[string]interface{}) {
 		scope := ti.GetScope()
 		if containsScope(scope, "openid") {
-			idToken, err := generateIDToken(ti, db, key)
+			idToken, err := generateIDToken(ti, key, subject, claims)
 			if err != nil {
 				return
 			}
@@ -25,19 +25,31 @@ func addIdTokenSupport(srv *server.Server, db *database.Queries, key mjwt.Signer
 	})
 }
 
-// IdTokenClaims contains the JWT claims for an access token
-type IdTokenClaims struct{}
+var errNoUser = errors.New("token has no user")
+
+// IdTokenClaims contains the JWT claims for an access token, the azp claim is
+// always set so the encoded claims are never empty
+type IdTokenClaims map[string]any
 
 func (a IdTokenClaims) Valid() error { return nil }
 func (a IdTokenClaims) Type() string { return "access-token" }
 
-func generateIDToken(ti oauth2.TokenInfo, us *database.Queries, key mjwt.Signer) (token string, err error) {
-	user, err := us.GetUser(context.Background(), ti.GetUserID())
+func generateIDToken(ti oauth2.TokenInfo, key mjwt.Signer, subject subjectMapper, claims claimsLookup) (token string, err error) {
+	// client credentials tokens have no user to identify
+	if ti.GetUserID() == "" {
+		return "", errNoUser
+	}
+	sub, err := subject(context.Background(), ti.GetClientID(), ti.GetUserID())
+	if err != nil {
+		return "", err
+	}
+	m, err := claims(context.Background(), ti.GetClientID(), ti.GetUserID(), ti.GetScope())
 	if err != nil {
 		return "", err
 	}
+	m["azp"] = ti.GetClientID()
 
-	token, err = key.GenerateJwt(user.Subject, "", jwt.ClaimStrings{ti.GetClientID()}, ti.GetAccessExpiresIn(), IdTokenClaims{})
+	token, err = key.GenerateJwt(sub, "", jwt.ClaimStrings{ti.GetClientID()}, ti.GetAccessExpiresIn(), IdTokenClaims(m))
 	return
 }
 
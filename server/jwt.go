@@ -4,26 +4,53 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
 	"github.com/1f349/mjwt"
 	"github.com/1f349/mjwt/auth"
 	"github.com/go-oauth2/oauth2/v4"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"maps"
 	"strings"
 )
 
 type JWTAccessGenerate struct {
-	signer mjwt.Signer
+	signer  mjwt.Signer
+	subject subjectMapper
+	claims  claimsLookup
 }
 
-func NewJWTAccessGenerate(signer mjwt.Signer) *JWTAccessGenerate {
-	return &JWTAccessGenerate{signer}
+func NewJWTAccessGenerate(signer mjwt.Signer, subject subjectMapper, claims claimsLookup) *JWTAccessGenerate {
+	return &JWTAccessGenerate{signer, subject, claims}
+}
+
+// AccessTokenClaims contains the JWT claims for an access token with the extra
+// claims from the groups scope and claim mappings
+type AccessTokenClaims struct {
+	auth.AccessTokenClaims
+	Claims map[string]any
+}
+
+// MarshalJSON outputs the extra claims alongside the access token claims
+func (a AccessTokenClaims) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(a.Claims)+1)
+	maps.Copy(m, a.Claims)
+	m["per"] = a.Perms
+	return json.Marshal(m)
 }
 
 var _ oauth2.AccessGenerate = &JWTAccessGenerate{}
 
 func (j JWTAccessGenerate) Token(ctx context.Context, data *oauth2.GenerateBasic, isGenRefresh bool) (access, refresh string, err error) {
-	access, err = j.signer.GenerateJwt(data.UserID, "", jwt.ClaimStrings{data.Client.GetID()}, data.TokenInfo.GetAccessExpiresIn(), auth.AccessTokenClaims{})
+	sub, err := j.subject(ctx, data.Client.GetID(), data.UserID)
+	if err != nil {
+		return "", "", err
+	}
+	claims, err := j.claims(ctx, data.Client.GetID(), data.UserID, data.TokenInfo.GetScope())
+	if err != nil {
+		return "", "", err
+	}
+	access, err = j.signer.GenerateJwt(sub, "", jwt.ClaimStrings{data.Client.GetID()}, data.TokenInfo.GetAccessExpiresIn(), AccessTokenClaims{Claims: claims})
 
 	if isGenRefresh {
 		t := uuid.NewHash(sha256.New(), uuid.New(), []byte(access), 5).String()
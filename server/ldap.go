@@ -0,0 +1,319 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/1f349/tulip/authenticator"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/ldapserver"
+	"github.com/1f349/tulip/password"
+	"github.com/go-ldap/ldap/v3"
+	"net"
+	"slices"
+	"strings"
+)
+
+// LdapConf configures the read-only LDAP directory of active users and
+// groups, the listener is disabled when Listen is empty
+type LdapConf struct {
+	Listen string `json:"listen"`
+	BaseDN string `json:"base_dn"`
+	// CertFile and KeyFile enable LDAPS, without them simple binds send the
+	// password in plain text
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+}
+
+var (
+	errLdapInvalidCredentials = ldapserver.NewError(ldap.LDAPResultInvalidCredentials, "invalid credentials")
+	errLdapLocked             = ldapserver.NewError(ldap.LDAPResultUnwillingToPerform, "too many failed attempts, please try again later")
+)
+
+// ldapDirectory serves users as uid=<username>,ou=users,<base> and groups as
+// cn=<name>,ou=groups,<base>
+type ldapDirectory struct {
+	db             *database.Queries
+	authenticators []authenticator.Authenticator
+	guard          *loginGuard
+	base           *ldap.DN
+	baseDN         string
+}
+
+var _ ldapserver.Handler = &ldapDirectory{}
+
+// NewLdapServer creates the LDAP server for the directory in the database
+func NewLdapServer(conf Conf, db *database.Queries) (*ldapserver.Server, error) {
+	base, err := ldap.ParseDN(conf.Ldap.BaseDN)
+	if err != nil || len(base.RDNs) == 0 {
+		return nil, fmt.Errorf("invalid ldap base dn: %q", conf.Ldap.BaseDN)
+	}
+	guard, err := newLoginGuard(db, conf)
+	if err != nil {
+		return nil, err
+	}
+	s := &ldapserver.Server{
+		Handler: &ldapDirectory{db: db, authenticators: conf.authenticators(), guard: guard, base: base, baseDN: conf.Ldap.BaseDN},
+	}
+	if conf.Ldap.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.Ldap.CertFile, conf.Ldap.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load ldap certificate: %w", err)
+		}
+		s.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	return s, nil
+}
+
+func (d *ldapDirectory) usersDN() string  { return "ou=users," + d.baseDN }
+func (d *ldapDirectory) groupsDN() string { return "ou=groups," + d.baseDN }
+
+func (d *ldapDirectory) userDN(username string) string {
+	return "uid=" + ldap.EscapeDN(username) + "," + d.usersDN()
+}
+
+func (d *ldapDirectory) groupDN(name string) string {
+	return "cn=" + ldap.EscapeDN(name) + "," + d.groupsDN()
+}
+
+// bindUsername finds the username from a user DN, a plain username is also
+// accepted for software which does not support bind DN templates
+func (d *ldapDirectory) bindUsername(dn string) (string, bool) {
+	if !strings.Contains(dn, "=") {
+		return dn, dn != ""
+	}
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) != len(d.base.RDNs)+2 {
+		return "", false
+	}
+	users, err := ldap.ParseDN(d.usersDN())
+	if err != nil || !users.AncestorOfFold(parsed) {
+		return "", false
+	}
+	rdn := parsed.RDNs[0]
+	if len(rdn.Attributes) != 1 || !strings.EqualFold(rdn.Attributes[0].Type, "uid") {
+		return "", false
+	}
+	return rdn.Attributes[0].Value, true
+}
+
+func (d *ldapDirectory) Bind(ctx context.Context, remote net.Addr, dn, pw string) (string, error) {
+	username, ok := d.bindUsername(dn)
+	if !ok {
+		return "", errLdapInvalidCredentials
+	}
+	ip, _, err := net.SplitHostPort(remote.String())
+	if err != nil {
+		ip = remote.String()
+	}
+
+	var lockout database.GetLoginLockoutRow
+	err = dbTxError(d.db, func(tx *database.Queries) (err error) {
+		lockout, err = tx.GetLoginLockout(ctx, username)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return
+	})
+	if err != nil {
+		return "", err
+	}
+	if d.guard.blocked(ip, lockout.LockedUntil) {
+		return "", errLdapLocked
+	}
+	ok, err = d.guard.startAttempt(ctx, ip, lockout.Subject)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", errLdapLocked
+	}
+
+	// app passwords are checked first as they are the only way to bind for
+	// accounts with two-factor authentication
+	if lockout.Subject != "" {
+		ok, err := d.guard.checkAppPassword(ctx, lockout.Subject, pw, appPasswordScopeLdap)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return d.appPasswordBind(ctx, lockout.Subject)
+		}
+	}
+
+	var login database.CheckLoginResult
+	var mismatch, unavailable bool
+	err = dbTxError(d.db, func(tx *database.Queries) (err error) {
+		login, err = tx.CheckLogin(ctx, d.authenticators, username, pw)
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, password.ErrMismatchedHashAndPassword) {
+			mismatch = true
+			return nil
+		}
+		if errors.Is(err, database.ErrDirectoryUnavailable) {
+			unavailable = true
+		}
+		return
+	})
+	if unavailable {
+		d.guard.releaseAttempt(ctx, lockout.Subject)
+	}
+	if err != nil {
+		return "", err
+	}
+	if mismatch {
+		d.guard.recordFailure(ctx, ip, lockout.Subject)
+		return "", errLdapInvalidCredentials
+	}
+	if !login.EmailVerified || !login.Active {
+		d.guard.releaseAttempt(ctx, login.Subject)
+		return "", errLdapInvalidCredentials
+	}
+	if login.HasOtp || login.HasWebauthn {
+		d.guard.releaseAttempt(ctx, login.Subject)
+		// the second factor can't be checked by a bind
+		return "", ldapserver.NewError(ldap.LDAPResultInvalidCredentials, "accounts with two-factor authentication must bind with an app password")
+	}
+
+	d.guard.resetFailures(ctx, login.Subject)
+	return login.Subject, nil
+}
+
+func (d *ldapDirectory) appPasswordBind(ctx context.Context, subject string) (string, error) {
+	var user database.User
+	err := dbTxError(d.db, func(tx *database.Queries) (err error) {
+		user, err = tx.GetUser(ctx, subject)
+		return
+	})
+	if err != nil {
+		return "", err
+	}
+	if !user.EmailVerified || !user.Active {
+		d.guard.releaseAttempt(ctx, subject)
+		return "", errLdapInvalidCredentials
+	}
+	d.guard.resetFailures(ctx, subject)
+	return subject, nil
+}
+
+func (d *ldapDirectory) Search(ctx context.Context, bound string) ([]ldapserver.Entry, error) {
+	if bound == "" {
+		return nil, ldapserver.NewError(ldap.LDAPResultInsufficientAccessRights, "bind required")
+	}
+
+	var users []database.GetDirectoryUsersRow
+	var groups []database.Group
+	var members []database.GroupMember
+	err := dbTxError(d.db, func(tx *database.Queries) (err error) {
+		users, err = tx.GetDirectoryUsers(ctx)
+		if err != nil {
+			return
+		}
+		groups, err = tx.GetDirectoryGroups(ctx)
+		if err != nil {
+			return
+		}
+		members, err = tx.GetDirectoryGroupMembers(ctx)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	usernames := make(map[string]string, len(users))
+	for _, i := range users {
+		usernames[i.Subject] = i.Username
+	}
+	groupNames := make(map[string]string, len(groups))
+	parents := make(map[string]string, len(groups))
+	for _, i := range groups {
+		groupNames[i.Subject] = i.Name
+		if i.Parent.Valid {
+			parents[i.Subject] = i.Parent.String
+		}
+	}
+
+	// members of a group are also members of the ancestor groups
+	memberOf := make(map[string][]string)
+	groupMembers := make(map[string][]string)
+	for _, m := range members {
+		username, ok := usernames[m.UserSubject]
+		if !ok {
+			continue
+		}
+		for g := m.GroupSubject; g != ""; g = parents[g] {
+			name, ok := groupNames[g]
+			if !ok || slices.Contains(memberOf[m.UserSubject], d.groupDN(name)) {
+				break
+			}
+			memberOf[m.UserSubject] = append(memberOf[m.UserSubject], d.groupDN(name))
+			groupMembers[g] = append(groupMembers[g], d.userDN(username))
+		}
+	}
+
+	baseRdn := d.base.RDNs[0].Attributes[0]
+	entries := []ldapserver.Entry{
+		ldapserver.NewEntry("",
+			ldapserver.Attribute{Name: "objectClass", Values: []string{"top"}},
+			ldapserver.Attribute{Name: "namingContexts", Values: []string{d.baseDN}},
+			ldapserver.Attribute{Name: "supportedLDAPVersion", Values: []string{"3"}},
+		),
+		ldapserver.NewEntry(d.baseDN,
+			ldapserver.Attribute{Name: "objectClass", Values: []string{"top"}},
+			ldapserver.Attribute{Name: baseRdn.Type, Values: []string{baseRdn.Value}},
+		),
+		ldapserver.NewEntry(d.usersDN(),
+			ldapserver.Attribute{Name: "objectClass", Values: []string{"top", "organizationalUnit"}},
+			ldapserver.Attribute{Name: "ou", Values: []string{"users"}},
+		),
+		ldapserver.NewEntry(d.groupsDN(),
+			ldapserver.Attribute{Name: "objectClass", Values: []string{"top", "organizationalUnit"}},
+			ldapserver.Attribute{Name: "ou", Values: []string{"groups"}},
+		),
+	}
+	for _, u := range users {
+		cn := u.Name
+		if cn == "" {
+			cn = u.Username
+		}
+		sn := u.FamilyName
+		if sn == "" {
+			sn = cn
+		}
+		var mail []string
+		if u.EmailVerified && u.Email != "" {
+			mail = []string{u.Email}
+		}
+		slices.Sort(memberOf[u.Subject])
+		entries = append(entries, ldapserver.NewEntry(d.userDN(u.Username),
+			ldapserver.Attribute{Name: "objectClass", Values: []string{"top", "person", "organizationalPerson", "inetOrgPerson"}},
+			ldapserver.Attribute{Name: "uid", Values: []string{u.Username}},
+			ldapserver.Attribute{Name: "cn", Values: []string{cn}},
+			ldapserver.Attribute{Name: "displayName", Values: []string{cn}},
+			ldapserver.Attribute{Name: "givenName", Values: nonEmpty(u.GivenName)},
+			ldapserver.Attribute{Name: "sn", Values: []string{sn}},
+			ldapserver.Attribute{Name: "mail", Values: mail},
+			ldapserver.Attribute{Name: "entryUUID", Values: []string{u.Subject}},
+			ldapserver.Attribute{Name: "memberOf", Values: memberOf[u.Subject]},
+		))
+	}
+	for _, g := range groups {
+		slices.Sort(groupMembers[g.Subject])
+		entries = append(entries, ldapserver.NewEntry(d.groupDN(g.Name),
+			ldapserver.Attribute{Name: "objectClass", Values: []string{"top", "groupOfNames"}},
+			ldapserver.Attribute{Name: "cn", Values: []string{g.Name}},
+			ldapserver.Attribute{Name: "description", Values: nonEmpty(g.Description)},
+			ldapserver.Attribute{Name: "entryUUID", Values: []string{g.Subject}},
+			ldapserver.Attribute{Name: "member", Values: groupMembers[g.Subject]},
+		))
+	}
+	return entries, nil
+}
+
+func nonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+)
+
+func TestLdapDirectory_BindUsername(t *testing.T) {
+	base, err := ldap.ParseDN("dc=example,dc=com")
+	assert.NoError(t, err)
+	d := &ldapDirectory{base: base, baseDN: "dc=example,dc=com"}
+
+	assert.Equal(t, "uid=alice,ou=users,dc=example,dc=com", d.userDN("alice"))
+	assert.Equal(t, `cn=Staff\, London,ou=groups,dc=example,dc=com`, d.groupDN("Staff, London"))
+
+	for dn, want := range map[string]string{
+		"uid=alice,ou=users,dc=example,dc=com": "alice",
+		"UID=alice,OU=Users,DC=Example,DC=com": "alice",
+		`uid=a\,b,ou=users,dc=example,dc=com`:  "a,b",
+		"alice":                                "alice",
+	} {
+		u, ok := d.bindUsername(dn)
+		assert.True(t, ok, dn)
+		assert.Equal(t, want, u, dn)
+	}
+	for _, dn := range []string{
+		"",
+		"cn=alice,ou=users,dc=example,dc=com",
+		"uid=alice,ou=groups,dc=example,dc=com",
+		"uid=alice,ou=users,dc=example,dc=org",
+		"uid=alice,ou=x,ou=users,dc=example,dc=com",
+		"uid=alice+cn=a,ou=users,dc=example,dc=com",
+	} {
+		_, ok := d.bindUsername(dn)
+		assert.False(t, ok, dn)
+	}
+}
+
+func newTestLdapDirectory(t *testing.T) (*HttpServer, *ldapDirectory) {
+	h := newTestServer(t)
+	base, err := ldap.ParseDN("dc=example,dc=com")
+	require.NoError(t, err)
+	return h, &ldapDirectory{db: h.db, guard: h.guard, base: base, baseDN: "dc=example,dc=com"}
+}
+
+func TestLdapDirectory_Bind(t *testing.T) {
+	h, d := newTestLdapDirectory(t)
+	subject := addTestUser(t, h, "alice")
+	remote := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 389}
+
+	sub, err := d.Bind(context.Background(), remote, d.userDN("alice"), "correct horse battery staple")
+	assert.NoError(t, err)
+	assert.Equal(t, subject, sub)
+
+	_, err = d.Bind(context.Background(), remote, d.userDN("alice"), "wrong")
+	assert.ErrorIs(t, err, errLdapInvalidCredentials)
+	lockout, err := h.db.GetLoginLockoutBySubject(context.Background(), subject)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), lockout.FailedLogins)
+}
+
+func TestLdapDirectory_Search(t *testing.T) {
+	h, d := newTestLdapDirectory(t)
+	subject := addTestUser(t, h, "alice")
+	_, err := h.db.AddServiceAccount(context.Background(), "Backup", "backup")
+	require.NoError(t, err)
+
+	entries, err := d.Search(context.Background(), subject)
+	require.NoError(t, err)
+	var dns []string
+	for _, i := range entries {
+		dns = append(dns, i.DN)
+	}
+	assert.Contains(t, dns, d.userDN("alice"))
+	assert.NotContains(t, dns, d.userDN("backup"))
+}
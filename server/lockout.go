@@ -0,0 +1,333 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/1f349/cache"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/logger"
+	tulipMail "github.com/1f349/tulip/mail"
+	"github.com/emersion/go-message/mail"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLockoutThreshold   = 10
+	defaultIpLockoutThreshold = 50
+	defaultLockoutDuration    = 15 * time.Minute
+
+	// backoffFreeAttempts is the number of failures allowed before each
+	// attempt has to wait
+	backoffFreeAttempts = 3
+	maxBackoff          = 5 * time.Minute
+)
+
+// LockoutConf controls the brute-force protection for password and OTP
+// attempts, zero values use the defaults
+//
+// With TrustForwardedFor the client IP is read from X-Forwarded-For, counting
+// TrustedProxies addresses from the right as each proxy appends the address it
+// received the request from. Entries further left are sent by the client.
+type LockoutConf struct {
+	Threshold         int64  `json:"threshold"`
+	IpThreshold       int64  `json:"ip_threshold"`
+	Duration          string `json:"duration"`
+	TrustForwardedFor bool   `json:"trust_forwarded_for"`
+	TrustedProxies    int    `json:"trusted_proxies"`
+}
+
+type ipFailures struct {
+	count int64
+	until time.Time
+}
+
+// loginLimiter keeps the per-IP failure counters, per-account counters are
+// stored in the database
+type loginLimiter struct {
+	threshold         int64
+	ipThreshold       int64
+	duration          time.Duration
+	trustForwardedFor bool
+	trustedProxies    int
+
+	mu  sync.Mutex
+	ips *cache.Cache[string, ipFailures]
+}
+
+func newLoginLimiter(conf LockoutConf) (*loginLimiter, error) {
+	l := &loginLimiter{
+		threshold:         conf.Threshold,
+		ipThreshold:       conf.IpThreshold,
+		duration:          defaultLockoutDuration,
+		trustForwardedFor: conf.TrustForwardedFor,
+		trustedProxies:    conf.TrustedProxies,
+		ips:               cache.New[string, ipFailures](),
+	}
+	if l.threshold <= 0 {
+		l.threshold = defaultLockoutThreshold
+	}
+	if l.ipThreshold <= 0 {
+		l.ipThreshold = defaultIpLockoutThreshold
+	}
+	if l.trustedProxies < 0 {
+		return nil, fmt.Errorf("trusted proxies must not be negative")
+	}
+	if l.trustedProxies == 0 {
+		l.trustedProxies = 1
+	}
+	if conf.Duration != "" {
+		d, err := time.ParseDuration(conf.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lockout duration: %w", err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("lockout duration must be positive")
+		}
+		l.duration = d
+	}
+	return l, nil
+}
+
+// backoffDelay returns how long to wait after n failures, the delay doubles
+// with each failure
+func backoffDelay(n int64) time.Duration {
+	if n <= backoffFreeAttempts {
+		return 0
+	}
+	shift := n - backoffFreeAttempts - 1
+	if shift > 16 {
+		return maxBackoff
+	}
+	return min(time.Second<<shift, maxBackoff)
+}
+
+// lockUntil returns the time when the next attempt is allowed after n failures
+func (l *loginLimiter) lockUntil(n, threshold int64, now time.Time) time.Time {
+	if n >= threshold {
+		return now.Add(l.duration)
+	}
+	return now.Add(backoffDelay(n))
+}
+
+// clientIp returns the address added by the furthest trusted proxy, the
+// addresses before it can be forged by the client
+func (l *loginLimiter) clientIp(req *http.Request) string {
+	if l.trustForwardedFor {
+		var fwd []string
+		for _, i := range req.Header.Values("X-Forwarded-For") {
+			for _, ip := range strings.Split(i, ",") {
+				if ip = strings.TrimSpace(ip); ip != "" {
+					fwd = append(fwd, ip)
+				}
+			}
+		}
+		if len(fwd) > 0 {
+			return fwd[max(len(fwd)-l.trustedProxies, 0)]
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+func (l *loginLimiter) ipBlocked(ip string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, ok := l.ips.Get(ip)
+	return ok && now.Before(f.until)
+}
+
+func (l *loginLimiter) ipFailure(ip string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, _ := l.ips.Get(ip)
+	f.count++
+	f.until = l.lockUntil(f.count, l.ipThreshold, now)
+	// the counter is forgotten after a quiet period
+	l.ips.Set(ip, f, f.until.Add(l.duration))
+}
+
+// loginGuard checks login attempts against the lockout counters and app
+// passwords, it is shared by the http server and the LDAP directory
+type loginGuard struct {
+	db      *database.Queries
+	limiter *loginLimiter
+	mail    tulipMail.Mail
+}
+
+func newLoginGuard(db *database.Queries, conf Conf) (*loginGuard, error) {
+	limiter, err := newLoginLimiter(conf.Lockout)
+	if err != nil {
+		return nil, err
+	}
+	return &loginGuard{db: db, limiter: limiter, mail: conf.Mail}, nil
+}
+
+// loginBlocked writes an error and returns true if the client IP or the
+// account is not allowed to make another attempt yet
+func (h *HttpServer) loginBlocked(rw http.ResponseWriter, req *http.Request, lockedUntil sql.NullTime) bool {
+	if h.guard.blocked(h.guard.limiter.clientIp(req), lockedUntil) {
+		http.Error(rw, "429 Too Many Requests: Too many failed attempts, please try again later", http.StatusTooManyRequests)
+		return true
+	}
+	return false
+}
+
+// blocked returns true if the IP or the account is not allowed to make
+// another attempt yet
+func (g *loginGuard) blocked(ip string, lockedUntil sql.NullTime) bool {
+	now := time.Now()
+	return g.limiter.ipBlocked(ip, now) || (lockedUntil.Valid && now.Before(lockedUntil.Time))
+}
+
+// startLoginAttempt counts an attempt for the account before the credentials
+// are checked, an error is written and true is returned if the account is
+// locked
+//
+// The attempt is counted atomically so parallel requests can't make more
+// guesses than the threshold allows before the lock is saved.
+func (h *HttpServer) startLoginAttempt(rw http.ResponseWriter, req *http.Request, subject string) bool {
+	ok, err := h.guard.startAttempt(req.Context(), h.guard.limiter.clientIp(req), subject)
+	if err != nil {
+		http.Error(rw, "Database error", http.StatusInternalServerError)
+		return true
+	}
+	if !ok {
+		http.Error(rw, "429 Too Many Requests: Too many failed attempts, please try again later", http.StatusTooManyRequests)
+		return true
+	}
+	return false
+}
+
+// startAttempt returns false if the account is locked, unknown accounts are
+// not counted
+func (g *loginGuard) startAttempt(ctx context.Context, ip, subject string) (bool, error) {
+	if subject == "" {
+		return true, nil
+	}
+	var n int64
+	err := dbTxError(g.db, func(tx *database.Queries) (err error) {
+		n, err = tx.StartLoginAttempt(ctx, database.StartLoginAttemptParams{
+			Subject:     subject,
+			LockedUntil: sql.NullTime{Time: time.Now(), Valid: true},
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			n = -1
+			return nil
+		}
+		return
+	})
+	if err != nil {
+		return false, err
+	}
+	if n == -1 {
+		return false, nil
+	}
+	// attempts past the threshold started before the lock was saved
+	if n > g.limiter.threshold {
+		g.recordFailure(ctx, ip, subject)
+		return false, nil
+	}
+	return true, nil
+}
+
+// releaseAttempt stops counting an attempt where the credentials were correct
+// but the login didn't complete, such as when a second factor is required
+func (g *loginGuard) releaseAttempt(ctx context.Context, subject string) {
+	err := dbTxError(g.db, func(tx *database.Queries) error {
+		return tx.ReleaseLoginAttempt(ctx, subject)
+	})
+	if err != nil {
+		logger.Logger.Warn("Failed to release login attempt", "sub", subject, "err", err)
+	}
+}
+
+// recordLoginFailure saves the lock for the attempt counted by
+// startLoginAttempt and increments the failure counter for the client IP, the
+// user is notified by email when the account becomes locked
+func (h *HttpServer) recordLoginFailure(req *http.Request, subject string) {
+	h.guard.recordFailure(req.Context(), h.guard.limiter.clientIp(req), subject)
+}
+
+func (g *loginGuard) recordFailure(ctx context.Context, ip, subject string) {
+	now := time.Now()
+	g.limiter.ipFailure(ip, now)
+	if subject == "" {
+		return
+	}
+
+	var locked bool
+	until := now.Add(g.limiter.duration)
+	err := dbTxError(g.db, func(tx *database.Queries) error {
+		lockout, err := tx.GetLoginLockoutBySubject(ctx, subject)
+		if err != nil {
+			return err
+		}
+		n := lockout.FailedLogins
+		if n < g.limiter.threshold {
+			// newer attempts have already saved their own lock if the counter changed
+			return tx.SetLockedUntil(ctx, database.SetLockedUntilParams{
+				LockedUntil:  sql.NullTime{Time: g.limiter.lockUntil(n, g.limiter.threshold, now), Valid: true},
+				Subject:      subject,
+				FailedLogins: n,
+			})
+		}
+		// the counter restarts so failures after the lock ends don't lock the
+		// account again straight away
+		rows, err := tx.LockAccount(ctx, database.LockAccountParams{
+			LockedUntil:  sql.NullTime{Time: until, Valid: true},
+			Subject:      subject,
+			FailedLogins: g.limiter.threshold,
+		})
+		locked = rows == 1
+		return err
+	})
+	if err != nil {
+		logger.Logger.Warn("Failed to record login failure", "sub", subject, "err", err)
+		return
+	}
+	if locked {
+		g.sendLockoutEmail(ctx, subject, until)
+	}
+}
+
+// resetFailures clears the account failure counter after a successful login
+func (g *loginGuard) resetFailures(ctx context.Context, subject string) {
+	err := dbTxError(g.db, func(tx *database.Queries) error {
+		return tx.ResetFailedLogins(ctx, subject)
+	})
+	if err != nil {
+		logger.Logger.Warn("Failed to reset login failures", "sub", subject, "err", err)
+	}
+}
+
+func (g *loginGuard) sendLockoutEmail(ctx context.Context, subject string, until time.Time) {
+	var userInfo database.User
+	err := dbTxError(g.db, func(tx *database.Queries) (err error) {
+		userInfo, err = tx.GetUser(ctx, subject)
+		return
+	})
+	if err != nil {
+		logger.Logger.Warn("Failed to read user for lockout email", "sub", subject, "err", err)
+		return
+	}
+	address, err := mail.ParseAddress(userInfo.Email)
+	if err != nil {
+		logger.Logger.Warn("Failed to parse user email address", "sub", subject, "err", err)
+		return
+	}
+	err = g.mail.SendEmailTemplate("mail-account-locked", "Account Locked", userInfo.Name, address, map[string]any{
+		"Until": until.UTC().Format(time.RFC1123),
+	})
+	if err != nil {
+		logger.Logger.Warn("Failed to send lockout email", "sub", subject, "err", err)
+	}
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/1f349/tulip/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	assert.Equal(t, time.Duration(0), backoffDelay(0))
+	assert.Equal(t, time.Duration(0), backoffDelay(backoffFreeAttempts))
+	assert.Equal(t, time.Second, backoffDelay(backoffFreeAttempts+1))
+	assert.Equal(t, 2*time.Second, backoffDelay(backoffFreeAttempts+2))
+	assert.Equal(t, 4*time.Second, backoffDelay(backoffFreeAttempts+3))
+	assert.Equal(t, maxBackoff, backoffDelay(100))
+}
+
+func TestNewLoginLimiter(t *testing.T) {
+	l, err := newLoginLimiter(LockoutConf{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(defaultLockoutThreshold), l.threshold)
+	assert.Equal(t, int64(defaultIpLockoutThreshold), l.ipThreshold)
+	assert.Equal(t, defaultLockoutDuration, l.duration)
+
+	l, err = newLoginLimiter(LockoutConf{Threshold: 3, Duration: "1h"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), l.threshold)
+	assert.Equal(t, time.Hour, l.duration)
+
+	_, err = newLoginLimiter(LockoutConf{Duration: "forever"})
+	assert.Error(t, err)
+	_, err = newLoginLimiter(LockoutConf{Duration: "-1m"})
+	assert.Error(t, err)
+}
+
+func TestLoginLimiter_IpFailure(t *testing.T) {
+	l, err := newLoginLimiter(LockoutConf{IpThreshold: 5, Duration: "10m"})
+	assert.NoError(t, err)
+	now := time.Now()
+
+	for i := 0; i < backoffFreeAttempts; i++ {
+		l.ipFailure("192.0.2.1", now)
+		assert.False(t, l.ipBlocked("192.0.2.1", now))
+	}
+	l.ipFailure("192.0.2.1", now)
+	assert.True(t, l.ipBlocked("192.0.2.1", now))
+	assert.False(t, l.ipBlocked("192.0.2.1", now.Add(2*time.Second)))
+	assert.False(t, l.ipBlocked("192.0.2.2", now))
+
+	// reaching the threshold locks for the full duration
+	l.ipFailure("192.0.2.1", now)
+	assert.True(t, l.ipBlocked("192.0.2.1", now.Add(9*time.Minute)))
+	assert.False(t, l.ipBlocked("192.0.2.1", now.Add(11*time.Minute)))
+}
+
+func TestLoginLimiter_ClientIp(t *testing.T) {
+	req := httptest.NewRequest("POST", "/login", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1, 192.0.2.1")
+
+	l, err := newLoginLimiter(LockoutConf{})
+	assert.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", l.clientIp(req))
+
+	l.trustForwardedFor = true
+	assert.Equal(t, "192.0.2.1", l.clientIp(req))
+
+	// the proxy appends the real address after the forged entries
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.1")
+	req.Header.Add("X-Forwarded-For", "192.0.2.7")
+	assert.Equal(t, "192.0.2.7", l.clientIp(req))
+	l.trustedProxies = 2
+	assert.Equal(t, "198.51.100.1", l.clientIp(req))
+	l.trustedProxies = 5
+	assert.Equal(t, "203.0.113.9", l.clientIp(req))
+
+	_, err = newLoginLimiter(LockoutConf{TrustedProxies: -1})
+	assert.Error(t, err)
+}
+
+func TestLoginBlocked_ForgedForwardedFor(t *testing.T) {
+	h := newTestServer(t)
+	h.guard.limiter.trustForwardedFor = true
+	h.guard.limiter.ipThreshold = 2
+
+	request := func(forged string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.Header.Set("X-Forwarded-For", forged+", 192.0.2.50")
+		return req
+	}
+	for i := 0; i < 2; i++ {
+		h.recordLoginFailure(request(fmt.Sprintf("203.0.113.%d", i)), "")
+	}
+
+	// a new forged entry doesn't reset the limit for the real address
+	rec := httptest.NewRecorder()
+	assert.True(t, h.loginBlocked(rec, request("203.0.113.99"), sql.NullTime{}))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+}
+
+func TestStartLoginAttempt(t *testing.T) {
+	h := newTestServer(t)
+	ctx := context.Background()
+	h.guard.limiter.threshold = 3
+	subject := addTestUser(t, h, "admin")
+
+	// attempts which started together can't make more guesses than the threshold
+	for i := 0; i < 3; i++ {
+		ok, err := h.guard.startAttempt(ctx, "192.0.2.1", subject)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	}
+	ok, err := h.guard.startAttempt(ctx, "192.0.2.1", subject)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	lockout, err := h.db.GetLoginLockoutBySubject(ctx, subject)
+	require.NoError(t, err)
+	assert.True(t, lockout.LockedUntil.Valid && lockout.LockedUntil.Time.After(time.Now()))
+	ok, err = h.guard.startAttempt(ctx, "192.0.2.1", subject)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// unknown accounts are not counted
+	ok, err = h.guard.startAttempt(ctx, "192.0.2.1", "")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestRecordLoginFailure_LockEnds(t *testing.T) {
+	h := newTestServer(t)
+	ctx := context.Background()
+	h.guard.limiter.threshold = 3
+	subject := addTestUser(t, h, "admin")
+
+	fail := func() {
+		ok, err := h.guard.startAttempt(ctx, "192.0.2.1", subject)
+		require.NoError(t, err)
+		require.True(t, ok)
+		h.guard.recordFailure(ctx, "192.0.2.1", subject)
+	}
+	for i := 0; i < 3; i++ {
+		fail()
+	}
+	lockout, err := h.db.GetLoginLockoutBySubject(ctx, subject)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), lockout.FailedLogins)
+	assert.True(t, lockout.LockedUntil.Time.After(time.Now().Add(time.Minute)))
+
+	// a failure after the lock has ended starts counting again
+	require.NoError(t, h.db.SetLockedUntil(ctx, database.SetLockedUntilParams{
+		LockedUntil:  sql.NullTime{Time: time.Now().Add(-time.Second), Valid: true},
+		Subject:      subject,
+		FailedLogins: 0,
+	}))
+	fail()
+	lockout, err = h.db.GetLoginLockoutBySubject(ctx, subject)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), lockout.FailedLogins)
+	assert.False(t, lockout.LockedUntil.Time.After(time.Now()))
+
+	// correct credentials which don't finish the login are not counted
+	ok, err := h.guard.startAttempt(ctx, "192.0.2.1", subject)
+	require.NoError(t, err)
+	require.True(t, ok)
+	h.guard.releaseAttempt(ctx, subject)
+	lockout, err = h.db.GetLoginLockoutBySubject(ctx, subject)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), lockout.FailedLogins)
+}
@@ -1,22 +1,23 @@
 package server
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/1f349/mjwt"
-	"github.com/1f349/mjwt/auth"
 	"github.com/1f349/mjwt/claims"
 	"github.com/1f349/tulip/database"
 	"github.com/1f349/tulip/logger"
 	"github.com/1f349/tulip/pages"
+	"github.com/1f349/tulip/password"
 	"github.com/emersion/go-message/mail"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/julienschmidt/httprouter"
-	"golang.org/x/crypto/bcrypt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -47,10 +48,14 @@ func (h *HttpServer) LoginGet(rw http.ResponseWriter, req *http.Request, _ httpr
 	rw.Header().Set("Content-Type", "text/html")
 	rw.WriteHeader(http.StatusOK)
 	pages.RenderPageTemplate(rw, "login", map[string]any{
-		"ServiceName": h.conf.ServiceName,
-		"Redirect":    req.URL.Query().Get("redirect"),
-		"Mismatch":    req.URL.Query().Get("mismatch"),
-		"LoginName":   loginName,
+		"ServiceName":     h.conf.ServiceName,
+		"Redirect":        req.URL.Query().Get("redirect"),
+		"Mismatch":        req.URL.Query().Get("mismatch"),
+		"LoginName":       loginName,
+		"RegisterEnabled": h.conf.Registration.Enabled,
+		"EmailLogin":      h.conf.EmailLogin,
+		"Upstreams":       h.conf.Upstreams,
+		"RememberMe":      h.sessions.rememberMe == rememberMeOptional,
 	})
 }
 
@@ -63,27 +68,60 @@ func (h *HttpServer) LoginPost(rw http.ResponseWriter, req *http.Request, _ http
 	var loginMismatch byte
 	var hasOtp bool
 
+	// check for a lockout before the password is checked
+	var lockout database.GetLoginLockoutRow
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		lockout, err = tx.GetLoginLockout(req.Context(), un)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return
+	}) {
+		return
+	}
+	if h.loginBlocked(rw, req, lockout.LockedUntil) {
+		return
+	}
+	if h.startLoginAttempt(rw, req, lockout.Subject) {
+		return
+	}
+
 	if h.DbTx(rw, func(tx *database.Queries) error {
-		loginUser, err := tx.CheckLogin(req.Context(), un, pw)
+		loginUser, err := tx.CheckLogin(req.Context(), h.authenticators, un, pw)
 		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) || errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			if errors.Is(err, sql.ErrNoRows) || errors.Is(err, password.ErrMismatchedHashAndPassword) {
 				loginMismatch = 1
 				return nil
 			}
+			if errors.Is(err, database.ErrDirectoryUnavailable) {
+				h.guard.releaseAttempt(req.Context(), lockout.Subject)
+				http.Error(rw, "503 Service Unavailable: Login directory is unavailable", http.StatusServiceUnavailable)
+				return err
+			}
 			http.Error(rw, "Internal server error", http.StatusInternalServerError)
 			return err
 		}
 
 		userInfo = loginUser
-		hasOtp = loginUser.HasOtp
+		// passkeys can be used as the second factor
+		hasOtp = loginUser.HasOtp || loginUser.HasWebauthn
 		if !loginUser.EmailVerified {
 			loginMismatch = 2
+		} else if !loginUser.Active {
+			loginMismatch = 3
 		}
 		return nil
 	}) {
 		return
 	}
 
+	if loginMismatch == 1 {
+		h.recordLoginFailure(req, lockout.Subject)
+	} else if loginMismatch != 0 {
+		// the password was correct
+		h.guard.releaseAttempt(req.Context(), lockout.Subject)
+	}
+
 	if loginMismatch != 0 {
 		originUrl, err := url.Parse(req.FormValue("redirect"))
 		if err != nil {
@@ -121,10 +159,23 @@ func (h *HttpServer) LoginPost(rw http.ResponseWriter, req *http.Request, _ http
 		return
 	}
 
+	// the second factor was checked when the device was trusted
+	if hasOtp && h.isTrustedDevice(req, userInfo.Subject) {
+		hasOtp = false
+	}
+
+	needSetup, err := h.needTwoFactorSetup(req.Context(), userInfo.Subject, true)
+	if err != nil {
+		http.Error(rw, "Database error", http.StatusInternalServerError)
+		return
+	}
+
 	// only continues if the above tx succeeds
 	userAuth = UserAuth{
-		Subject: userInfo.Subject,
-		NeedOtp: hasOtp,
+		Subject:            userInfo.Subject,
+		NeedOtp:            hasOtp,
+		Persistent:         h.sessions.persistent(req.FormValue("remember_me") != ""),
+		NeedTwoFactorSetup: needSetup,
 	}
 
 	if h.setLoginDataCookie(rw, userAuth) {
@@ -133,6 +184,8 @@ func (h *HttpServer) LoginPost(rw http.ResponseWriter, req *http.Request, _ http
 	}
 
 	if hasOtp {
+		// the OTP attempts are counted separately
+		h.guard.releaseAttempt(req.Context(), lockout.Subject)
 		originUrl, err := url.Parse(req.FormValue("redirect"))
 		if err != nil {
 			http.Error(rw, "400 Bad Request: Invalid redirect URL", http.StatusBadRequest)
@@ -143,24 +196,129 @@ func (h *HttpServer) LoginPost(rw http.ResponseWriter, req *http.Request, _ http
 		return
 	}
 
+	// the counter is only reset once all factors have been checked
+	h.guard.resetFailures(req.Context(), userInfo.Subject)
 	h.SafeRedirect(rw, req)
 }
 
-const twelveHours = 12 * time.Hour
-const oneMonth = 30 * 24 * time.Hour
+// finishExternalLogin completes a login which did not use the password form,
+// the user continues to the OTP page if they have a second factor
+func (h *HttpServer) finishExternalLogin(rw http.ResponseWriter, req *http.Request, subject, redirect string) {
+	var user database.User
+	var hasOtp, hasWebauthn bool
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		user, err = tx.GetUser(req.Context(), subject)
+		if err != nil {
+			return
+		}
+		hasOtp, err = tx.HasOtp(req.Context(), subject)
+		if err != nil {
+			return
+		}
+		hasWebauthn, err = tx.HasWebauthn(req.Context(), subject)
+		return
+	}) {
+		return
+	}
+	if h.loginBlocked(rw, req, user.LockedUntil) {
+		return
+	}
+
+	originUrl, err := url.Parse(safeRedirectPath(redirect))
+	if err != nil {
+		http.Error(rw, "400 Bad Request: Invalid redirect URL", http.StatusBadRequest)
+		return
+	}
+	// service accounts are also excluded from the login queries
+	if !user.Active || user.ServiceAccount {
+		http.Redirect(rw, req, PrepareRedirectUrl("/login?mismatch=3", originUrl).String(), http.StatusFound)
+		return
+	}
+
+	needSetup, err := h.needTwoFactorSetup(req.Context(), subject, true)
+	if err != nil {
+		http.Error(rw, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	userAuth := UserAuth{
+		Subject:            subject,
+		NeedOtp:            (hasOtp || hasWebauthn) && !h.isTrustedDevice(req, subject),
+		Persistent:         h.sessions.persistent(true),
+		NeedTwoFactorSetup: needSetup,
+	}
+	if h.setLoginDataCookie(rw, userAuth) {
+		return
+	}
+
+	if userAuth.NeedOtp {
+		http.Redirect(rw, req, PrepareRedirectUrl("/login/otp", originUrl).String(), http.StatusFound)
+		return
+	}
+
+	h.guard.resetFailures(req.Context(), subject)
+	http.Redirect(rw, req, originUrl.String(), http.StatusFound)
+}
+
+const passwordResetInterval = 5 * time.Minute
+
+// loginAccessClaims are the access token claims with the time the token was
+// issued in nanoseconds, the issued at claim is rounded to the second so it
+// can't be compared with the time the sessions were revoked
+type loginAccessClaims struct {
+	Perms    *claims.PermStorage `json:"per"`
+	IssuedAt int64               `json:"ins,omitempty"`
+}
+
+func (a loginAccessClaims) Valid() error { return nil }
+func (a loginAccessClaims) Type() string { return "access-token" }
+
+// loginRefreshClaims extends the refresh token claims with the time of the
+// login, the remember me choice and whether the OTP step is still required,
+// these are kept when the tokens are refreshed
+type loginRefreshClaims struct {
+	AccessTokenId string `json:"ati"`
+	SessionStart  int64  `json:"sst,omitempty"`
+	Persistent    bool   `json:"pst,omitempty"`
+	NeedOtp       bool   `json:"otp,omitempty"`
+	IssuedAt      int64  `json:"ins,omitempty"`
+}
+
+func (r loginRefreshClaims) Valid() error { return nil }
+func (r loginRefreshClaims) Type() string { return "refresh-token" }
 
 func (h *HttpServer) setLoginDataCookie(rw http.ResponseWriter, authData UserAuth) bool {
+	return h.setLoginCookies(rw, authData, time.Now())
+}
+
+// setLoginCookies issues new login tokens, start is the time of the login and
+// limits how long the session can be refreshed
+func (h *HttpServer) setLoginCookies(rw http.ResponseWriter, authData UserAuth, start time.Time) bool {
+	now := time.Now()
 	ps := claims.NewPermStorage()
 	if authData.NeedOtp {
 		ps.Set("needs-otp")
 	}
+	if authData.Persistent {
+		ps.Set("persistent")
+	}
+	if authData.NeedTwoFactorSetup {
+		ps.Set("needs-2fa-setup")
+	}
 	accId := uuid.NewString()
-	gen, err := h.signingKey.GenerateJwt(authData.Subject, accId, jwt.ClaimStrings{h.conf.BaseUrl}, twelveHours, auth.AccessTokenClaims{Perms: ps})
+	gen, err := h.signingKey.GenerateJwt(authData.Subject, accId, jwt.ClaimStrings{h.conf.BaseUrl}, h.sessions.access, loginAccessClaims{Perms: ps, IssuedAt: now.UnixNano()})
 	if err != nil {
 		http.Error(rw, "Failed to generate cookie token", http.StatusInternalServerError)
 		return true
 	}
-	ref, err := h.signingKey.GenerateJwt(authData.Subject, uuid.NewString(), jwt.ClaimStrings{h.conf.BaseUrl}, oneMonth, auth.RefreshTokenClaims{AccessTokenId: accId})
+	refreshExpiry := h.sessions.refreshExpiry(start, now)
+	ref, err := h.signingKey.GenerateJwt(authData.Subject, uuid.NewString(), jwt.ClaimStrings{h.conf.BaseUrl}, refreshExpiry.Sub(now), loginRefreshClaims{
+		AccessTokenId: accId,
+		SessionStart:  start.Unix(),
+		Persistent:    authData.Persistent,
+		NeedOtp:       authData.NeedOtp,
+		IssuedAt:      now.UnixNano(),
+	})
 	if err != nil {
 		http.Error(rw, "Failed to generate cookie token", http.StatusInternalServerError)
 		return true
@@ -169,19 +327,25 @@ func (h *HttpServer) setLoginDataCookie(rw http.ResponseWriter, authData UserAut
 		Name:     "tulip-login-access",
 		Value:    gen,
 		Path:     "/",
+		Domain:   h.sessions.cookieDomain,
 		Secure:   true,
 		HttpOnly: true,
 		SameSite: http.SameSiteLaxMode,
 	})
-	http.SetCookie(rw, &http.Cookie{
+	refreshCookie := &http.Cookie{
 		Name:     "tulip-login-refresh",
 		Value:    ref,
 		Path:     "/",
-		Expires:  time.Now().AddDate(0, 0, 32),
+		Domain:   h.sessions.cookieDomain,
 		Secure:   true,
 		HttpOnly: true,
 		SameSite: http.SameSiteLaxMode,
-	})
+	}
+	// without an expiry the browser removes the cookie when it is closed
+	if authData.Persistent {
+		refreshCookie.Expires = refreshExpiry
+	}
+	http.SetCookie(rw, refreshCookie)
 	return false
 }
 
@@ -198,35 +362,61 @@ func readJwtCookie[T mjwt.Claims](req *http.Request, cookieName string, signingK
 }
 
 func (h *HttpServer) readLoginAccessCookie(rw http.ResponseWriter, req *http.Request, u *UserAuth) error {
-	loginData, err := readJwtCookie[auth.AccessTokenClaims](req, "tulip-login-access", h.signingKey)
-	if err != nil {
+	loginData, err := readJwtCookie[loginAccessClaims](req, "tulip-login-access", h.signingKey)
+	if err != nil || h.sessionRevoked(req.Context(), loginData.Subject, loginData.RegisteredClaims.IssuedAt, loginData.Claims.IssuedAt) {
 		return h.readLoginRefreshCookie(rw, req, u)
 	}
 	*u = UserAuth{
-		Subject: loginData.Subject,
-		NeedOtp: loginData.Claims.Perms.Has("needs-otp"),
+		Subject:            loginData.Subject,
+		NeedOtp:            loginData.Claims.Perms.Has("needs-otp"),
+		Persistent:         loginData.Claims.Perms.Has("persistent"),
+		NeedTwoFactorSetup: loginData.Claims.Perms.Has("needs-2fa-setup"),
 	}
 	return nil
 }
 
 func (h *HttpServer) readLoginRefreshCookie(rw http.ResponseWriter, req *http.Request, userAuth *UserAuth) error {
-	refreshData, err := readJwtCookie[auth.RefreshTokenClaims](req, "tulip-login-refresh", h.signingKey)
+	refreshData, err := readJwtCookie[loginRefreshClaims](req, "tulip-login-refresh", h.signingKey)
+	if err != nil {
+		return err
+	}
+	if h.sessionRevoked(req.Context(), refreshData.Subject, refreshData.RegisteredClaims.IssuedAt, refreshData.Claims.IssuedAt) {
+		return errSessionRevoked
+	}
+
+	// users who skipped the enrolment page are only stopped after the grace period
+	needSetup, err := h.needTwoFactorSetup(req.Context(), refreshData.Subject, false)
 	if err != nil {
 		return err
 	}
 
 	*userAuth = UserAuth{
-		Subject: refreshData.Subject,
-		NeedOtp: false,
+		Subject:            refreshData.Subject,
+		NeedOtp:            refreshData.Claims.NeedOtp,
+		Persistent:         refreshData.Claims.Persistent,
+		NeedTwoFactorSetup: needSetup,
 	}
 
-	if h.setLoginDataCookie(rw, *userAuth) {
+	// tokens from before the session start was recorded start a new session
+	start := time.Now()
+	if refreshData.Claims.SessionStart != 0 {
+		start = time.Unix(refreshData.Claims.SessionStart, 0)
+	}
+	if h.setLoginCookies(rw, *userAuth, start) {
 		http.Error(rw, "Failed to save login cookie", http.StatusInternalServerError)
 		return fmt.Errorf("failed to save login cookie: %w", ErrAuthHttpError)
 	}
 	return nil
 }
 
+func (h *HttpServer) LoginResetPasswordGet(rw http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	rw.Header().Set("Content-Type", "text/html")
+	rw.WriteHeader(http.StatusOK)
+	pages.RenderPageTemplate(rw, "forgot-password", map[string]any{
+		"ServiceName": h.conf.ServiceName,
+	})
+}
+
 func (h *HttpServer) LoginResetPasswordPost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	email := req.PostFormValue("email")
 	address, err := mail.ParseAddress(email)
@@ -243,13 +433,42 @@ func (h *HttpServer) LoginResetPasswordPost(rw http.ResponseWriter, req *http.Re
 		return
 	}
 
-	go h.possiblySendPasswordResetEmail(email, emailExists)
+	go h.possiblySendPasswordResetEmail(address, emailExists)
 
-	http.Error(rw, "An email will be send to your inbox if an account with that email address is found", http.StatusOK)
+	http.Error(rw, "An email will be sent to your inbox if an account with that email address is found", http.StatusOK)
 }
 
-func (h *HttpServer) possiblySendPasswordResetEmail(email string, exists bool) {
-	// TODO(Melon): Send reset password email template
-	_ = email
-	_ = exists
+// possiblySendPasswordResetEmail runs in the background so the response does
+// not reveal if the email address exists
+func (h *HttpServer) possiblySendPasswordResetEmail(address *mail.Address, exists bool) {
+	// only one email is sent to each address within the rate limit window
+	key := strings.ToLower(address.Address)
+	if _, ok := h.passwordResetLimit.Get(key); ok {
+		return
+	}
+	h.passwordResetLimit.Set(key, struct{}{}, time.Now().Add(passwordResetInterval))
+
+	if !exists {
+		return
+	}
+
+	var userInfo database.GetVerifiedUserByEmailRow
+	err := h.DbTxError(func(tx *database.Queries) (err error) {
+		userInfo, err = tx.GetVerifiedUserByEmail(context.Background(), address.Address)
+		return
+	})
+	if err != nil {
+		logger.Logger.Warn("Reset password: Failed to find user", "err", err)
+		return
+	}
+
+	u := uuid.NewString()
+	h.mailLinkCache.Set(mailLinkKey{mailLinkResetPassword, u}, userInfo.Subject, time.Now().Add(10*time.Minute))
+
+	err = h.conf.Mail.SendEmailTemplate("mail-reset-password", "Reset Password", userInfo.Name, address, map[string]any{
+		"ResetUrl": h.conf.BaseUrl + "/mail/password/" + u,
+	})
+	if err != nil {
+		logger.Logger.Warn("Reset password: Failed to send reset password email", "err", err)
+	}
 }
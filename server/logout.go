@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"github.com/1f349/mjwt"
+	"github.com/1f349/tulip/pages"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// LogoutGet is the OpenID Connect end session endpoint, the user is asked to
+// confirm the logout before the cookies are removed
+func (h *HttpServer) LogoutGet(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	q := req.URL.Query()
+	if auth.IsGuest() {
+		if u := h.postLogoutRedirectUrl(req.Context(), q, ""); u != nil {
+			http.Redirect(rw, req, u.String(), http.StatusFound)
+			return
+		}
+		http.Redirect(rw, req, "/", http.StatusFound)
+		return
+	}
+
+	lNonce := uuid.NewString()
+	http.SetCookie(rw, &http.Cookie{
+		Name:     "tulip-nonce",
+		Value:    lNonce,
+		Path:     "/",
+		Expires:  time.Now().Add(10 * time.Minute),
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	rw.Header().Set("Content-Type", "text/html")
+	rw.WriteHeader(http.StatusOK)
+	pages.RenderPageTemplate(rw, "logout", map[string]any{
+		"ServiceName":           h.conf.ServiceName,
+		"Nonce":                 lNonce,
+		"ClientID":              q.Get("client_id"),
+		"IdTokenHint":           q.Get("id_token_hint"),
+		"PostLogoutRedirectUri": q.Get("post_logout_redirect_uri"),
+		"State":                 q.Get("state"),
+	})
+}
+
+func (h *HttpServer) LogoutPost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	cookie, err := req.Cookie("tulip-nonce")
+	if err != nil {
+		http.Error(rw, "Missing nonce", http.StatusBadRequest)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(req.PostFormValue("nonce"))) == 1 {
+		h.clearLoginCookies(rw)
+
+		if u := h.postLogoutRedirectUrl(req.Context(), req.PostForm, auth.Subject); u != nil {
+			http.Redirect(rw, req, u.String(), http.StatusFound)
+			return
+		}
+		http.Redirect(rw, req, "/", http.StatusFound)
+		return
+	}
+	http.Error(rw, "Logout failed", http.StatusInternalServerError)
+}
+
+// clearLoginCookies removes the login cookies, the host-only cookies from before
+// the cookie domain was configured are removed too
+func (h *HttpServer) clearLoginCookies(rw http.ResponseWriter) {
+	domains := []string{h.sessions.cookieDomain}
+	if h.sessions.cookieDomain != "" {
+		domains = append(domains, "")
+	}
+	for _, domain := range domains {
+		for _, name := range []string{"tulip-login-access", "tulip-login-refresh"} {
+			http.SetCookie(rw, &http.Cookie{
+				Name:     name,
+				Path:     "/",
+				Domain:   domain,
+				MaxAge:   -1,
+				Secure:   true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+	}
+}
+
+// postLogoutRedirectUrl returns the post logout redirect url if it has the same
+// origin as the redirect url of the client, the client is found using the
+// client_id or id_token_hint parameters
+//
+// If subject is not empty the id_token_hint must have been issued to that user.
+func (h *HttpServer) postLogoutRedirectUrl(ctx context.Context, v url.Values, subject string) *url.URL {
+	redirectUri := v.Get("post_logout_redirect_uri")
+	if redirectUri == "" {
+		return nil
+	}
+
+	clientId := v.Get("client_id")
+	if v.Get("id_token_hint") != "" {
+		_, idToken, err := mjwt.ExtractClaims[IdTokenClaims](h.signingKey, v.Get("id_token_hint"))
+		if err != nil || len(idToken.Audience) != 1 || (clientId != "" && clientId != idToken.Audience[0]) {
+			return nil
+		}
+		clientId = idToken.Audience[0]
+		if subject != "" {
+			// pairwise clients only know the pairwise identifier of the user
+			owner, err := h.subjectOwner(ctx, clientId, idToken.Subject)
+			if err != nil || owner != subject {
+				return nil
+			}
+		}
+	}
+	if clientId == "" {
+		return nil
+	}
+
+	client, err := h.oauthMgr.GetClient(ctx, clientId)
+	if err != nil {
+		return nil
+	}
+	clientUrl, err := url.Parse(client.GetDomain())
+	if err != nil {
+		return nil
+	}
+	u, err := url.Parse(redirectUri)
+	if err != nil || u.Scheme != clientUrl.Scheme || u.Host != clientUrl.Host {
+		return nil
+	}
+
+	if state := v.Get("state"); state != "" {
+		q := u.Query()
+		q.Set("state", state)
+		u.RawQuery = q.Encode()
+	}
+	return u
+}
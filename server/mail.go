@@ -51,25 +51,30 @@ func (h *HttpServer) MailPasswordPost(rw http.ResponseWriter, req *http.Request,
 	rpw := req.PostFormValue("confirm_password")
 	code := req.PostFormValue("code")
 
-	// reverse passwords are possible
-	if len(pw) == 0 {
-		http.Error(rw, "Cannot set an empty password", http.StatusBadRequest)
+	k := mailLinkKey{mailLinkResetPassword, code}
+	userSub, ok := h.mailLinkCache.Get(k)
+	if !ok {
+		http.Error(rw, "Invalid password reset code", http.StatusBadRequest)
 		return
 	}
-	// bcrypt only allows up to 72 bytes anyway
-	if len(pw) > 64 {
-		http.Error(rw, "Security by extremely long password is a weird flex", http.StatusBadRequest)
+
+	var userInfo database.User
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		userInfo, err = tx.GetUser(req.Context(), userSub)
+		return
+	}) {
 		return
 	}
-	if rpw != pw {
-		http.Error(rw, "Passwords do not match", http.StatusBadRequest)
+
+	if userInfo.AuthSource != "" {
+		h.mailLinkCache.Delete(k)
+		http.Error(rw, "400 Bad Request: "+errPasswordExternal.Error(), http.StatusBadRequest)
 		return
 	}
 
-	k := mailLinkKey{mailLinkResetPassword, code}
-	userSub, ok := h.mailLinkCache.Get(k)
-	if !ok {
-		http.Error(rw, "Invalid password reset code", http.StatusBadRequest)
+	// the code is kept so the user can try again with a different password
+	if err := h.checkNewPassword(pw, rpw, userInfo.Username, userInfo.Email); err != nil {
+		http.Error(rw, "400 Bad Request: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -77,7 +82,20 @@ func (h *HttpServer) MailPasswordPost(rw http.ResponseWriter, req *http.Request,
 
 	// reset password database call
 	if h.DbTx(rw, func(tx *database.Queries) error {
-		return tx.ChangePassword(req.Context(), userSub, pw)
+		err := tx.ChangePassword(req.Context(), userSub, pw)
+		if err != nil {
+			return err
+		}
+		err = tx.ResetFailedLogins(req.Context(), userSub)
+		if err != nil {
+			return err
+		}
+		err = tx.DeleteTrustedDevices(req.Context(), userSub)
+		if err != nil {
+			return err
+		}
+		_, err = revokeSessions(req.Context(), tx, userSub)
+		return err
 	}) {
 		return
 	}
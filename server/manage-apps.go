@@ -3,6 +3,7 @@ package server
 import (
 	"github.com/1f349/tulip/database"
 	"github.com/1f349/tulip/database/types"
+	"github.com/1f349/tulip/openid"
 	"github.com/1f349/tulip/pages"
 	"github.com/1f349/tulip/password"
 	"github.com/google/uuid"
@@ -44,7 +45,33 @@ func (h *HttpServer) ManageAppsGet(rw http.ResponseWriter, req *http.Request, _
 	if q.Has("edit") {
 		for _, i := range appList {
 			if i.Subject == q.Get("edit") {
+				var access []database.GetClientAccessRow
+				var groups []database.GetAllGroupsRow
+				if h.DbTx(rw, func(tx *database.Queries) (err error) {
+					access, err = tx.GetClientAccess(req.Context(), i.Subject)
+					if err != nil || role != types.RoleAdmin {
+						return
+					}
+					groups, err = tx.GetAllGroups(req.Context())
+					return
+				}) {
+					return
+				}
+				accessRoles := make(map[string]bool)
+				accessGroups := make(map[string]bool)
+				for _, a := range access {
+					switch a.Kind {
+					case accessKindRole:
+						accessRoles[a.Value] = true
+					case accessKindGroup:
+						accessGroups[a.Value] = true
+					}
+				}
+
 				m["EditApp"] = i
+				m["Groups"] = groups
+				m["EditAccessRoles"] = accessRoles
+				m["EditAccessGroups"] = accessGroups
 				rw.Header().Set("Content-Type", "text/html")
 				rw.WriteHeader(http.StatusOK)
 				pages.RenderPageTemplate(rw, "manage-apps-edit", m)
@@ -62,8 +89,13 @@ func (h *HttpServer) ManageAppsGet(rw http.ResponseWriter, req *http.Request, _
 
 func (h *HttpServer) ManageAppsCreateGet(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
 	var roles types.UserRole
+	var groups []database.GetAllGroupsRow
 	if h.DbTx(rw, func(tx *database.Queries) (err error) {
 		roles, err = tx.GetUserRole(req.Context(), auth.Subject)
+		if err != nil || roles != types.RoleAdmin {
+			return
+		}
+		groups, err = tx.GetAllGroups(req.Context())
 		return
 	}) {
 		return
@@ -72,6 +104,7 @@ func (h *HttpServer) ManageAppsCreateGet(rw http.ResponseWriter, req *http.Reque
 	m := map[string]any{
 		"ServiceName": h.conf.ServiceName,
 		"IsAdmin":     roles == types.RoleAdmin,
+		"Groups":      groups,
 	}
 
 	rw.Header().Set("Content-Type", "text/html")
@@ -93,19 +126,44 @@ func (h *HttpServer) ManageAppsPost(rw http.ResponseWriter, req *http.Request, _
 	public := req.Form.Has("public")
 	sso := req.Form.Has("sso")
 	active := req.Form.Has("active")
+	subjectType := req.Form.Get("subject_type")
 
-	if sso {
-		var role types.UserRole
-		if h.DbTx(rw, func(tx *database.Queries) (err error) {
-			role, err = tx.GetUserRole(req.Context(), auth.Subject)
-			return
-		}) {
-			return
-		}
-		if role != types.RoleAdmin {
-			http.Error(rw, "400 Bad Request: Only admin users can create SSO client applications", http.StatusBadRequest)
+	switch subjectType {
+	case "":
+		subjectType = openid.SubjectPublic
+	case openid.SubjectPublic, openid.SubjectPairwise:
+	default:
+		http.Error(rw, "400 Bad Request: Invalid subject type", http.StatusBadRequest)
+		return
+	}
+
+	// only admins can see the groups and choose which groups are allowed
+	var role types.UserRole
+	var groups []database.GetAllGroupsRow
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		role, err = tx.GetUserRole(req.Context(), auth.Subject)
+		if err != nil || role != types.RoleAdmin {
 			return
 		}
+		groups, err = tx.GetAllGroups(req.Context())
+		return
+	}) {
+		return
+	}
+	isAdmin := role == types.RoleAdmin
+	if !isAdmin && req.Form.Has("access_group") {
+		http.Error(rw, "400 Bad Request: Only admin users can choose the allowed groups", http.StatusBadRequest)
+		return
+	}
+	access, err := parseClientAccess(req.Form, groups)
+	if err != nil {
+		http.Error(rw, "400 Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if sso && !isAdmin {
+		http.Error(rw, "400 Bad Request: Only admin users can create SSO client applications", http.StatusBadRequest)
+		return
 	}
 
 	switch action {
@@ -115,33 +173,64 @@ func (h *HttpServer) ManageAppsPost(rw http.ResponseWriter, req *http.Request, _
 			if err != nil {
 				return err
 			}
-			return tx.InsertClientApp(req.Context(), database.InsertClientAppParams{
-				Subject: uuid.NewString(),
-				Name:    name,
-				Secret:  secret,
-				Domain:  domain,
-				Owner:   auth.Subject,
-				Public:  public,
-				Sso:     sso,
-				Active:  active,
+			sub := uuid.NewString()
+			err = tx.InsertClientApp(req.Context(), database.InsertClientAppParams{
+				Subject:     sub,
+				Name:        name,
+				Secret:      secret,
+				Domain:      domain,
+				Owner:       auth.Subject,
+				Public:      public,
+				Sso:         sso,
+				Active:      active,
+				SubjectType: subjectType,
 			})
+			if err != nil {
+				return err
+			}
+			return setClientAccess(req.Context(), tx, sub, access)
 		}) {
 			return
 		}
 	case "edit":
+		var owned bool
 		if h.DbTx(rw, func(tx *database.Queries) error {
-			return tx.UpdateClientApp(req.Context(), database.UpdateClientAppParams{
-				Name:    name,
-				Domain:  domain,
-				Public:  public,
-				Sso:     sso,
-				Active:  active,
-				Subject: req.FormValue("subject"),
-				Owner:   auth.Subject,
+			sub := req.Form.Get("subject")
+			info, err := tx.GetClientInfo(req.Context(), sub)
+			if err != nil {
+				return err
+			}
+			if info.Owner != auth.Subject {
+				return nil
+			}
+			owned = true
+			err = tx.UpdateClientApp(req.Context(), database.UpdateClientAppParams{
+				Name:        name,
+				Domain:      domain,
+				Public:      public,
+				Sso:         sso,
+				Active:      active,
+				SubjectType: subjectType,
+				Subject:     sub,
+				Owner:       auth.Subject,
 			})
+			if err != nil {
+				return err
+			}
+			if !isAdmin {
+				access, err = keepGroupAccess(req.Context(), tx, sub, access)
+				if err != nil {
+					return err
+				}
+			}
+			return setClientAccess(req.Context(), tx, sub, access)
 		}) {
 			return
 		}
+		if !owned {
+			http.Error(rw, "403 Forbidden: Only the owner can edit a client application", http.StatusForbidden)
+			return
+		}
 	case "secret":
 		var info database.ClientStore
 		var secret string
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/pages"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+func (h *HttpServer) ManageGroupsGet(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	q := req.URL.Query()
+	offset, _ := strconv.Atoi(q.Get("offset"))
+
+	var groupList []database.Group
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		groupList, err = tx.GetGroupList(req.Context(), int64(offset))
+		return
+	}) {
+		return
+	}
+
+	m := map[string]any{
+		"ServiceName": h.conf.ServiceName,
+		"Groups":      groupList,
+		"Offset":      offset,
+	}
+	if q.Has("edit") {
+		var group database.Group
+		var members []database.GetGroupMembersRow
+		if h.DbTx(rw, func(tx *database.Queries) (err error) {
+			group, err = tx.GetGroup(req.Context(), q.Get("edit"))
+			if err != nil {
+				return
+			}
+			members, err = tx.GetGroupMembers(req.Context(), group.Subject)
+			return
+		}) {
+			return
+		}
+
+		m["EditGroup"] = group
+		m["Members"] = members
+		rw.Header().Set("Content-Type", "text/html")
+		rw.WriteHeader(http.StatusOK)
+		pages.RenderPageTemplate(rw, "manage-groups-edit", m)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/html")
+	rw.WriteHeader(http.StatusOK)
+	pages.RenderPageTemplate(rw, "manage-groups", m)
+}
+
+func (h *HttpServer) ManageGroupsCreateGet(rw http.ResponseWriter, _ *http.Request, _ httprouter.Params, _ UserAuth) {
+	m := map[string]any{
+		"ServiceName": h.conf.ServiceName,
+	}
+
+	rw.Header().Set("Content-Type", "text/html")
+	rw.WriteHeader(http.StatusOK)
+	pages.RenderPageTemplate(rw, "manage-groups-create", m)
+}
+
+func (h *HttpServer) ManageGroupsPost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, _ UserAuth) {
+	err := req.ParseForm()
+	if err != nil {
+		http.Error(rw, "400 Bad Request: Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	offset := req.Form.Get("offset")
+	action := req.Form.Get("action")
+	sub := req.Form.Get("subject")
+	name := strings.TrimSpace(req.Form.Get("name"))
+	description := req.Form.Get("description")
+	parent := strings.TrimSpace(req.Form.Get("parent"))
+
+	redirectUrl := url.URL{Path: "/manage/groups", RawQuery: url.Values{"offset": []string{offset}}.Encode()}
+
+	switch action {
+	case "create", "edit":
+		if name == "" {
+			http.Error(rw, "400 Bad Request: Invalid group name", http.StatusBadRequest)
+			return
+		}
+		if action == "create" {
+			sub = uuid.NewString()
+		}
+		if parent != "" && !h.validGroupParent(rw, req, sub, parent) {
+			return
+		}
+		parentValue := sql.NullString{String: parent, Valid: parent != ""}
+
+		if h.DbTx(rw, func(tx *database.Queries) error {
+			if action == "create" {
+				return tx.AddGroup(req.Context(), database.AddGroupParams{
+					Subject:     sub,
+					Name:        name,
+					Description: description,
+					Parent:      parentValue,
+				})
+			}
+			return tx.UpdateGroup(req.Context(), database.UpdateGroupParams{
+				Name:        name,
+				Description: description,
+				Parent:      parentValue,
+				Subject:     sub,
+			})
+		}) {
+			return
+		}
+	case "delete":
+		if h.DbTx(rw, func(tx *database.Queries) error {
+			// child groups are moved to the top level
+			err := tx.ClearGroupParent(req.Context(), sql.NullString{String: sub, Valid: true})
+			if err != nil {
+				return err
+			}
+			err = tx.DeleteGroupMembers(req.Context(), sub)
+			if err != nil {
+				return err
+			}
+			return tx.DeleteGroup(req.Context(), sub)
+		}) {
+			return
+		}
+	case "add-member":
+		username := strings.TrimSpace(req.Form.Get("username"))
+		var userExists bool
+		if h.DbTx(rw, func(tx *database.Queries) (err error) {
+			userExists, err = tx.UsernameExists(req.Context(), username)
+			if err != nil || !userExists {
+				return
+			}
+			return tx.AddGroupMemberByUsername(req.Context(), database.AddGroupMemberByUsernameParams{
+				GroupSubject: sub,
+				Username:     username,
+			})
+		}) {
+			return
+		}
+		if !userExists {
+			http.Error(rw, "400 Bad Request: Unknown username", http.StatusBadRequest)
+			return
+		}
+		// return to the edit page after changing members
+		redirectUrl.RawQuery = url.Values{"offset": []string{offset}, "edit": []string{sub}}.Encode()
+	case "remove-member":
+		if h.DbTx(rw, func(tx *database.Queries) error {
+			return tx.RemoveGroupMember(req.Context(), database.RemoveGroupMemberParams{
+				GroupSubject: sub,
+				UserSubject:  req.Form.Get("user"),
+			})
+		}) {
+			return
+		}
+		redirectUrl.RawQuery = url.Values{"offset": []string{offset}, "edit": []string{sub}}.Encode()
+	default:
+		http.Error(rw, "400 Bad Request: Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(rw, req, redirectUrl.String(), http.StatusFound)
+}
+
+// validGroupParent checks the parent group exists and that using it as the
+// parent of the group would not create a cycle
+func (h *HttpServer) validGroupParent(rw http.ResponseWriter, req *http.Request, sub, parent string) bool {
+	var exists bool
+	var ancestors []string
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		_, err = tx.GetGroup(req.Context(), parent)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return
+		}
+		exists = true
+		ancestors, err = tx.GetGroupAncestors(req.Context(), parent)
+		return
+	}) {
+		return false
+	}
+	if !exists {
+		http.Error(rw, "400 Bad Request: Invalid parent group", http.StatusBadRequest)
+		return false
+	}
+	for _, i := range ancestors {
+		if i == sub {
+			http.Error(rw, "400 Bad Request: A group cannot be nested inside itself", http.StatusBadRequest)
+			return false
+		}
+	}
+	return true
+}
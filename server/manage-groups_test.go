@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"github.com/1f349/tulip/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func postManageGroups(h *HttpServer, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/manage/groups", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.ManageGroupsPost(rec, req, nil, UserAuth{})
+	return rec
+}
+
+func TestHttpServer_ValidGroupParent(t *testing.T) {
+	h := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, h.db.AddGroup(ctx, database.AddGroupParams{Subject: "staff", Name: "Staff"}))
+	require.NoError(t, h.db.AddGroup(ctx, database.AddGroupParams{Subject: "ops", Name: "Ops", Parent: sql.NullString{String: "staff", Valid: true}}))
+
+	valid := func(sub, parent string) int {
+		rec := httptest.NewRecorder()
+		if h.validGroupParent(rec, httptest.NewRequest(http.MethodPost, "/", nil), sub, parent) {
+			return http.StatusOK
+		}
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusOK, valid("new", "ops"))
+	assert.Equal(t, http.StatusBadRequest, valid("new", "missing"))
+	assert.Equal(t, http.StatusBadRequest, valid("staff", "staff"))
+	assert.Equal(t, http.StatusBadRequest, valid("staff", "ops"))
+}
+
+func TestHttpServer_ManageGroupsPost(t *testing.T) {
+	h := newTestServer(t)
+	ctx := context.Background()
+	subject := addTestUser(t, h, "alice")
+
+	rec := postManageGroups(h, url.Values{"action": {"create"}, "name": {"Staff"}})
+	assert.Equal(t, http.StatusFound, rec.Code)
+	groups, err := h.db.GetGroupList(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	staff := groups[0].Subject
+
+	rec = postManageGroups(h, url.Values{"action": {"create"}, "name": {"Ops"}, "parent": {staff}})
+	assert.Equal(t, http.StatusFound, rec.Code)
+	groups, err = h.db.GetGroupList(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	ops := groups[0].Subject
+	assert.Equal(t, "Ops", groups[0].Name)
+
+	assert.Equal(t, http.StatusBadRequest, postManageGroups(h, url.Values{"action": {"create"}, "name": {" "}}).Code)
+	assert.Equal(t, http.StatusBadRequest, postManageGroups(h, url.Values{"action": {"create"}, "name": {"Bad"}, "parent": {"missing"}}).Code)
+	assert.Equal(t, http.StatusBadRequest, postManageGroups(h, url.Values{"action": {"edit"}, "subject": {staff}, "name": {"Staff"}, "parent": {ops}}).Code)
+	assert.Equal(t, http.StatusBadRequest, postManageGroups(h, url.Values{"action": {"unknown"}}).Code)
+
+	// members of a child group are members of the parent group
+	rec = postManageGroups(h, url.Values{"action": {"add-member"}, "subject": {ops}, "username": {"alice"}})
+	assert.Equal(t, http.StatusFound, rec.Code)
+	names, err := h.db.GetUserGroupNames(ctx, subject)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Ops", "Staff"}, names)
+
+	rec = postManageGroups(h, url.Values{"action": {"add-member"}, "subject": {ops}, "username": {"bob"}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Unknown username")
+
+	rec = postManageGroups(h, url.Values{"action": {"remove-member"}, "subject": {ops}, "user": {subject}})
+	assert.Equal(t, http.StatusFound, rec.Code)
+	names, err = h.db.GetUserGroupNames(ctx, subject)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	// deleting the parent moves the child group to the top level
+	rec = postManageGroups(h, url.Values{"action": {"delete"}, "subject": {staff}})
+	assert.Equal(t, http.StatusFound, rec.Code)
+	group, err := h.db.GetGroup(ctx, ops)
+	require.NoError(t, err)
+	assert.False(t, group.Parent.Valid)
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/1f349/tulip/database/types"
 	"github.com/1f349/tulip/logger"
 	"github.com/1f349/tulip/pages"
+	"github.com/1f349/tulip/password"
 	"github.com/emersion/go-message/mail"
 	"github.com/google/uuid"
 	"github.com/julienschmidt/httprouter"
@@ -22,12 +23,17 @@ func (h *HttpServer) ManageUsersGet(rw http.ResponseWriter, req *http.Request, _
 
 	var role types.UserRole
 	var userList []database.GetUserListRow
+	var serviceAccounts []database.GetServiceAccountsRow
 	if h.DbTx(rw, func(tx *database.Queries) (err error) {
 		role, err = tx.GetUserRole(req.Context(), auth.Subject)
 		if err != nil {
 			return
 		}
 		userList, err = tx.GetUserList(req.Context(), int64(offset))
+		if err != nil {
+			return
+		}
+		serviceAccounts, err = tx.GetServiceAccounts(req.Context())
 		return
 	}) {
 		return
@@ -41,22 +47,49 @@ func (h *HttpServer) ManageUsersGet(rw http.ResponseWriter, req *http.Request, _
 	m := map[string]any{
 		"ServiceName":  h.conf.ServiceName,
 		"Users":        userList,
+		"Services":     serviceAccounts,
 		"Offset":       offset,
 		"EmailShow":    req.URL.Query().Has("show-email"),
 		"CurrentAdmin": auth.Subject,
 		"Namespace":    h.conf.Namespace,
 	}
 	if q.Has("edit") {
+		editSubject := q.Get("edit")
 		for _, i := range userList {
-			if i.Subject == q.Get("edit") {
+			if i.Subject == editSubject {
 				m["EditUser"] = i
-				rw.Header().Set("Content-Type", "text/html")
-				rw.WriteHeader(http.StatusOK)
-				pages.RenderPageTemplate(rw, "manage-users-edit", m)
+			}
+		}
+		for _, i := range serviceAccounts {
+			if i.Subject == editSubject {
+				m["EditUser"] = i
+				m["ServiceAccount"] = true
+				m["Scopes"] = personalTokenScopes()
+				m["Expiry"] = personalTokenExpiry
+			}
+		}
+		if m["EditUser"] == nil {
+			http.Error(rw, "400 Bad Request: Invalid user to edit", http.StatusBadRequest)
+			return
+		}
+
+		var passkeys []database.WebauthnCredential
+		var tokens []database.GetPersonalTokensRow
+		if h.DbTx(rw, func(tx *database.Queries) (err error) {
+			passkeys, err = tx.GetWebauthnCredentials(req.Context(), editSubject)
+			if err != nil {
 				return
 			}
+			tokens, err = tx.GetPersonalTokens(req.Context(), editSubject)
+			return
+		}) {
+			return
 		}
-		http.Error(rw, "400 Bad Request: Invalid user to edit", http.StatusBadRequest)
+		m["Passkeys"] = passkeys
+		m["Tokens"] = tokens
+		rw.Header().Set("Content-Type", "text/html")
+		rw.WriteHeader(http.StatusOK)
+		pages.RenderPageTemplate(rw, "manage-users-edit", m)
 		return
 	}
 
@@ -110,7 +143,8 @@ func (h *HttpServer) ManageUsersPost(rw http.ResponseWriter, req *http.Request,
 	username := req.Form.Get("username")
 	email := req.Form.Get("email")
 	newRole, err := parseRoleValue(req.Form.Get("role"))
-	if err != nil {
+	// only the create and edit actions change the role
+	if err != nil && (action == "create" || action == "edit") {
 		http.Error(rw, "400 Bad Request: Invalid role", http.StatusBadRequest)
 		return
 	}
@@ -131,12 +165,20 @@ func (h *HttpServer) ManageUsersPost(rw http.ResponseWriter, req *http.Request,
 		}
 		addrDomain := address.Address[n+1:]
 
+		// the user chooses a password using the emailed link, until then the
+		// account has a random password
+		placeholderPw, err := password.GenerateApiSecret(32)
+		if err != nil {
+			http.Error(rw, "500 Internal Server Error: Failed to generate password", http.StatusInternalServerError)
+			return
+		}
+
 		var userSub string
 		if h.DbTx(rw, func(tx *database.Queries) (err error) {
 			userSub, err = tx.AddUser(req.Context(), database.AddUserParams{
 				Name:          name,
 				Username:      username,
-				Password:      "",
+				Password:      placeholderPw,
 				Email:         email,
 				EmailVerified: addrDomain == h.conf.Namespace,
 				Role:          newRole,
@@ -171,6 +213,68 @@ func (h *HttpServer) ManageUsersPost(rw http.ResponseWriter, req *http.Request,
 		}) {
 			return
 		}
+	case "unlock":
+		if h.DbTx(rw, func(tx *database.Queries) error {
+			return tx.ResetFailedLogins(req.Context(), req.Form.Get("subject"))
+		}) {
+			return
+		}
+	case "create-service-account":
+		if name == "" || username == "" {
+			http.Error(rw, "400 Bad Request: Name and username are required", http.StatusBadRequest)
+			return
+		}
+		var usernameExists bool
+		if h.DbTx(rw, func(tx *database.Queries) (err error) {
+			usernameExists, err = tx.UsernameExists(req.Context(), username)
+			if err != nil || usernameExists {
+				return
+			}
+			_, err = tx.AddServiceAccount(req.Context(), name, username)
+			return
+		}) {
+			return
+		}
+		if usernameExists {
+			http.Error(rw, "400 Bad Request: Username is already taken", http.StatusBadRequest)
+			return
+		}
+	case "create-token":
+		sub := req.Form.Get("subject")
+		var target database.User
+		if h.DbTx(rw, func(tx *database.Queries) (err error) {
+			target, err = tx.GetUser(req.Context(), sub)
+			return
+		}) {
+			return
+		}
+		if !target.ServiceAccount {
+			http.Error(rw, "400 Bad Request: Tokens can only be created for service accounts", http.StatusBadRequest)
+			return
+		}
+		tokenName, token, failed := h.createPersonalToken(rw, req, sub)
+		if failed {
+			return
+		}
+
+		// the token is only shown in this response
+		pages.RenderPageTemplate(rw, "manage-users-token", map[string]any{
+			"ServiceName": h.conf.ServiceName,
+			"Offset":      offset,
+			"EditUser":    target,
+			"Name":        tokenName,
+			"Token":       token,
+		})
+		return
+	case "revoke-token":
+		if h.DbTx(rw, func(tx *database.Queries) error {
+			return tx.DeletePersonalToken(req.Context(), database.DeletePersonalTokenParams{
+				ID:      req.Form.Get("token"),
+				Subject: req.Form.Get("subject"),
+			})
+		}) {
+			return
+		}
 	default:
 		http.Error(rw, "400 Bad Request: Invalid action", http.StatusBadRequest)
 		return
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/database/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestHttpServer_ManageUsersPost_CreateServiceAccount(t *testing.T) {
+	h := newTestServer(t)
+	ctx := context.Background()
+	admin := addTestUser(t, h, "admin")
+	require.NoError(t, h.db.UpdateUserRole(ctx, database.UpdateUserRoleParams{Active: true, Role: types.RoleAdmin, Subject: admin}))
+
+	form := url.Values{"action": {"create-service-account"}, "name": {"Backup"}, "username": {"backup"}}
+	rec := postEditForm(h.ManageUsersPost, admin, form)
+	assert.Equal(t, http.StatusFound, rec.Code)
+	exists, err := h.db.UsernameExists(ctx, "backup")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// usernames of service accounts and users can't be reused
+	rec = postEditForm(h.ManageUsersPost, admin, form)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Username is already taken")
+	form.Set("username", "admin")
+	rec = postEditForm(h.ManageUsersPost, admin, form)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/1f349/tulip/database"
 	"github.com/1f349/tulip/pages"
 	"github.com/1f349/tulip/scope"
+	"github.com/go-oauth2/oauth2/v4"
 	"github.com/julienschmidt/httprouter"
 	"net/http"
 	"net/url"
@@ -52,6 +53,35 @@ func (h *HttpServer) authorizeEndpoint(rw http.ResponseWriter, req *http.Request
 		return
 	}
 
+	allowed, err := h.userCanAccessClient(req.Context(), client.GetID(), auth.Subject)
+	if err != nil {
+		http.Error(rw, "500 Internal Server Error: Failed to check application access", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		// the user can return to the application with an access_denied error
+		uDenied, err := url.Parse(redirectUri)
+		if err != nil {
+			http.Error(rw, "400 Bad Request: Invalid redirect URI", http.StatusBadRequest)
+			return
+		}
+		q := uDenied.Query()
+		q.Set("error", "access_denied")
+		q.Set("error_description", "The user does not have access to this application")
+		if form.Has("state") {
+			q.Set("state", form.Get("state"))
+		}
+		uDenied.RawQuery = q.Encode()
+
+		rw.WriteHeader(http.StatusForbidden)
+		pages.RenderPageTemplate(rw, "oauth-access-denied", map[string]any{
+			"ServiceName": h.conf.ServiceName,
+			"AppName":     clientDisplayName(client, uDenied),
+			"ReturnUrl":   uDenied.String(),
+		})
+		return
+	}
+
 	var isSSO bool
 	if clientIsSSO, ok := client.(interface{ IsSSO() bool }); ok {
 		isSSO = clientIsSSO.IsSSO()
@@ -69,13 +99,7 @@ func (h *HttpServer) authorizeEndpoint(rw http.ResponseWriter, req *http.Request
 			return
 		}
 		appDomain := appUrlFull.Scheme + "://" + appUrlFull.Host
-		appName := appUrlFull.Host
-		if clientGetName, ok := client.(interface{ GetName() string }); ok {
-			n := clientGetName.GetName()
-			if n != "" {
-				appName = n
-			}
-		}
+		appName := clientDisplayName(client, appUrlFull)
 
 		var user string
 		var hasOtp bool
@@ -89,6 +113,9 @@ func (h *HttpServer) authorizeEndpoint(rw http.ResponseWriter, req *http.Request
 		}) {
 			return
 		}
+		if hasOtp && h.isTrustedDevice(req, auth.Subject) {
+			hasOtp = false
+		}
 
 		scopeList := form.Get("scope")
 		if !scope.ScopesExist(scopeList) {
@@ -115,9 +142,9 @@ func (h *HttpServer) authorizeEndpoint(rw http.ResponseWriter, req *http.Request
 		return
 	}
 
-	if !isSSO {
+	if !isSSO && !h.isTrustedDevice(req, auth.Subject) {
 		otpInput := req.FormValue("code")
-		if h.fetchAndValidateOtp(rw, auth.Subject, otpInput) {
+		if h.fetchAndValidateOtp(rw, req, auth.Subject, otpInput) {
 			return
 		}
 	}
@@ -141,6 +168,18 @@ func (h *HttpServer) authorizeEndpoint(rw http.ResponseWriter, req *http.Request
 	http.Redirect(rw, req, parsedRedirect.String(), http.StatusFound)
 }
 
+// clientDisplayName returns the client name or the host of the redirect URL if
+// the client has no name
+func clientDisplayName(client oauth2.ClientInfo, appUrl *url.URL) string {
+	if clientGetName, ok := client.(interface{ GetName() string }); ok {
+		n := clientGetName.GetName()
+		if n != "" {
+			return n
+		}
+	}
+	return appUrl.Host
+}
+
 func (h *HttpServer) oauthUserAuthorization(rw http.ResponseWriter, req *http.Request) (string, error) {
 	err := req.ParseForm()
 	if err != nil {
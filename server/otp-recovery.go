@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/logger"
+	"github.com/1f349/tulip/pages"
+	"github.com/emersion/go-message/mail"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strings"
+)
+
+const (
+	recoveryCodeCount = 10
+	recoveryCodeChars = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// randomRecoveryChars returns n random characters from recoveryCodeChars,
+// bytes above the largest multiple of the alphabet size are discarded so each
+// character is equally likely
+func randomRecoveryChars(n int) (string, error) {
+	limit := 256 - 256%len(recoveryCodeChars)
+	out := make([]byte, 0, n)
+	b := make([]byte, n)
+	for len(out) < n {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		for _, c := range b {
+			if int(c) < limit && len(out) < n {
+				out = append(out, recoveryCodeChars[int(c)%len(recoveryCodeChars)])
+			}
+		}
+	}
+	return string(out), nil
+}
+
+// generateRecoveryCodes returns a set of random codes in the format
+// xxxxx-xxxxx, ambiguous characters are not used
+func generateRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		c, err := randomRecoveryChars(10)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = c[:5] + "-" + c[5:]
+	}
+	return codes, nil
+}
+
+// normaliseRecoveryCode lowercases the code and removes the dashes and spaces
+// which users may type between the groups
+func normaliseRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+}
+
+// hashRecoveryCode normalises the code and returns the hex encoded hash, the
+// codes contain enough entropy for a plain sha256 hash to be used
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normaliseRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// isRecoveryChars returns true if the normalised code has n characters which
+// are all from recoveryCodeChars
+func isRecoveryChars(code string, n int) bool {
+	if len(code) != n {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		if strings.IndexByte(recoveryCodeChars, code[i]) == -1 {
+			return false
+		}
+	}
+	return true
+}
+
+// looksLikeRecoveryCode is used to skip the database lookup for OTP codes
+func looksLikeRecoveryCode(code string) bool {
+	return isRecoveryChars(normaliseRecoveryCode(code), 10)
+}
+
+// replaceRecoveryCodes removes any existing recovery codes for the user and
+// generates a new set
+func replaceRecoveryCodes(ctx context.Context, tx *database.Queries, subject string) ([]string, error) {
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = tx.DeleteOtpRecoveryCodes(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		err = tx.AddOtpRecoveryCode(ctx, database.AddOtpRecoveryCodeParams{
+			Subject:  subject,
+			CodeHash: hashRecoveryCode(code),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// useRecoveryCode consumes the recovery code and notifies the user by email,
+// the returned bool is true if the code was valid
+func (h *HttpServer) useRecoveryCode(ctx context.Context, subject, code string) (bool, error) {
+	var used bool
+	var userInfo database.User
+	var remaining int64
+	err := h.DbTxError(func(tx *database.Queries) error {
+		n, err := tx.UseOtpRecoveryCode(ctx, database.UseOtpRecoveryCodeParams{
+			Subject:  subject,
+			CodeHash: hashRecoveryCode(code),
+		})
+		if err != nil || n == 0 {
+			return err
+		}
+		used = true
+		userInfo, err = tx.GetUser(ctx, subject)
+		if err != nil {
+			return err
+		}
+		remaining, err = tx.CountOtpRecoveryCodes(ctx, subject)
+		return err
+	})
+	if err != nil || !used {
+		return false, err
+	}
+
+	address, err := mail.ParseAddress(userInfo.Email)
+	if err != nil {
+		logger.Logger.Warn("Failed to parse user email address", "sub", subject, "err", err)
+		return true, nil
+	}
+	err = h.conf.Mail.SendEmailTemplate("mail-recovery-code-used", "Recovery Code Used", userInfo.Name, address, map[string]any{
+		"Remaining": remaining,
+	})
+	if err != nil {
+		logger.Logger.Warn("Failed to send recovery code email", "sub", subject, "err", err)
+	}
+	return true, nil
+}
+
+func (h *HttpServer) EditOtpRecoveryPost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(rw, "400 Bad Request: Failed to parse form", http.StatusBadRequest)
+		return
+	}
+	if !req.Form.Has("code") {
+		// render page
+		pages.RenderPageTemplate(rw, "otp-recovery-codes", map[string]any{
+			"ServiceName": h.conf.ServiceName,
+		})
+		return
+	}
+
+	var hasOtp bool
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		hasOtp, err = tx.HasOtp(req.Context(), auth.Subject)
+		return
+	}) {
+		return
+	}
+	if !hasOtp {
+		http.Error(rw, "400 Bad Request: OTP is not enabled for this account", http.StatusBadRequest)
+		return
+	}
+
+	if h.fetchAndValidateOtp(rw, req, auth.Subject, req.Form.Get("code")) {
+		return
+	}
+
+	var codes []string
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		codes, err = replaceRecoveryCodes(req.Context(), tx, auth.Subject)
+		return
+	}) {
+		return
+	}
+
+	pages.RenderPageTemplate(rw, "otp-recovery-codes", map[string]any{
+		"ServiceName": h.conf.ServiceName,
+		"Codes":       codes,
+	})
+}
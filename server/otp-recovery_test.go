@@ -0,0 +1,37 @@
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := generateRecoveryCodes()
+	assert.NoError(t, err)
+	assert.Len(t, codes, recoveryCodeCount)
+	for _, code := range codes {
+		assert.True(t, looksLikeRecoveryCode(code), code)
+	}
+	assert.NotEqual(t, codes[0], codes[1])
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	assert.Equal(t, hashRecoveryCode("abcde-fghjk"), hashRecoveryCode("ABCDE-FGHJK"))
+	assert.Equal(t, hashRecoveryCode("abcde-fghjk"), hashRecoveryCode("abcde fghjk"))
+	assert.NotEqual(t, hashRecoveryCode("abcde-fghjk"), hashRecoveryCode("abcde-fghjm"))
+}
+
+func TestLooksLikeRecoveryCode(t *testing.T) {
+	assert.True(t, looksLikeRecoveryCode("abcde-fghjk"))
+	assert.True(t, looksLikeRecoveryCode("ABCDE FGHJK"))
+	assert.True(t, looksLikeRecoveryCode("abcdefghjk"))
+	assert.False(t, looksLikeRecoveryCode("123456"))
+	assert.False(t, looksLikeRecoveryCode("abcde-fghjl"))
+	assert.False(t, looksLikeRecoveryCode("abcde-fghjkm"))
+}
+
+func TestRandomRecoveryChars(t *testing.T) {
+	s, err := randomRecoveryChars(1000)
+	assert.NoError(t, err)
+	assert.True(t, isRecoveryChars(s, 1000))
+}
@@ -21,9 +21,23 @@ func (h *HttpServer) LoginOtpGet(rw http.ResponseWriter, req *http.Request, _ ht
 		return
 	}
 
+	var hasOtp, hasWebauthn bool
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		hasOtp, err = tx.HasOtp(req.Context(), auth.Subject)
+		if err != nil {
+			return
+		}
+		hasWebauthn, err = tx.HasWebauthn(req.Context(), auth.Subject)
+		return
+	}) {
+		return
+	}
+
 	pages.RenderPageTemplate(rw, "login-otp", map[string]any{
 		"ServiceName": h.conf.ServiceName,
 		"Redirect":    req.URL.Query().Get("redirect"),
+		"HasOtp":      hasOtp,
+		"HasWebauthn": hasWebauthn,
 	})
 }
 
@@ -33,23 +47,45 @@ func (h *HttpServer) LoginOtpPost(rw http.ResponseWriter, req *http.Request, _ h
 		return
 	}
 
+	// users with only passkeys must not skip the second factor
+	var hasOtp bool
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		hasOtp, err = tx.HasOtp(req.Context(), auth.Subject)
+		return
+	}) {
+		return
+	}
+	if !hasOtp {
+		http.Error(rw, "400 Bad Request: OTP is not enabled for this account", http.StatusBadRequest)
+		return
+	}
+
 	otpInput := req.FormValue("code")
-	if h.fetchAndValidateOtp(rw, auth.Subject, otpInput) {
+	if h.fetchAndValidateOtp(rw, req, auth.Subject, otpInput) {
 		return
 	}
 
 	auth.NeedOtp = false
 
+	if req.FormValue("trust_device") != "" && h.trustDevice(rw, req, auth.Subject) {
+		return
+	}
+
 	h.setLoginDataCookie(rw, auth)
 	h.SafeRedirect(rw, req)
 }
 
-func (h *HttpServer) fetchAndValidateOtp(rw http.ResponseWriter, sub, code string) bool {
+func (h *HttpServer) fetchAndValidateOtp(rw http.ResponseWriter, req *http.Request, sub, code string) bool {
 	var hasOtp bool
 	var otpRow database.GetOtpRow
 	var secret string
 	var digits int64
+	var lockout database.GetLoginLockoutBySubjectRow
 	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		lockout, err = tx.GetLoginLockoutBySubject(context.Background(), sub)
+		if err != nil {
+			return
+		}
 		hasOtp, err = tx.HasOtp(context.Background(), sub)
 		if err != nil {
 			return
@@ -65,11 +101,31 @@ func (h *HttpServer) fetchAndValidateOtp(rw http.ResponseWriter, sub, code strin
 	}
 
 	if hasOtp {
-		totp := gotp.NewTOTP(secret, int(digits), 30, nil)
-		if !verifyTotp(totp, code) {
-			http.Error(rw, "400 Bad Request: Invalid OTP code", http.StatusBadRequest)
+		if h.loginBlocked(rw, req, lockout.LockedUntil) || h.startLoginAttempt(rw, req, sub) {
 			return true
 		}
+
+		totp := gotp.NewTOTP(secret, int(digits), 30, nil)
+		if verifyTotp(totp, code) {
+			h.guard.resetFailures(req.Context(), sub)
+			return false
+		}
+
+		// fallback to a single use recovery code
+		if looksLikeRecoveryCode(code) {
+			used, err := h.useRecoveryCode(context.Background(), sub, code)
+			if err != nil {
+				http.Error(rw, "Database error", http.StatusInternalServerError)
+				return true
+			}
+			if used {
+				h.guard.resetFailures(req.Context(), sub)
+				return false
+			}
+		}
+		h.recordLoginFailure(req, sub)
+		http.Error(rw, "400 Bad Request: Invalid OTP code", http.StatusBadRequest)
+		return true
 	}
 
 	return false
@@ -86,16 +142,37 @@ func (h *HttpServer) EditOtpPost(rw http.ResponseWriter, req *http.Request, _ ht
 		}
 
 		otpInput := req.Form.Get("code")
-		if h.fetchAndValidateOtp(rw, auth.Subject, otpInput) {
+		if h.fetchAndValidateOtp(rw, req, auth.Subject, otpInput) {
+			return
+		}
+
+		// passkeys also count as a second factor
+		var required, hasWebauthn bool
+		if h.DbTx(rw, func(tx *database.Queries) (err error) {
+			required, err = h.twoFactorRequired(req.Context(), tx, auth.Subject)
+			if err != nil {
+				return
+			}
+			hasWebauthn, err = tx.HasWebauthn(req.Context(), auth.Subject)
+			return
+		}) {
+			return
+		}
+		if required && !hasWebauthn {
+			http.Error(rw, "400 Bad Request: Two-factor authentication is required for this account, add a passkey before removing OTP", http.StatusBadRequest)
 			return
 		}
 
 		if h.DbTx(rw, func(tx *database.Queries) error {
-			return tx.SetOtp(req.Context(), database.SetOtpParams{
-				Subject: auth.Subject,
-				Secret:  "",
-				Digits:  0,
-			})
+			err := tx.DeleteOtp(req.Context(), auth.Subject)
+			if err != nil {
+				return err
+			}
+			err = tx.DeleteTrustedDevices(req.Context(), auth.Subject)
+			if err != nil {
+				return err
+			}
+			return tx.DeleteOtpRecoveryCodes(req.Context(), auth.Subject)
 		}) {
 			return
 		}
@@ -175,17 +252,31 @@ func (h *HttpServer) EditOtpPost(rw http.ResponseWriter, req *http.Request, _ ht
 		return
 	}
 
+	var codes []string
 	if h.DbTx(rw, func(tx *database.Queries) error {
-		return tx.SetOtp(req.Context(), database.SetOtpParams{
+		err := tx.SetOtp(req.Context(), database.SetOtpParams{
 			Subject: auth.Subject,
 			Secret:  secret,
 			Digits:  int64(digits),
 		})
+		if err != nil {
+			return err
+		}
+		err = tx.DeleteTrustedDevices(req.Context(), auth.Subject)
+		if err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(req.Context(), tx, auth.Subject)
+		return err
 	}) {
 		return
 	}
 
-	http.Redirect(rw, req, "/", http.StatusFound)
+	// the recovery codes are only shown once
+	pages.RenderPageTemplate(rw, "otp-recovery-codes", map[string]any{
+		"ServiceName": h.conf.ServiceName,
+		"Codes":       codes,
+	})
 }
 
 func verifyTotp(totp *gotp.TOTP, code string) bool {
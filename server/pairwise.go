@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/openid"
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+// subjectMapper converts the user subject into the subject identifier seen by
+// the client
+type subjectMapper func(ctx context.Context, clientId, userId string) (string, error)
+
+// clientSubject returns the subject identifier of the user for the client,
+// pairwise clients receive a different identifier for each sector
+func (h *HttpServer) clientSubject(ctx context.Context, clientId, userId string) (string, error) {
+	sub, _, err := h.pairwiseSubject(ctx, clientId, userId)
+	return sub, err
+}
+
+// issueClientSubject is used when a token is issued, the pairwise mapping is
+// saved so the identifier can be resolved by subjectOwner later
+func (h *HttpServer) issueClientSubject(ctx context.Context, clientId, userId string) (string, error) {
+	sub, sector, err := h.pairwiseSubject(ctx, clientId, userId)
+	if err != nil || sector == "" {
+		return sub, err
+	}
+	err = h.DbTxError(func(tx *database.Queries) error {
+		return tx.AddPairwiseSubject(ctx, database.AddPairwiseSubjectParams{
+			Pairwise: sub,
+			Sector:   sector,
+			Subject:  userId,
+		})
+	})
+	return sub, err
+}
+
+// subjectOwner returns the user subject for an identifier sent by the client,
+// this is the reverse of clientSubject
+func (h *HttpServer) subjectOwner(ctx context.Context, clientId, sub string) (string, error) {
+	client, err := h.oauthMgr.GetClient(ctx, clientId)
+	if err != nil {
+		return "", err
+	}
+	sector, ok, err := pairwiseSector(client)
+	if err != nil || !ok {
+		return sub, err
+	}
+	var owner string
+	err = h.DbTxError(func(tx *database.Queries) (err error) {
+		owner, err = tx.GetPairwiseSubjectOwner(ctx, database.GetPairwiseSubjectOwnerParams{
+			Pairwise: sub,
+			Sector:   sector,
+		})
+		return
+	})
+	return owner, err
+}
+
+// pairwiseSubject returns the subject identifier and the sector, the sector
+// is empty if the real subject is used
+func (h *HttpServer) pairwiseSubject(ctx context.Context, clientId, userId string) (string, string, error) {
+	// client credentials tokens have no user
+	if userId == "" {
+		return "", "", nil
+	}
+	// personal access tokens have no client so the real subject is used
+	if clientId == "" {
+		return userId, "", nil
+	}
+
+	client, err := h.oauthMgr.GetClient(ctx, clientId)
+	if err != nil {
+		return "", "", err
+	}
+	sector, ok, err := pairwiseSector(client)
+	if err != nil || !ok {
+		return userId, "", err
+	}
+	return openid.PairwiseSubject([]byte(h.conf.PairwiseSalt), sector, userId), sector, nil
+}
+
+// pairwiseSector returns the sector identifier if the client uses pairwise
+// subject identifiers
+func pairwiseSector(client oauth2.ClientInfo) (string, bool, error) {
+	clientSubjectType, ok := client.(interface{ GetSubjectType() string })
+	if !ok || clientSubjectType.GetSubjectType() != openid.SubjectPairwise {
+		return "", false, nil
+	}
+	sector, err := openid.SectorIdentifier(client.GetDomain())
+	return sector, err == nil, err
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"github.com/1f349/tulip/openid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+)
+
+func TestPairwiseSubject(t *testing.T) {
+	h := newTestServer(t)
+	ctx := context.Background()
+	subject := addTestUser(t, h, "admin")
+	other := addTestUser(t, h, "other")
+	addTestClient(t, h, "app", subject, openid.SubjectPairwise)
+
+	// looking up the identifier doesn't save the mapping
+	pairwise, err := h.clientSubject(ctx, "app", subject)
+	require.NoError(t, err)
+	assert.NotEqual(t, subject, pairwise)
+	_, err = h.subjectOwner(ctx, "app", pairwise)
+	assert.Error(t, err)
+
+	// issuing a token saves the mapping
+	ti := issueTestToken(t, h, "app", subject, "openid")
+	owner, err := h.subjectOwner(ctx, "app", pairwise)
+	require.NoError(t, err)
+	assert.Equal(t, subject, owner)
+	assert.Equal(t, pairwise, introspectTestToken(t, h, "app", ti.GetAccess())["sub"])
+
+	// the id token hint must belong to the user logging out
+	idToken, err := generateIDToken(ti, h.signingKey, h.clientSubject, h.tokenClaims)
+	require.NoError(t, err)
+	v := url.Values{
+		"id_token_hint":            {idToken},
+		"post_logout_redirect_uri": {"https://app.example.com/logged-out"},
+	}
+	u := h.postLogoutRedirectUrl(ctx, v, subject)
+	if assert.NotNil(t, u) {
+		assert.Equal(t, "https://app.example.com/logged-out", u.String())
+	}
+	assert.Nil(t, h.postLogoutRedirectUrl(ctx, v, other))
+}
+
+func TestPairwiseSubject_Public(t *testing.T) {
+	h := newTestServer(t)
+	ctx := context.Background()
+	subject := addTestUser(t, h, "admin")
+	addTestClient(t, h, "app", subject, openid.SubjectPublic)
+
+	sub, err := h.issueClientSubject(ctx, "app", subject)
+	require.NoError(t, err)
+	assert.Equal(t, subject, sub)
+	owner, err := h.subjectOwner(ctx, "app", subject)
+	require.NoError(t, err)
+	assert.Equal(t, subject, owner)
+}
+
+func TestPairwiseSubject_ClaimMapping(t *testing.T) {
+	h := newTestServer(t)
+	ctx := context.Background()
+	subject := addTestUser(t, h, "admin")
+	addTestClient(t, h, "app", subject, openid.SubjectPairwise)
+	mappings := []ClaimMapping{
+		{Claim: "uid", Source: claimSourceUser, Value: "sub"},
+		{Claim: "login_name", Source: claimSourceUser, Value: "preferred_username"},
+	}
+	assert.Error(t, validateClaimMappings(mappings))
+
+	// the internal subject isn't copied even if the mapping wasn't validated
+	h.conf.ClientClaims = map[string][]ClaimMapping{"app": mappings}
+	m, err := h.tokenClaims(ctx, "app", subject, "openid")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"login_name": "admin"}, m)
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"errors"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/logger"
+	"github.com/1f349/tulip/pages"
+	"github.com/1f349/tulip/password"
+	"github.com/emersion/go-message/mail"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"time"
+)
+
+var (
+	errPasswordEmpty    = errors.New("cannot set an empty password")
+	errPasswordTooLong  = errors.New("password is too long")
+	errPasswordMismatch = errors.New("passwords do not match")
+	errPasswordExternal = errors.New("the password for this account is managed by an external directory")
+	// errPasswordCheckFailed hides errors from reading the breach list
+	errPasswordCheckFailed = errors.New("failed to check password")
+)
+
+// checkNewPassword validates a new password and the retyped confirmation
+// against the password policy, userInputs contains the username and email
+// address of the user
+func (h *HttpServer) checkNewPassword(pw, rpw string, userInputs ...string) error {
+	if len(pw) == 0 {
+		return errPasswordEmpty
+	}
+	if len(pw) > password.MaxLength() {
+		return errPasswordTooLong
+	}
+	if rpw != pw {
+		return errPasswordMismatch
+	}
+	err := h.policy.Check(pw, userInputs...)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, password.ErrPasswordTooShort),
+		errors.Is(err, password.ErrPasswordTooWeak),
+		errors.Is(err, password.ErrPasswordContainsUserInfo),
+		errors.Is(err, password.ErrPasswordBreached):
+		return err
+	}
+	logger.Logger.Warn("Failed to check password policy", "err", err)
+	return errPasswordCheckFailed
+}
+
+func (h *HttpServer) EditPasswordGet(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	var hasOtp bool
+	var userInfo database.User
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		userInfo, err = tx.GetUser(req.Context(), auth.Subject)
+		if err != nil {
+			return
+		}
+		hasOtp, err = tx.HasOtp(req.Context(), auth.Subject)
+		return
+	}) {
+		return
+	}
+	if userInfo.AuthSource != "" {
+		http.Error(rw, "400 Bad Request: "+errPasswordExternal.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/html")
+	rw.WriteHeader(http.StatusOK)
+	pages.RenderPageTemplate(rw, "edit-password", map[string]any{
+		"ServiceName": h.conf.ServiceName,
+		"HasOtp":      hasOtp,
+	})
+}
+
+func (h *HttpServer) EditPasswordPost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	current := req.PostFormValue("password")
+	pw := req.PostFormValue("new_password")
+	rpw := req.PostFormValue("confirm_password")
+
+	if rpw != pw {
+		http.Error(rw, "400 Bad Request: "+errPasswordMismatch.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var lockout database.GetLoginLockoutBySubjectRow
+	var hasOtp bool
+	var passwordErr error
+	var userInfo database.User
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		lockout, err = tx.GetLoginLockoutBySubject(req.Context(), auth.Subject)
+		if err != nil {
+			return
+		}
+		userInfo, err = tx.GetUser(req.Context(), auth.Subject)
+		if err != nil || userInfo.AuthSource != "" {
+			return
+		}
+		hasOtp, err = tx.HasOtp(req.Context(), auth.Subject)
+		if err != nil {
+			return
+		}
+		passwordErr = tx.CheckPassword(req.Context(), auth.Subject, current)
+		if errors.Is(passwordErr, password.ErrMismatchedHashAndPassword) {
+			return nil
+		}
+		return passwordErr
+	}) {
+		return
+	}
+	// the local password of a directory user is random so checking it would
+	// only count towards the lockout
+	if userInfo.AuthSource != "" {
+		http.Error(rw, "400 Bad Request: "+errPasswordExternal.Error(), http.StatusBadRequest)
+		return
+	}
+	if h.loginBlocked(rw, req, lockout.LockedUntil) || h.startLoginAttempt(rw, req, auth.Subject) {
+		return
+	}
+	if passwordErr != nil {
+		h.recordLoginFailure(req, auth.Subject)
+		http.Error(rw, "400 Bad Request: Current password is incorrect", http.StatusBadRequest)
+		return
+	}
+	// the OTP attempts are counted separately
+	h.guard.releaseAttempt(req.Context(), auth.Subject)
+	if err := h.checkNewPassword(pw, rpw, userInfo.Username, userInfo.Email); err != nil {
+		http.Error(rw, "400 Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if hasOtp && h.fetchAndValidateOtp(rw, req, auth.Subject, req.PostFormValue("code")) {
+		return
+	}
+
+	if h.DbTx(rw, func(tx *database.Queries) error {
+		err := tx.ChangePassword(req.Context(), auth.Subject, pw)
+		if err != nil {
+			return err
+		}
+		err = tx.DeleteTrustedDevices(req.Context(), auth.Subject)
+		if err != nil {
+			return err
+		}
+		_, err = revokeSessions(req.Context(), tx, auth.Subject)
+		return err
+	}) {
+		return
+	}
+
+	// replace the login cookies so only this session stays logged in
+	if h.setLoginDataCookie(rw, UserAuth{Subject: auth.Subject, Persistent: auth.Persistent}) {
+		return
+	}
+
+	h.sendPasswordChangedEmail(userInfo)
+	http.Redirect(rw, req, "/", http.StatusFound)
+}
+
+func (h *HttpServer) sendPasswordChangedEmail(userInfo database.User) {
+	address, err := mail.ParseAddress(userInfo.Email)
+	if err != nil {
+		logger.Logger.Warn("Failed to parse user email address", "sub", userInfo.Subject, "err", err)
+		return
+	}
+	err = h.conf.Mail.SendEmailTemplate("mail-password-changed", "Password Changed", userInfo.Name, address, map[string]any{
+		"ChangedAt": time.Now().UTC().Format(time.RFC1123),
+	})
+	if err != nil {
+		logger.Logger.Warn("Failed to send password changed email", "sub", userInfo.Subject, "err", err)
+	}
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"github.com/1f349/tulip/password"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestHttpServer_CheckNewPassword(t *testing.T) {
+	policy, err := password.NewPolicy(password.PolicyConfig{})
+	assert.NoError(t, err)
+	h := &HttpServer{policy: policy}
+
+	const pw = "correct horse battery staple"
+	assert.NoError(t, h.checkNewPassword(pw, pw, "melon", "melon@example.com"))
+	assert.ErrorIs(t, h.checkNewPassword("", ""), errPasswordEmpty)
+	long := strings.Repeat("a", password.MaxLength()+1)
+	assert.ErrorIs(t, h.checkNewPassword(long, long), errPasswordTooLong)
+	assert.ErrorIs(t, h.checkNewPassword(pw, "hunter3"), errPasswordMismatch)
+	assert.ErrorIs(t, h.checkNewPassword("hunter2", "hunter2"), password.ErrPasswordTooShort)
+	assert.ErrorIs(t, h.checkNewPassword("melon-horse-battery", "melon-horse-battery", "melon"), password.ErrPasswordContainsUserInfo)
+}
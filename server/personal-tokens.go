@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/logger"
+	"github.com/1f349/tulip/pages"
+	"github.com/1f349/tulip/password"
+	"github.com/1f349/tulip/scope"
+	"github.com/go-oauth2/oauth2/v4"
+	oauthErrors "github.com/go-oauth2/oauth2/v4/errors"
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	personalTokenPrefix  = "tulip_pat_"
+	personalTokenLength  = 40
+	personalTokenMaxName = 64
+)
+
+// personalTokenExpiry lists the lifetimes in days which can be chosen when
+// creating a token
+var personalTokenExpiry = []int{7, 30, 90, 365}
+
+type personalTokenScope struct {
+	Name        string
+	Description string
+}
+
+// personalTokenScopes returns the scopes which can be granted to a token, the
+// openid scope is always granted so it is not listed
+func personalTokenScopes() []personalTokenScope {
+	var scopes []personalTokenScope
+	for _, i := range scope.ScopeNames() {
+		if i == "openid" {
+			continue
+		}
+		scopes = append(scopes, personalTokenScope{Name: i, Description: scope.FancyScopeList(i)[0]})
+	}
+	return scopes
+}
+
+// generatePersonalToken returns a random token with a prefix which identifies
+// it as a personal access token
+func generatePersonalToken() (string, error) {
+	secret, err := password.GenerateApiSecret(personalTokenLength)
+	if err != nil {
+		return "", err
+	}
+	return personalTokenPrefix + secret, nil
+}
+
+func isPersonalToken(token string) bool {
+	return strings.HasPrefix(token, personalTokenPrefix)
+}
+
+// hashPersonalToken returns the hex encoded hash, the tokens contain enough
+// entropy for a plain sha256 hash to be used
+func hashPersonalToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadPersonalToken finds an unexpired token for an active user, the token
+// info has no client ID and the last used time is updated
+func (h *HttpServer) loadPersonalToken(ctx context.Context, token string) (oauth2.TokenInfo, error) {
+	var row database.GetPersonalTokenRow
+	err := h.DbTxError(func(tx *database.Queries) (err error) {
+		row, err = tx.GetPersonalToken(ctx, database.GetPersonalTokenParams{
+			TokenHash: hashPersonalToken(token),
+			ExpiresAt: time.Now(),
+		})
+		if err != nil {
+			return
+		}
+		return tx.SetPersonalTokenLastUsed(ctx, database.SetPersonalTokenLastUsedParams{
+			LastUsed: sql.NullTime{Time: time.Now(), Valid: true},
+			ID:       row.ID,
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, oauthErrors.ErrInvalidAccessToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &models.Token{
+		UserID:          row.Subject,
+		Scope:           row.Scope,
+		Access:          token,
+		AccessCreateAt:  row.CreatedAt,
+		AccessExpiresIn: row.ExpiresAt.Sub(row.CreatedAt),
+	}, nil
+}
+
+// validateBearerToken loads the OAuth access token or personal access token
+// from the request
+func (h *HttpServer) validateBearerToken(req *http.Request) (oauth2.TokenInfo, error) {
+	token, ok := h.oauthSrv.BearerAuth(req)
+	if !ok {
+		return nil, oauthErrors.ErrInvalidAccessToken
+	}
+	if isPersonalToken(token) {
+		return h.loadPersonalToken(req.Context(), token)
+	}
+	return h.loadAccessToken(req.Context(), token)
+}
+
+// loadAccessToken finds an OAuth access token, tokens issued before the
+// sessions of the user were revoked are rejected
+func (h *HttpServer) loadAccessToken(ctx context.Context, token string) (oauth2.TokenInfo, error) {
+	ti, err := h.oauthMgr.LoadAccessToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if ti.GetUserID() != "" && h.revokedSince(ctx, ti.GetUserID(), ti.GetAccessCreateAt()) {
+		return nil, oauthErrors.ErrInvalidAccessToken
+	}
+	return ti, nil
+}
+
+func (h *HttpServer) renderEditTokens(rw http.ResponseWriter, req *http.Request, subject string, created map[string]string) {
+	var tokens []database.GetPersonalTokensRow
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		tokens, err = tx.GetPersonalTokens(req.Context(), subject)
+		return
+	}) {
+		return
+	}
+	pages.RenderPageTemplate(rw, "edit-tokens", map[string]any{
+		"ServiceName": h.conf.ServiceName,
+		"Tokens":      tokens,
+		"Scopes":      personalTokenScopes(),
+		"Expiry":      personalTokenExpiry,
+		"Created":     created,
+		"Now":         time.Now(),
+	})
+}
+
+func (h *HttpServer) EditTokensGet(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	h.renderEditTokens(rw, req, auth.Subject, nil)
+}
+
+// createPersonalToken creates a token for the subject from the name, expiry
+// and scope form values, the returned bool is true if an error was written
+func (h *HttpServer) createPersonalToken(rw http.ResponseWriter, req *http.Request, subject string) (name, token string, failed bool) {
+	name = strings.TrimSpace(req.Form.Get("name"))
+	if name == "" || len(name) > personalTokenMaxName {
+		http.Error(rw, "400 Bad Request: Invalid token name", http.StatusBadRequest)
+		return "", "", true
+	}
+	days, err := strconv.Atoi(req.Form.Get("expiry"))
+	if err != nil || !slices.Contains(personalTokenExpiry, days) {
+		http.Error(rw, "400 Bad Request: Invalid token expiry", http.StatusBadRequest)
+		return "", "", true
+	}
+	scopes := []string{"openid"}
+	for _, i := range req.Form["scope"] {
+		if !slices.Contains(scopes, i) {
+			scopes = append(scopes, i)
+		}
+	}
+	tokenScope := strings.Join(scopes, " ")
+	if !scope.ScopesExist(tokenScope) {
+		http.Error(rw, "400 Bad Request: Invalid token scope", http.StatusBadRequest)
+		return "", "", true
+	}
+
+	token, err = generatePersonalToken()
+	if err != nil {
+		http.Error(rw, "500 Internal Server Error: Failed to generate token", http.StatusInternalServerError)
+		return "", "", true
+	}
+	now := time.Now()
+	if h.DbTx(rw, func(tx *database.Queries) error {
+		return tx.AddPersonalToken(req.Context(), database.AddPersonalTokenParams{
+			ID:        uuid.NewString(),
+			Subject:   subject,
+			Name:      name,
+			TokenHash: hashPersonalToken(token),
+			Scope:     tokenScope,
+			CreatedAt: now,
+			ExpiresAt: now.AddDate(0, 0, days),
+		})
+	}) {
+		return "", "", true
+	}
+	logger.Logger.Info("Personal access token created", "sub", subject, "name", name)
+	return name, token, false
+}
+
+func (h *HttpServer) EditTokensPost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(rw, "400 Bad Request: Failed to parse form", http.StatusBadRequest)
+		return
+	}
+	name, token, failed := h.createPersonalToken(rw, req, auth.Subject)
+	if failed {
+		return
+	}
+
+	// the token is only shown in this response
+	h.renderEditTokens(rw, req, auth.Subject, map[string]string{"Name": name, "Token": token})
+}
+
+func (h *HttpServer) EditTokensDeletePost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	if h.DbTx(rw, func(tx *database.Queries) error {
+		return tx.DeletePersonalToken(req.Context(), database.DeletePersonalTokenParams{
+			ID:      req.PostFormValue("id"),
+			Subject: auth.Subject,
+		})
+	}) {
+		return
+	}
+	http.Redirect(rw, req, "/edit/tokens", http.StatusFound)
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGeneratePersonalToken(t *testing.T) {
+	a, err := generatePersonalToken()
+	assert.NoError(t, err)
+	assert.True(t, isPersonalToken(a))
+	assert.Len(t, a, len(personalTokenPrefix)+personalTokenLength)
+	b, err := generatePersonalToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, a, b)
+	assert.NotEqual(t, hashPersonalToken(a), hashPersonalToken(b))
+
+	assert.False(t, isPersonalToken("eyJhbGciOiJSUzUxMiJ9.e30.sig"))
+}
+
+func TestPersonalTokenScopes(t *testing.T) {
+	scopes := personalTokenScopes()
+	assert.NotEmpty(t, scopes)
+	for _, i := range scopes {
+		assert.NotEqual(t, "openid", i.Name)
+		assert.NotEmpty(t, i.Description)
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/database/types"
+	"github.com/1f349/tulip/logger"
+	"github.com/1f349/tulip/pages"
+	"github.com/emersion/go-message/mail"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RegistrationConf controls the self-service registration page, if both
+// AllowedDomains and InviteCodes are empty then anyone can register
+type RegistrationConf struct {
+	Enabled         bool     `json:"enabled"`
+	AllowedDomains  []string `json:"allowed_domains"`
+	InviteCodes     []string `json:"invite_codes"`
+	RequireApproval bool     `json:"require_approval"`
+}
+
+// allowed checks if the email domain or the invite code permits registration
+func (r RegistrationConf) allowed(email, invite string) bool {
+	if len(r.AllowedDomains) == 0 && len(r.InviteCodes) == 0 {
+		return true
+	}
+	if invite != "" && slices.Contains(r.InviteCodes, invite) {
+		return true
+	}
+	n := strings.LastIndexByte(email, '@')
+	if n == -1 {
+		return false
+	}
+	domain := email[n+1:]
+	return slices.ContainsFunc(r.AllowedDomains, func(s string) bool {
+		return strings.EqualFold(s, domain)
+	})
+}
+
+func (h *HttpServer) RegisterGet(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	if !h.conf.Registration.Enabled {
+		http.NotFound(rw, req)
+		return
+	}
+	if !auth.IsGuest() {
+		http.Redirect(rw, req, "/", http.StatusFound)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/html")
+	rw.WriteHeader(http.StatusOK)
+	pages.RenderPageTemplate(rw, "register", map[string]any{
+		"ServiceName": h.conf.ServiceName,
+		"NeedsInvite": len(h.conf.Registration.InviteCodes) != 0,
+		"Invite":      req.URL.Query().Get("invite"),
+	})
+}
+
+func (h *HttpServer) RegisterPost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	if !h.conf.Registration.Enabled {
+		http.NotFound(rw, req)
+		return
+	}
+	if !auth.IsGuest() {
+		http.Redirect(rw, req, "/", http.StatusFound)
+		return
+	}
+
+	name := strings.TrimSpace(req.PostFormValue("name"))
+	username := strings.TrimSpace(req.PostFormValue("username"))
+	email := req.PostFormValue("email")
+	invite := strings.TrimSpace(req.PostFormValue("invite"))
+	pw := req.PostFormValue("new_password")
+	rpw := req.PostFormValue("confirm_password")
+
+	if username == "" {
+		http.Error(rw, "400 Bad Request: Username is required", http.StatusBadRequest)
+		return
+	}
+	if name == "" {
+		name = username
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Name != "" {
+		http.Error(rw, "400 Bad Request: Invalid email address format", http.StatusBadRequest)
+		return
+	}
+	if !h.conf.Registration.allowed(address.Address, invite) {
+		http.Error(rw, "403 Forbidden: Registration is not allowed for this email address or invite code", http.StatusForbidden)
+		return
+	}
+	if err := h.checkNewPassword(pw, rpw, username, address.Address); err != nil {
+		http.Error(rw, "400 Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var usernameExists, emailExists bool
+	var userSub string
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		usernameExists, err = tx.UsernameExists(req.Context(), username)
+		if err != nil {
+			return
+		}
+		emailExists, err = tx.UserEmailExists(req.Context(), address.Address)
+		if err != nil || usernameExists || emailExists {
+			return
+		}
+		userSub, err = tx.AddUser(req.Context(), database.AddUserParams{
+			Name:          name,
+			Username:      username,
+			Password:      pw,
+			Email:         address.Address,
+			EmailVerified: false,
+			Role:          types.RoleMember,
+			UpdatedAt:     time.Now(),
+			Active:        !h.conf.Registration.RequireApproval,
+		})
+		return
+	}) {
+		return
+	}
+	if usernameExists {
+		http.Error(rw, "400 Bad Request: Username is already taken", http.StatusBadRequest)
+		return
+	}
+	if emailExists {
+		http.Error(rw, "400 Bad Request: Email address is already in use", http.StatusBadRequest)
+		return
+	}
+
+	u := uuid.NewString()
+	h.mailLinkCache.Set(mailLinkKey{mailLinkVerifyEmail, u}, userSub, time.Now().Add(10*time.Minute))
+
+	err = h.conf.Mail.SendEmailTemplate("mail-verify", "Verify Email", name, address, map[string]any{
+		"VerifyUrl": h.conf.BaseUrl + "/mail/verify/" + u,
+	})
+	if err != nil {
+		// the user can request another email by trying to login
+		logger.Logger.Warn("Register: Failed to send verification email", "err", err)
+	}
+
+	if h.conf.Registration.RequireApproval {
+		http.Error(rw, "Your account has been created, please verify your email address. An administrator must approve your account before you can login.", http.StatusOK)
+		return
+	}
+	http.Error(rw, "Your account has been created, please verify your email address before logging in.", http.StatusOK)
+}
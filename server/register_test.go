@@ -0,0 +1,45 @@
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRegistrationConf_Allowed(t *testing.T) {
+	open := RegistrationConf{Enabled: true}
+	assert.True(t, open.allowed("a@example.com", ""))
+
+	r := RegistrationConf{
+		Enabled:        true,
+		AllowedDomains: []string{"example.com"},
+		InviteCodes:    []string{"let-me-in"},
+	}
+	assert.True(t, r.allowed("a@example.com", ""))
+	assert.True(t, r.allowed("a@EXAMPLE.com", ""))
+	assert.False(t, r.allowed("a@example.org", ""))
+	assert.False(t, r.allowed("a@sub.example.com", ""))
+	assert.True(t, r.allowed("a@example.org", "let-me-in"))
+	assert.False(t, r.allowed("a@example.org", "wrong"))
+	assert.False(t, r.allowed("invalid", ""))
+}
+
+func TestRegisterPost_NotAllowed(t *testing.T) {
+	h := &HttpServer{conf: Conf{Registration: RegistrationConf{Enabled: true, AllowedDomains: []string{"example.com"}}}}
+
+	// the password isn't checked before registration is allowed
+	form := url.Values{
+		"username":         {"guest"},
+		"email":            {"guest@example.org"},
+		"new_password":     {strings.Repeat("a", 200)},
+		"confirm_password": {strings.Repeat("a", 200)},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.RegisterPost(rec, req, nil, UserAuth{})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
@@ -1,22 +1,25 @@
 package server
 
 import (
-	"crypto/subtle"
+	"context"
 	_ "embed"
-	"encoding/json"
-	"fmt"
 	"github.com/1f349/cache"
 	"github.com/1f349/mjwt"
+	"github.com/1f349/tulip/authenticator"
 	clientStore "github.com/1f349/tulip/client-store"
 	"github.com/1f349/tulip/database"
 	"github.com/1f349/tulip/logger"
 	"github.com/1f349/tulip/openid"
 	"github.com/1f349/tulip/pages"
+	"github.com/1f349/tulip/password"
 	scope2 "github.com/1f349/tulip/scope"
+	"github.com/go-oauth2/oauth2/v4"
 	"github.com/go-oauth2/oauth2/v4/errors"
 	"github.com/go-oauth2/oauth2/v4/manage"
 	"github.com/go-oauth2/oauth2/v4/server"
 	"github.com/go-oauth2/oauth2/v4/store"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/julienschmidt/httprouter"
 	"net/http"
 	"net/url"
@@ -37,6 +40,28 @@ type HttpServer struct {
 
 	// mailLinkCache contains a mapping of verify uuids to user uuids
 	mailLinkCache *cache.Cache[mailLinkKey, string]
+	// passwordResetLimit contains email addresses which recently requested a
+	// password reset
+	passwordResetLimit *cache.Cache[string, struct{}]
+	// emailLogins contains pending passwordless logins by token
+	emailLogins     *cache.Cache[string, emailLogin]
+	emailLoginLimit *cache.Cache[string, struct{}]
+
+	webauthn *webauthn.WebAuthn
+	// webauthnSessions contains the state of passkey ceremonies
+	webauthnSessions *cache.Cache[string, webauthnSession]
+
+	upstreams map[string]*upstreamProvider
+	// upstreamStates contains the state of upstream provider logins
+	upstreamStates *cache.Cache[string, upstreamState]
+
+	// authenticators are the external directories used to check passwords
+	authenticators []authenticator.Authenticator
+
+	guard     *loginGuard
+	policy    *password.Policy
+	sessions  sessionSettings
+	twoFactor twoFactorPolicy
 }
 
 const (
@@ -62,14 +87,31 @@ func NewHttpServer(conf Conf, db *database.Queries, signingKey mjwt.Signer) *htt
 		}
 	}
 
-	openIdConf := openid.GenConfig(conf.BaseUrl, []string{"openid", "name", "username", "profile", "email", "birthdate", "age", "zoneinfo", "locale"}, []string{"sub", "name", "preferred_username", "profile", "picture", "website", "email", "email_verified", "gender", "birthdate", "zoneinfo", "locale", "updated_at"})
-	openIdBytes, err := json.Marshal(openIdConf)
-	if err != nil {
-		logger.Logger.Fatal("Failed to generate OpenID configuration", "err", err)
-	}
-
 	oauthManager := manage.NewDefaultManager()
 	oauthSrv := server.NewServer(server.NewConfig(), oauthManager)
+	wa, err := newWebauthn(conf)
+	if err != nil {
+		logger.Logger.Fatal("Failed to create WebAuthn config", "err", err)
+	}
+	guard, err := newLoginGuard(db, conf)
+	if err != nil {
+		logger.Logger.Fatal("Invalid lockout config", "err", err)
+	}
+	policy, err := password.NewPolicy(conf.PasswordPolicy)
+	if err != nil {
+		logger.Logger.Fatal("Invalid password policy", "err", err)
+	}
+	sessions, err := newSessionSettings(conf.Session)
+	if err != nil {
+		logger.Logger.Fatal("Invalid session config", "err", err)
+	}
+	if err := checkForwardAuthHosts(conf.BaseUrl, conf.Session.CookieDomain, conf.ForwardAuth); err != nil {
+		logger.Logger.Fatal("Invalid forward-auth config", "err", err)
+	}
+	twoFactor, err := newTwoFactorPolicy(conf.TwoFactor)
+	if err != nil {
+		logger.Logger.Fatal("Invalid two factor policy", "err", err)
+	}
 	hs := &HttpServer{
 		r:          httprouter.New(),
 		oauthSrv:   oauthSrv,
@@ -78,12 +120,28 @@ func NewHttpServer(conf Conf, db *database.Queries, signingKey mjwt.Signer) *htt
 		conf:       conf,
 		signingKey: signingKey,
 
-		mailLinkCache: cache.New[mailLinkKey, string](),
+		mailLinkCache:      cache.New[mailLinkKey, string](),
+		passwordResetLimit: cache.New[string, struct{}](),
+		emailLogins:        cache.New[string, emailLogin](),
+		emailLoginLimit:    cache.New[string, struct{}](),
+
+		webauthn:         wa,
+		webauthnSessions: cache.New[string, webauthnSession](),
+
+		upstreams:      newUpstreamProviders(conf.Upstreams),
+		upstreamStates: cache.New[string, upstreamState](),
+
+		authenticators: conf.authenticators(),
+
+		guard:     guard,
+		policy:    policy,
+		sessions:  sessions,
+		twoFactor: twoFactor,
 	}
 
 	oauthManager.SetAuthorizeCodeTokenCfg(manage.DefaultAuthorizeCodeTokenCfg)
 	oauthManager.MustTokenStorage(store.NewMemoryTokenStore())
-	oauthManager.MapAccessGenerate(NewJWTAccessGenerate(hs.signingKey))
+	oauthManager.MapAccessGenerate(NewJWTAccessGenerate(hs.signingKey, hs.issueClientSubject, hs.tokenClaims))
 	oauthManager.MapClientStorage(clientStore.New(db))
 
 	oauthSrv.SetClientInfoHandler(func(req *http.Request) (clientID, clientSecret string, err error) {
@@ -110,40 +168,43 @@ func NewHttpServer(conf Conf, db *database.Queries, signingKey mjwt.Signer) *htt
 		}
 		return a, nil
 	})
-	addIdTokenSupport(oauthSrv, db, signingKey)
-
-	r.GET("/.well-known/openid-configuration", func(rw http.ResponseWriter, req *http.Request, params httprouter.Params) {
-		rw.WriteHeader(http.StatusOK)
-		_, _ = rw.Write(openIdBytes)
-	})
-	r.GET("/", hs.OptionalAuthentication(false, hs.Home))
-	r.POST("/logout", hs.RequireAuthentication(func(rw http.ResponseWriter, req *http.Request, params httprouter.Params, auth UserAuth) {
-		cookie, err := req.Cookie("tulip-nonce")
-		if err != nil {
-			http.Error(rw, "Missing nonce", http.StatusBadRequest)
-			return
+	oauthSrv.SetRefreshingValidationHandler(func(ti oauth2.TokenInfo) (allowed bool, err error) {
+		// refresh tokens are revoked along with the login sessions
+		if ti.GetUserID() != "" && hs.revokedSince(context.Background(), ti.GetUserID(), ti.GetRefreshCreateAt()) {
+			return false, errors.ErrInvalidRefreshToken
 		}
-		if subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(req.PostFormValue("nonce"))) == 1 {
-			http.SetCookie(rw, &http.Cookie{
-				Name:     "tulip-login-access",
-				Path:     "/",
-				MaxAge:   -1,
-				Secure:   true,
-				SameSite: http.SameSiteLaxMode,
-			})
-			http.SetCookie(rw, &http.Cookie{
-				Name:     "tulip-login-refresh",
-				Path:     "/",
-				MaxAge:   -1,
-				Secure:   true,
-				SameSite: http.SameSiteLaxMode,
-			})
-
-			http.Redirect(rw, req, "/", http.StatusFound)
+		allowed, err = hs.userCanAccessClient(context.Background(), ti.GetClientID(), ti.GetUserID())
+		if err != nil || allowed {
 			return
 		}
-		http.Error(rw, "Logout failed", http.StatusInternalServerError)
-	}))
+		return false, errors.ErrAccessDenied
+	})
+	addIdTokenSupport(oauthSrv, signingKey, hs.clientSubject, hs.tokenClaims)
+
+	if err := validateClaimMappings(conf.Claims); err != nil {
+		logger.Logger.Fatal("Invalid claim mappings", "err", err)
+	}
+	for clientId, mappings := range conf.ClientClaims {
+		if err := validateClaimMappings(mappings); err != nil {
+			logger.Logger.Fatal("Invalid claim mappings", "client", clientId, "err", err)
+		}
+	}
+
+	openIdHandler, err := serveJson(genOpenIdConfig(conf.BaseUrl, oauthSrv, mappedClaimNames(conf)))
+	if err != nil {
+		logger.Logger.Fatal("Failed to generate OpenID configuration", "err", err)
+	}
+	jwksHandler, err := serveJson(openid.GenJwks(signingKey.PublicKey(), jwt.SigningMethodRS512.Alg()))
+	if err != nil {
+		logger.Logger.Fatal("Failed to generate JWKS", "err", err)
+	}
+
+	r.GET("/.well-known/openid-configuration", openIdHandler)
+	r.GET("/.well-known/oauth-authorization-server", openIdHandler)
+	r.GET("/.well-known/jwks.json", jwksHandler)
+	r.GET("/", hs.OptionalAuthentication(false, hs.Home))
+	r.GET("/logout", hs.OptionalAuthentication(false, hs.LogoutGet))
+	r.POST("/logout", hs.RequireAuthentication(hs.LogoutPost))
 
 	// theme styles
 	r.GET("/assets/*filepath", func(rw http.ResponseWriter, req *http.Request, params httprouter.Params) {
@@ -159,8 +220,22 @@ func NewHttpServer(conf Conf, db *database.Queries, signingKey mjwt.Signer) *htt
 	// login steps
 	r.GET("/login", hs.OptionalAuthentication(false, hs.LoginGet))
 	r.POST("/login", hs.OptionalAuthentication(false, hs.LoginPost))
+	r.POST("/login/email", hs.OptionalAuthentication(false, hs.LoginEmailPost))
+	r.GET("/login/email/:token", hs.OptionalAuthentication(false, hs.LoginEmailLinkGet))
+	r.POST("/login/email/link", hs.OptionalAuthentication(false, hs.LoginEmailLinkPost))
+	r.POST("/login/email/code", hs.OptionalAuthentication(false, hs.LoginEmailCodePost))
+	r.GET("/login/upstream/:provider", hs.OptionalAuthentication(false, hs.LoginUpstreamGet))
+	r.GET("/login/upstream/:provider/callback", hs.OptionalAuthentication(false, hs.LoginUpstreamCallbackGet))
+	r.GET("/login/reset-password", hs.LoginResetPasswordGet)
+	r.POST("/login/reset-password", hs.LoginResetPasswordPost)
+	r.GET("/register", hs.OptionalAuthentication(false, hs.RegisterGet))
+	r.POST("/register", hs.OptionalAuthentication(false, hs.RegisterPost))
 	r.GET("/login/otp", hs.OptionalAuthentication(true, hs.LoginOtpGet))
 	r.POST("/login/otp", hs.OptionalAuthentication(true, hs.LoginOtpPost))
+	r.POST("/login/webauthn/begin", hs.OptionalAuthentication(true, hs.LoginWebauthnBeginPost))
+	r.POST("/login/webauthn/finish", hs.OptionalAuthentication(true, hs.LoginWebauthnFinishPost))
+	r.GET("/login/two-factor", hs.RequireAuthentication(hs.LoginTwoFactorGet))
+	r.POST("/login/two-factor/skip", hs.RequireAuthentication(hs.LoginTwoFactorSkipPost))
 
 	// mail codes
 	r.GET("/mail/verify/:code", hs.MailVerify)
@@ -171,7 +246,28 @@ func NewHttpServer(conf Conf, db *database.Queries, signingKey mjwt.Signer) *htt
 	// edit profile pages
 	r.GET("/edit", hs.RequireAuthentication(hs.EditGet))
 	r.POST("/edit", hs.RequireAuthentication(hs.EditPost))
+	r.GET("/edit/password", hs.RequireAuthentication(hs.EditPasswordGet))
+	r.POST("/edit/password", hs.RequireAuthentication(hs.EditPasswordPost))
 	r.POST("/edit/otp", hs.RequireAuthentication(hs.EditOtpPost))
+	r.POST("/edit/otp/recovery", hs.RequireAuthentication(hs.EditOtpRecoveryPost))
+	r.POST("/edit/webauthn/begin", hs.RequireAuthentication(hs.EditWebauthnBeginPost))
+	r.POST("/edit/webauthn/finish", hs.RequireAuthentication(hs.EditWebauthnFinishPost))
+	r.POST("/edit/webauthn/delete", hs.RequireAuthentication(hs.EditWebauthnDeletePost))
+	r.POST("/edit/upstream/link", hs.RequireAuthentication(hs.EditUpstreamLinkPost))
+	r.POST("/edit/upstream/delete", hs.RequireAuthentication(hs.EditUpstreamDeletePost))
+	r.POST("/edit/app-passwords/create", hs.RequireAuthentication(hs.EditAppPasswordCreatePost))
+	r.POST("/edit/app-passwords/delete", hs.RequireAuthentication(hs.EditAppPasswordDeletePost))
+	r.POST("/edit/devices/delete", hs.RequireAuthentication(hs.EditTrustedDeviceDeletePost))
+	r.GET("/edit/tokens", hs.RequireAuthentication(hs.EditTokensGet))
+	r.POST("/edit/tokens", hs.RequireAuthentication(hs.EditTokensPost))
+	r.POST("/edit/tokens/delete", hs.RequireAuthentication(hs.EditTokensDeletePost))
+
+	// forward-auth for reverse proxies, the request method of the original
+	// request is used by some proxies
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		r.Handle(method, "/forward-auth", hs.ForwardAuth)
+	}
+	r.GET("/forward-auth/redirect", hs.RequireAuthentication(hs.ForwardAuthRedirectGet))
 
 	// management pages
 	r.GET("/manage/apps", hs.RequireAuthentication(hs.ManageAppsGet))
@@ -180,6 +276,9 @@ func NewHttpServer(conf Conf, db *database.Queries, signingKey mjwt.Signer) *htt
 	r.GET("/manage/users", hs.RequireAdminAuthentication(hs.ManageUsersGet))
 	r.GET("/manage/users/create", hs.RequireAuthentication(hs.ManageUsersCreateGet))
 	r.POST("/manage/users", hs.RequireAdminAuthentication(hs.ManageUsersPost))
+	r.GET("/manage/groups", hs.RequireAdminAuthentication(hs.ManageGroupsGet))
+	r.GET("/manage/groups/create", hs.RequireAdminAuthentication(hs.ManageGroupsCreateGet))
+	r.POST("/manage/groups", hs.RequireAdminAuthentication(hs.ManageGroupsPost))
 
 	// oauth pages
 	r.GET("/authorize", hs.RequireAuthentication(hs.authorizeEndpoint))
@@ -189,65 +288,9 @@ func NewHttpServer(conf Conf, db *database.Queries, signingKey mjwt.Signer) *htt
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
 		}
 	})
-	r.GET("/userinfo", func(rw http.ResponseWriter, req *http.Request, params httprouter.Params) {
-		token, err := oauthSrv.ValidationBearerToken(req)
-		if err != nil {
-			http.Error(rw, "403 Forbidden", http.StatusForbidden)
-			return
-		}
-		userId := token.GetUserID()
-
-		fmt.Printf("Using token for user: %s by app: %s with scope: '%s'\n", userId, token.GetClientID(), token.GetScope())
-		claims := ParseClaims(token.GetScope())
-		if !claims["openid"] {
-			http.Error(rw, "Invalid scope", http.StatusBadRequest)
-			return
-		}
-
-		var userData database.User
-
-		if hs.DbTx(rw, func(tx *database.Queries) (err error) {
-			userData, err = tx.GetUser(req.Context(), userId)
-			return err
-		}) {
-			return
-		}
-
-		m := map[string]any{}
-		m["sub"] = userId
-		m["aud"] = token.GetClientID()
-		if claims["name"] {
-			m["name"] = userData.Name
-		}
-		if claims["username"] {
-			m["preferred_username"] = userData.Username
-			m["login"] = userData.Username
-		}
-		if claims["profile"] {
-			m["profile"] = conf.BaseUrl + "/user/" + userData.Username
-			m["picture"] = userData.Picture
-			m["website"] = userData.Website
-		}
-		if claims["email"] {
-			m["email"] = userData.Email
-			m["email_verified"] = userData.EmailVerified
-		}
-		if claims["birthdate"] && userData.Birthdate.Valid {
-			m["birthdate"] = userData.Birthdate.Date.String()
-		}
-		if claims["age"] {
-			m["age"] = CalculateAge(userData.Birthdate.Date.ToTime().In(userData.Zoneinfo.Location))
-		}
-		if claims["zoneinfo"] {
-			m["zoneinfo"] = userData.Zoneinfo.Location.String()
-		}
-		if claims["locale"] {
-			m["locale"] = userData.Locale.Tag.String()
-		}
-		m["updated_at"] = time.Now().Unix()
-
-		_ = json.NewEncoder(rw).Encode(m)
-	})
+	r.GET("/userinfo", hs.UserInfo)
+	r.POST("/revoke", hs.RevokeToken)
+	r.POST("/introspect", hs.IntrospectToken)
 
 	return &http.Server{
 		Addr:              conf.Listen,
@@ -278,6 +321,20 @@ func (h *HttpServer) SafeRedirect(rw http.ResponseWriter, req *http.Request) {
 	http.Redirect(rw, req, parse.String(), http.StatusFound)
 }
 
+// safeRedirectPath returns the redirect path if it stays on this server,
+// otherwise the home page is returned
+func safeRedirectPath(redirectUrl string) string {
+	// browsers treat "//host" and "/\host" as a url on another host
+	if !strings.HasPrefix(redirectUrl, "/") || strings.HasPrefix(redirectUrl, "//") || strings.HasPrefix(redirectUrl, "/\\") {
+		return "/"
+	}
+	parse, err := url.Parse(redirectUrl)
+	if err != nil || parse.IsAbs() || parse.Host != "" || parse.User != nil {
+		return "/"
+	}
+	return parse.String()
+}
+
 func ParseClaims(claims string) map[string]bool {
 	m := make(map[string]bool)
 	for {
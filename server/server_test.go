@@ -36,3 +36,15 @@ func TestCalculateAge(t *testing.T) {
 	fmt.Println(tPastDst.AddDate(4, 0, 0).UTC(), tCur.UTC())
 	assert.Equal(t, 0, CalculateAge(tFut))
 }
+
+func TestSafeRedirectPath(t *testing.T) {
+	assert.Equal(t, "/", safeRedirectPath(""))
+	assert.Equal(t, "/edit", safeRedirectPath("/edit"))
+	assert.Equal(t, "/authorize?client_id=1234", safeRedirectPath("/authorize?client_id=1234"))
+	assert.Equal(t, "/", safeRedirectPath("edit"))
+	assert.Equal(t, "/", safeRedirectPath("https://evil.example.com/"))
+	assert.Equal(t, "/", safeRedirectPath("//evil.example.com"))
+	assert.Equal(t, "/", safeRedirectPath("///evil.example.com"))
+	assert.Equal(t, "/", safeRedirectPath("/\\evil.example.com"))
+	assert.Equal(t, "/", safeRedirectPath("/\t/evil.example.com"))
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/1f349/tulip/database"
+	"github.com/golang-jwt/jwt/v4"
+	"time"
+)
+
+var errSessionRevoked = errors.New("session revoked")
+
+const (
+	defaultAccessLifetime  = 12 * time.Hour
+	defaultRefreshLifetime = 30 * 24 * time.Hour
+
+	rememberMeOptional = "optional"
+	rememberMeAlways   = "always"
+	rememberMeNever    = "never"
+)
+
+// SessionConf controls the login cookies, zero values use the defaults
+//
+// The refresh lifetime limits how long a session lasts after the user logs in,
+// the idle timeout ends a session which has not been used. RememberMe is
+// "optional" to show a checkbox on the login page, "always" or "never". Logins
+// which don't use the login form are remembered unless RememberMe is "never".
+type SessionConf struct {
+	CookieDomain    string `json:"cookie_domain"`
+	AccessLifetime  string `json:"access_lifetime"`
+	RefreshLifetime string `json:"refresh_lifetime"`
+	IdleTimeout     string `json:"idle_timeout"`
+	RememberMe      string `json:"remember_me"`
+}
+
+// sessionSettings is the parsed SessionConf
+type sessionSettings struct {
+	cookieDomain string
+	access       time.Duration
+	refresh      time.Duration
+	idle         time.Duration
+	rememberMe   string
+}
+
+func parseSessionDuration(name, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid session %s: %w", name, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("session %s must be positive", name)
+	}
+	return d, nil
+}
+
+func newSessionSettings(conf SessionConf) (s sessionSettings, err error) {
+	s.cookieDomain = conf.CookieDomain
+	s.access, err = parseSessionDuration("access lifetime", conf.AccessLifetime, defaultAccessLifetime)
+	if err != nil {
+		return
+	}
+	s.refresh, err = parseSessionDuration("refresh lifetime", conf.RefreshLifetime, defaultRefreshLifetime)
+	if err != nil {
+		return
+	}
+	s.idle, err = parseSessionDuration("idle timeout", conf.IdleTimeout, 0)
+	if err != nil {
+		return
+	}
+	// the idle timeout is only checked when the access token is refreshed
+	if s.idle > 0 && s.access > s.idle {
+		return s, errors.New("session access lifetime must not be longer than the idle timeout")
+	}
+	switch conf.RememberMe {
+	case "":
+		s.rememberMe = rememberMeOptional
+	case rememberMeOptional, rememberMeAlways, rememberMeNever:
+		s.rememberMe = conf.RememberMe
+	default:
+		return s, fmt.Errorf("invalid session remember me option: %q", conf.RememberMe)
+	}
+	return s, nil
+}
+
+// persistent returns true if the login cookies should outlive the browser
+// session, chosen is the value of the remember me checkbox
+func (s sessionSettings) persistent(chosen bool) bool {
+	switch s.rememberMe {
+	case rememberMeAlways:
+		return true
+	case rememberMeNever:
+		return false
+	}
+	return chosen
+}
+
+// refreshExpiry returns the expiry of a refresh token issued now, each refresh
+// extends the session by the idle timeout up to the refresh lifetime
+func (s sessionSettings) refreshExpiry(start, now time.Time) time.Time {
+	exp := start.Add(s.refresh)
+	if s.idle > 0 && now.Add(s.idle).Before(exp) {
+		exp = now.Add(s.idle)
+	}
+	return exp
+}
+
+// sessionRevoked returns true if the login token was issued before the sessions
+// of the user were revoked, issuedNano is the exact time the token was issued
+// and tokens from older versions without it use the issued at claim
+func (h *HttpServer) sessionRevoked(ctx context.Context, subject string, issuedAt *jwt.NumericDate, issuedNano int64) bool {
+	if issuedNano != 0 {
+		return h.revokedSince(ctx, subject, time.Unix(0, issuedNano))
+	}
+	if issuedAt == nil {
+		return true
+	}
+	return h.revokedSince(ctx, subject, issuedAt.Time)
+}
+
+// revokedSince returns true if the sessions of the user were revoked at or
+// after the given time, database errors are treated as revoked
+func (h *HttpServer) revokedSince(ctx context.Context, subject string, t time.Time) bool {
+	var revokedAt sql.NullTime
+	err := h.DbTxError(func(tx *database.Queries) (err error) {
+		revokedAt, err = tx.GetSessionsRevokedAt(ctx, subject)
+		return
+	})
+	if err != nil {
+		return true
+	}
+	if !revokedAt.Valid {
+		return false
+	}
+	return !t.After(revokedAt.Time)
+}
+
+// revokeSessions logs out every existing session for the user and returns the
+// revocation time
+//
+// OAuth refresh tokens from before the revocation can't be used and the access
+// tokens are rejected by the userinfo, introspection and forward-auth
+// endpoints. Clients which only check the signature of an access token accept
+// it until it expires.
+func revokeSessions(ctx context.Context, tx *database.Queries, subject string) (time.Time, error) {
+	now := time.Now()
+	return now, tx.RevokeSessions(ctx, database.RevokeSessionsParams{
+		SessionsRevokedAt: sql.NullTime{Time: now, Valid: true},
+		Subject:           subject,
+	})
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestNewSessionSettings(t *testing.T) {
+	s, err := newSessionSettings(SessionConf{})
+	assert.NoError(t, err)
+	assert.Equal(t, defaultAccessLifetime, s.access)
+	assert.Equal(t, defaultRefreshLifetime, s.refresh)
+	assert.Equal(t, time.Duration(0), s.idle)
+	assert.Equal(t, rememberMeOptional, s.rememberMe)
+
+	s, err = newSessionSettings(SessionConf{CookieDomain: ".example.com", AccessLifetime: "15m", RefreshLifetime: "168h", IdleTimeout: "2h", RememberMe: "never"})
+	assert.NoError(t, err)
+	assert.Equal(t, ".example.com", s.cookieDomain)
+	assert.Equal(t, 15*time.Minute, s.access)
+	assert.Equal(t, 7*24*time.Hour, s.refresh)
+	assert.Equal(t, 2*time.Hour, s.idle)
+	assert.Equal(t, rememberMeNever, s.rememberMe)
+
+	_, err = newSessionSettings(SessionConf{AccessLifetime: "soon"})
+	assert.Error(t, err)
+	_, err = newSessionSettings(SessionConf{RefreshLifetime: "-1h"})
+	assert.Error(t, err)
+	_, err = newSessionSettings(SessionConf{IdleTimeout: "1h"})
+	assert.Error(t, err)
+	_, err = newSessionSettings(SessionConf{RememberMe: "sometimes"})
+	assert.Error(t, err)
+}
+
+func TestSessionSettings_Persistent(t *testing.T) {
+	assert.True(t, sessionSettings{rememberMe: rememberMeOptional}.persistent(true))
+	assert.False(t, sessionSettings{rememberMe: rememberMeOptional}.persistent(false))
+	assert.True(t, sessionSettings{rememberMe: rememberMeAlways}.persistent(false))
+	assert.False(t, sessionSettings{rememberMe: rememberMeNever}.persistent(true))
+}
+
+func TestSessionSettings_RefreshExpiry(t *testing.T) {
+	start := time.Now()
+	s := sessionSettings{refresh: 24 * time.Hour}
+	assert.Equal(t, start.Add(24*time.Hour), s.refreshExpiry(start, start.Add(time.Hour)))
+
+	s.idle = 2 * time.Hour
+	assert.Equal(t, start.Add(3*time.Hour), s.refreshExpiry(start, start.Add(time.Hour)))
+	assert.Equal(t, start.Add(24*time.Hour), s.refreshExpiry(start, start.Add(23*time.Hour)))
+}
+
+func TestReadLoginRefreshCookie_KeepsNeedOtp(t *testing.T) {
+	h := newTestServer(t)
+	subject := addTestUser(t, h, "admin")
+
+	rec := httptest.NewRecorder()
+	assert.False(t, h.setLoginDataCookie(rec, UserAuth{Subject: subject, NeedOtp: true}))
+
+	// dropping the access cookie must not skip the OTP step
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "tulip-login-refresh" {
+			req.AddCookie(c)
+		}
+	}
+	var u UserAuth
+	require.NoError(t, h.readLoginAccessCookie(httptest.NewRecorder(), req, &u))
+	assert.Equal(t, subject, u.Subject)
+	assert.True(t, u.NeedOtp)
+}
+
+func TestClearLoginCookies(t *testing.T) {
+	rec := httptest.NewRecorder()
+	(&HttpServer{}).clearLoginCookies(rec)
+	assert.Len(t, rec.Result().Cookies(), 2)
+
+	rec = httptest.NewRecorder()
+	(&HttpServer{sessions: sessionSettings{cookieDomain: ".example.com"}}).clearLoginCookies(rec)
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 4)
+	var hostOnly int
+	for _, c := range cookies {
+		assert.Equal(t, -1, c.MaxAge)
+		if c.Domain == "" {
+			hostOnly++
+		}
+	}
+	assert.Equal(t, 2, hostOnly)
+}
+
+func TestRevokeSessions(t *testing.T) {
+	h := newTestServer(t)
+	subject := addTestUser(t, h, "admin")
+	addTestClient(t, h, "app", subject, "public")
+	ti := issueTestToken(t, h, "app", subject, "openid")
+	require.NotEmpty(t, ti.GetRefresh())
+	rec := httptest.NewRecorder()
+	assert.False(t, h.setLoginDataCookie(rec, UserAuth{Subject: subject}))
+
+	revokedAt, err := revokeSessions(context.Background(), h.db, subject)
+	require.NoError(t, err)
+
+	// only tokens issued before the revocation are revoked
+	assert.True(t, h.revokedSince(context.Background(), subject, revokedAt.Add(-time.Millisecond)))
+	assert.True(t, h.revokedSince(context.Background(), subject, revokedAt))
+	assert.False(t, h.revokedSince(context.Background(), subject, revokedAt.Add(time.Millisecond)))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	var u UserAuth
+	assert.ErrorIs(t, h.readLoginAccessCookie(httptest.NewRecorder(), req, &u), errSessionRevoked)
+
+	// the OAuth access and refresh tokens are revoked too
+	_, err = h.loadAccessToken(context.Background(), ti.GetAccess())
+	assert.Error(t, err)
+	assert.Equal(t, map[string]any{"active": false}, introspectTestToken(t, h, "app", ti.GetAccess()))
+	assert.Equal(t, map[string]any{"active": false}, introspectTestToken(t, h, "app", ti.GetRefresh()))
+
+	// a login in the same second as the revocation is still valid
+	rec = httptest.NewRecorder()
+	assert.False(t, h.setLoginDataCookie(rec, UserAuth{Subject: subject}))
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	assert.NoError(t, h.readLoginAccessCookie(httptest.NewRecorder(), req, &u))
+	assert.Equal(t, subject, u.Subject)
+}
+
+func TestEditPasswordPost_KeepsCurrentSession(t *testing.T) {
+	h := newTestServer(t)
+	subject := addTestUser(t, h, "alice")
+	rec := httptest.NewRecorder()
+	assert.False(t, h.setLoginDataCookie(rec, UserAuth{Subject: subject}))
+	oldCookies := rec.Result().Cookies()
+
+	pw := "a different horse battery staple"
+	rec = postEditForm(h.EditPasswordPost, subject, url.Values{
+		"password":         {"correct horse battery staple"},
+		"new_password":     {pw},
+		"confirm_password": {pw},
+	})
+	assert.Equal(t, http.StatusFound, rec.Code)
+
+	// the cookies from the password change stay logged in and the old ones don't
+	var u UserAuth
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	assert.NoError(t, h.readLoginAccessCookie(httptest.NewRecorder(), req, &u))
+	assert.Equal(t, subject, u.Subject)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range oldCookies {
+		req.AddCookie(c)
+	}
+	assert.ErrorIs(t, h.readLoginAccessCookie(httptest.NewRecorder(), req, &u), errSessionRevoked)
+}
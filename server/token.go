@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/errors"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// authenticateClient reads the client credentials from the request and checks
+// them against the client store
+func (h *HttpServer) authenticateClient(req *http.Request) (oauth2.ClientInfo, error) {
+	clientId, clientSecret, err := h.oauthSrv.ClientInfoHandler(req)
+	if err != nil {
+		return nil, errors.ErrInvalidClient
+	}
+	client, err := h.oauthMgr.GetClient(req.Context(), clientId)
+	if err != nil {
+		return nil, errors.ErrInvalidClient
+	}
+	if clientIsActive, ok := client.(interface{ IsActive() bool }); ok && !clientIsActive.IsActive() {
+		return nil, errors.ErrInvalidClient
+	}
+	if subtle.ConstantTimeCompare([]byte(client.GetSecret()), []byte(clientSecret)) != 1 {
+		return nil, errors.ErrInvalidClient
+	}
+	return client, nil
+}
+
+// writeOAuthError outputs the JSON error response for err
+func (h *HttpServer) writeOAuthError(rw http.ResponseWriter, err error) {
+	data, statusCode, header := h.oauthSrv.GetErrorData(err)
+	for k := range header {
+		rw.Header().Set(k, header.Get(k))
+	}
+	rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	rw.WriteHeader(statusCode)
+	_ = json.NewEncoder(rw).Encode(data)
+}
+
+// loadTokenInfo finds the token info for an access or refresh token, the token
+// type hint is used to decide which type is checked first
+func (h *HttpServer) loadTokenInfo(ctx context.Context, token, hint string) (ti oauth2.TokenInfo, isRefresh bool) {
+	if isPersonalToken(token) {
+		ti, err := h.loadPersonalToken(ctx, token)
+		if err != nil {
+			return nil, false
+		}
+		return ti, false
+	}
+
+	loadAccess := func() bool {
+		var err error
+		ti, err = h.loadAccessToken(ctx, token)
+		return err == nil
+	}
+	loadRefresh := func() bool {
+		var err error
+		ti, err = h.oauthMgr.LoadRefreshToken(ctx, token)
+		return err == nil && (ti.GetUserID() == "" || !h.revokedSince(ctx, ti.GetUserID(), ti.GetRefreshCreateAt()))
+	}
+
+	if hint == "refresh_token" {
+		if loadRefresh() {
+			return ti, true
+		}
+		if loadAccess() {
+			return ti, false
+		}
+		return nil, false
+	}
+	if loadAccess() {
+		return ti, false
+	}
+	if loadRefresh() {
+		return ti, true
+	}
+	return nil, false
+}
+
+// RevokeToken implements the RFC 7009 token revocation endpoint
+func (h *HttpServer) RevokeToken(rw http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	client, err := h.authenticateClient(req)
+	if err != nil {
+		h.writeOAuthError(rw, err)
+		return
+	}
+
+	token := req.PostFormValue("token")
+	if token == "" {
+		h.writeOAuthError(rw, errors.ErrInvalidRequest)
+		return
+	}
+
+	// invalid tokens and tokens owned by other clients are ignored, the response
+	// is the same for both cases
+	ti, isRefresh := h.loadTokenInfo(req.Context(), token, req.PostFormValue("token_type_hint"))
+	if ti != nil && ti.GetClientID() == client.GetID() {
+		// revoking the refresh token also revokes the access token from the same grant
+		if isRefresh {
+			_ = h.oauthMgr.RemoveRefreshToken(req.Context(), ti.GetRefresh())
+		}
+		_ = h.oauthMgr.RemoveAccessToken(req.Context(), ti.GetAccess())
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+// IntrospectToken implements the RFC 7662 token introspection endpoint
+func (h *HttpServer) IntrospectToken(rw http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	client, err := h.authenticateClient(req)
+	if err != nil {
+		h.writeOAuthError(rw, err)
+		return
+	}
+
+	token := req.PostFormValue("token")
+	if token == "" {
+		h.writeOAuthError(rw, errors.ErrInvalidRequest)
+		return
+	}
+
+	// clients can only introspect their own tokens, tokens owned by other clients
+	// and personal access tokens are reported as inactive
+	m := map[string]any{"active": false}
+	ti, isRefresh := h.loadTokenInfo(req.Context(), token, req.PostFormValue("token_type_hint"))
+	if ti != nil && ti.GetClientID() == client.GetID() {
+		m["active"] = true
+		m["scope"] = ti.GetScope()
+		m["client_id"] = ti.GetClientID()
+		m["aud"] = ti.GetClientID()
+		m["iss"] = h.conf.BaseUrl
+		if ti.GetUserID() != "" {
+			m["sub"], err = h.clientSubject(req.Context(), ti.GetClientID(), ti.GetUserID())
+			if err != nil {
+				http.Error(rw, "500 Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+		}
+		if isRefresh {
+			m["token_type"] = "refresh_token"
+			m["iat"] = ti.GetRefreshCreateAt().Unix()
+			if ti.GetRefreshExpiresIn() != 0 {
+				m["exp"] = ti.GetRefreshCreateAt().Add(ti.GetRefreshExpiresIn()).Unix()
+			}
+		} else {
+			m["token_type"] = "Bearer"
+			m["iat"] = ti.GetAccessCreateAt().Unix()
+			m["exp"] = ti.GetAccessCreateAt().Add(ti.GetAccessExpiresIn()).Unix()
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(m)
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/1f349/tulip/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func introspectTestToken(t *testing.T, h *HttpServer, clientId, token string) map[string]any {
+	req := httptest.NewRequest(http.MethodPost, "/introspect", strings.NewReader(url.Values{"token": {token}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(clientId, clientId)
+	rec := httptest.NewRecorder()
+	h.IntrospectToken(rec, req, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var m map[string]any
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&m))
+	return m
+}
+
+func TestIntrospectToken(t *testing.T) {
+	h := newTestServer(t)
+	subject := addTestUser(t, h, "admin")
+	addTestClient(t, h, "app", subject, "public")
+	addTestClient(t, h, "other", subject, "public")
+
+	ti := issueTestToken(t, h, "app", subject, "openid")
+	m := introspectTestToken(t, h, "app", ti.GetAccess())
+	assert.Equal(t, true, m["active"])
+	assert.Equal(t, subject, m["sub"])
+	assert.Equal(t, "app", m["client_id"])
+
+	// tokens from other clients are hidden
+	assert.Equal(t, map[string]any{"active": false}, introspectTestToken(t, h, "other", ti.GetAccess()))
+
+	// personal access tokens are not issued to a client
+	token, err := generatePersonalToken()
+	require.NoError(t, err)
+	require.NoError(t, h.db.AddPersonalToken(context.Background(), database.AddPersonalTokenParams{
+		ID:        "pat",
+		Subject:   subject,
+		Name:      "pat",
+		TokenHash: hashPersonalToken(token),
+		Scope:     "openid",
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}))
+	assert.Equal(t, map[string]any{"active": false}, introspectTestToken(t, h, "app", token))
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"database/sql"
+	"github.com/1f349/tulip/database"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	trustedDeviceCookie   = "tulip-trusted-device"
+	trustedDeviceLifetime = 30 * 24 * time.Hour
+	trustedDeviceMaxName  = 128
+)
+
+// trustedDeviceClaims contains the ID of the device record, the subject of the
+// token is the user
+//
+// mjwt can't sign claims which encode as an empty object so the ID can't be
+// left in the token ID.
+type trustedDeviceClaims struct {
+	DeviceId string `json:"device"`
+}
+
+func (t trustedDeviceClaims) Valid() error { return nil }
+func (t trustedDeviceClaims) Type() string { return "trusted-device" }
+
+// trustedDeviceName returns the name shown in the list of trusted devices
+func trustedDeviceName(userAgent string) string {
+	name := []rune(strings.TrimSpace(userAgent))
+	if len(name) == 0 {
+		return "Unknown device"
+	}
+	if len(name) > trustedDeviceMaxName {
+		name = name[:trustedDeviceMaxName]
+	}
+	return string(name)
+}
+
+// trustDevice creates a device record for the subject and sets the signed
+// device cookie, the returned bool is true if an error was written
+func (h *HttpServer) trustDevice(rw http.ResponseWriter, req *http.Request, subject string) bool {
+	id := uuid.NewString()
+	now := time.Now()
+	if h.DbTx(rw, func(tx *database.Queries) error {
+		err := tx.DeleteExpiredTrustedDevices(req.Context(), database.DeleteExpiredTrustedDevicesParams{
+			Subject:   subject,
+			ExpiresAt: now,
+		})
+		if err != nil {
+			return err
+		}
+		return tx.AddTrustedDevice(req.Context(), database.AddTrustedDeviceParams{
+			ID:        id,
+			Subject:   subject,
+			Name:      trustedDeviceName(req.UserAgent()),
+			CreatedAt: now,
+			ExpiresAt: now.Add(trustedDeviceLifetime),
+		})
+	}) {
+		return true
+	}
+
+	gen, err := h.signingKey.GenerateJwt(subject, uuid.NewString(), jwt.ClaimStrings{h.conf.BaseUrl}, trustedDeviceLifetime, trustedDeviceClaims{DeviceId: id})
+	if err != nil {
+		http.Error(rw, "Failed to generate cookie token", http.StatusInternalServerError)
+		return true
+	}
+	http.SetCookie(rw, &http.Cookie{
+		Name:     trustedDeviceCookie,
+		Value:    gen,
+		Path:     "/",
+		Expires:  now.Add(trustedDeviceLifetime),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return false
+}
+
+// isTrustedDevice returns true if the request has a device cookie for the
+// subject and the device record still exists, records are removed when the
+// password or OTP changes
+func (h *HttpServer) isTrustedDevice(req *http.Request, subject string) bool {
+	device, err := readJwtCookie[trustedDeviceClaims](req, trustedDeviceCookie, h.signingKey)
+	if err != nil || device.Subject != subject || device.Claims.DeviceId == "" {
+		return false
+	}
+	var trusted bool
+	err = h.DbTxError(func(tx *database.Queries) (err error) {
+		trusted, err = tx.HasTrustedDevice(req.Context(), database.HasTrustedDeviceParams{
+			ID:        device.Claims.DeviceId,
+			Subject:   subject,
+			ExpiresAt: time.Now(),
+		})
+		if err != nil || !trusted {
+			return
+		}
+		return tx.SetTrustedDeviceLastUsed(req.Context(), database.SetTrustedDeviceLastUsedParams{
+			LastUsed: sql.NullTime{Time: time.Now(), Valid: true},
+			ID:       device.Claims.DeviceId,
+		})
+	})
+	return err == nil && trusted
+}
+
+func (h *HttpServer) EditTrustedDeviceDeletePost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	if h.DbTx(rw, func(tx *database.Queries) error {
+		return tx.DeleteTrustedDevice(req.Context(), database.DeleteTrustedDeviceParams{
+			ID:      req.PostFormValue("id"),
+			Subject: auth.Subject,
+		})
+	}) {
+		return
+	}
+	http.Redirect(rw, req, "/edit", http.StatusFound)
+}
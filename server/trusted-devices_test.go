@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"github.com/1f349/tulip/database"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xlzd/gotp"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrustedDeviceName(t *testing.T) {
+	assert.Equal(t, "Unknown device", trustedDeviceName(""))
+	assert.Equal(t, "Mozilla/5.0", trustedDeviceName(" Mozilla/5.0 "))
+	assert.Equal(t, strings.Repeat("é", trustedDeviceMaxName), trustedDeviceName(strings.Repeat("é", 200)))
+}
+
+// trustTestDevice returns the device cookie for a new trusted device
+func trustTestDevice(t *testing.T, h *HttpServer, subject string) *http.Cookie {
+	rec := httptest.NewRecorder()
+	require.False(t, h.trustDevice(rec, httptest.NewRequest(http.MethodPost, "/", nil), subject), rec.Body.String())
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == trustedDeviceCookie {
+			return c
+		}
+	}
+	t.Fatal("missing trusted device cookie")
+	return nil
+}
+
+func trustedDeviceRequest(cookie *http.Cookie) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	return req
+}
+
+// postEditForm calls the handler with a form submitted by the user
+func postEditForm(handler func(http.ResponseWriter, *http.Request, httprouter.Params, UserAuth), subject string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/edit", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler(rec, req, nil, UserAuth{Subject: subject})
+	return rec
+}
+
+func TestIsTrustedDevice(t *testing.T) {
+	h := newTestServer(t)
+	alice := addTestUser(t, h, "alice")
+	bob := addTestUser(t, h, "bob")
+
+	cookie := trustTestDevice(t, h, alice)
+	assert.True(t, h.isTrustedDevice(trustedDeviceRequest(cookie), alice))
+	assert.False(t, h.isTrustedDevice(httptest.NewRequest(http.MethodGet, "/", nil), alice))
+
+	// the cookie only trusts the device for the user who signed in
+	assert.False(t, h.isTrustedDevice(trustedDeviceRequest(cookie), bob))
+
+	devices, err := h.db.GetTrustedDevices(context.Background(), database.GetTrustedDevicesParams{Subject: alice, ExpiresAt: time.Now()})
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	assert.True(t, devices[0].LastUsed.Valid)
+
+	// removing the device from the account page stops the cookie working
+	rec := postEditForm(h.EditTrustedDeviceDeletePost, alice, url.Values{"id": {devices[0].ID}})
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.False(t, h.isTrustedDevice(trustedDeviceRequest(cookie), alice))
+}
+
+func TestEditPasswordPost_DeletesTrustedDevices(t *testing.T) {
+	h := newTestServer(t)
+	subject := addTestUser(t, h, "alice")
+	cookie := trustTestDevice(t, h, subject)
+
+	pw := "a different horse battery staple"
+	rec := postEditForm(h.EditPasswordPost, subject, url.Values{
+		"password":         {"correct horse battery staple"},
+		"new_password":     {pw},
+		"confirm_password": {pw},
+	})
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.False(t, h.isTrustedDevice(trustedDeviceRequest(cookie), subject))
+}
+
+func TestEditOtpPost_DeletesTrustedDevices(t *testing.T) {
+	h := newTestServer(t)
+	subject := addTestUser(t, h, "alice")
+	cookie := trustTestDevice(t, h, subject)
+
+	secret := gotp.RandomSecret(64)
+	totp := gotp.NewTOTP(secret, 6, 30, nil)
+	rec := postEditForm(h.EditOtpPost, subject, url.Values{"digits": {"6"}, "secret": {secret}, "code": {totp.Now()}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	hasOtp, err := h.db.HasOtp(context.Background(), subject)
+	require.NoError(t, err)
+	assert.True(t, hasOtp)
+	assert.False(t, h.isTrustedDevice(trustedDeviceRequest(cookie), subject))
+
+	// removing OTP also deletes the devices trusted since it was added
+	cookie = trustTestDevice(t, h, subject)
+	rec = postEditForm(h.EditOtpPost, subject, url.Values{"remove": {"1"}, "code": {totp.Now()}})
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.False(t, h.isTrustedDevice(trustedDeviceRequest(cookie), subject))
+	hasOtp, err = h.db.HasOtp(context.Background(), subject)
+	require.NoError(t, err)
+	assert.False(t, hasOtp)
+}
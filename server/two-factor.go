@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/pages"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const defaultTwoFactorGracePeriod = 7 * 24 * time.Hour
+
+// TwoFactorConf requires users to add an OTP or passkey, Required applies to
+// every user and Groups applies to members of the named groups
+//
+// Users without a second factor are sent to the enrolment page after each login
+// and can skip it until the grace period ends, after that they can't continue
+// without adding one.
+type TwoFactorConf struct {
+	Required    bool     `json:"required"`
+	Groups      []string `json:"groups"`
+	GracePeriod string   `json:"grace_period"`
+}
+
+// twoFactorPolicy is the parsed TwoFactorConf
+type twoFactorPolicy struct {
+	required bool
+	groups   []string
+	grace    time.Duration
+}
+
+func newTwoFactorPolicy(conf TwoFactorConf) (twoFactorPolicy, error) {
+	p := twoFactorPolicy{
+		required: conf.Required,
+		groups:   conf.Groups,
+		grace:    defaultTwoFactorGracePeriod,
+	}
+	if conf.GracePeriod != "" {
+		d, err := time.ParseDuration(conf.GracePeriod)
+		if err != nil {
+			return p, fmt.Errorf("invalid two factor grace period: %w", err)
+		}
+		if d < 0 {
+			return p, errors.New("two factor grace period must not be negative")
+		}
+		p.grace = d
+	}
+	return p, nil
+}
+
+func (p twoFactorPolicy) enabled() bool {
+	return p.required || len(p.groups) > 0
+}
+
+// appliesTo returns true if a user in the groups must have a second factor
+func (p twoFactorPolicy) appliesTo(groups []string) bool {
+	if p.required {
+		return true
+	}
+	return slices.ContainsFunc(groups, func(g string) bool { return slices.Contains(p.groups, g) })
+}
+
+// twoFactorStatus is the state of the policy for a single user
+type twoFactorStatus struct {
+	required bool
+	enrolled bool
+	deadline time.Time
+}
+
+// pending returns true if the user must add a second factor
+func (s twoFactorStatus) pending() bool {
+	return s.required && !s.enrolled
+}
+
+// overdue returns true if the grace period has ended and the enrolment page
+// can't be skipped
+func (s twoFactorStatus) overdue(now time.Time) bool {
+	return s.pending() && !now.Before(s.deadline)
+}
+
+// twoFactorRequired returns true if the policy applies to the user
+func (h *HttpServer) twoFactorRequired(ctx context.Context, tx *database.Queries, subject string) (bool, error) {
+	if !h.twoFactor.enabled() {
+		return false, nil
+	}
+	if h.twoFactor.required {
+		return true, nil
+	}
+	groups, err := tx.GetUserGroupNames(ctx, subject)
+	if err != nil {
+		return false, err
+	}
+	return h.twoFactor.appliesTo(groups), nil
+}
+
+// twoFactorStatus loads the policy state for the user, the grace period starts
+// the first time a user without a second factor is found and is cleared once
+// the policy no longer applies
+func (h *HttpServer) twoFactorStatus(ctx context.Context, subject string) (status twoFactorStatus, err error) {
+	if !h.twoFactor.enabled() {
+		return
+	}
+	err = h.DbTxError(func(tx *database.Queries) error {
+		user, err := tx.GetUser(ctx, subject)
+		if err != nil {
+			return err
+		}
+		status.required, err = h.twoFactorRequired(ctx, tx, subject)
+		if err != nil {
+			return err
+		}
+		hasOtp, err := tx.HasOtp(ctx, subject)
+		if err != nil {
+			return err
+		}
+		hasWebauthn, err := tx.HasWebauthn(ctx, subject)
+		if err != nil {
+			return err
+		}
+		status.enrolled = hasOtp || hasWebauthn
+
+		deadline := user.TwoFactorDeadline
+		switch {
+		case status.pending() && !deadline.Valid:
+			deadline = sql.NullTime{Time: time.Now().Add(h.twoFactor.grace), Valid: true}
+		case !status.pending() && deadline.Valid:
+			deadline = sql.NullTime{}
+		default:
+			status.deadline = deadline.Time
+			return nil
+		}
+		status.deadline = deadline.Time
+		return tx.SetTwoFactorDeadline(ctx, database.SetTwoFactorDeadlineParams{
+			TwoFactorDeadline: deadline,
+			Subject:           subject,
+		})
+	})
+	return
+}
+
+// needTwoFactorSetup returns true if the user must be sent to the enrolment
+// page, after a login this is any user without a second factor and otherwise
+// only users past the end of the grace period
+func (h *HttpServer) needTwoFactorSetup(ctx context.Context, subject string, login bool) (bool, error) {
+	status, err := h.twoFactorStatus(ctx, subject)
+	if err != nil {
+		return false, err
+	}
+	if login {
+		return status.pending(), nil
+	}
+	return status.overdue(time.Now()), nil
+}
+
+func (h *HttpServer) LoginTwoFactorGet(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	status, err := h.twoFactorStatus(req.Context(), auth.Subject)
+	if err != nil {
+		http.Error(rw, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !status.pending() {
+		// the user has added a second factor or the policy no longer applies
+		if auth.NeedTwoFactorSetup {
+			auth.NeedTwoFactorSetup = false
+			if h.setLoginDataCookie(rw, auth) {
+				return
+			}
+		}
+		h.SafeRedirect(rw, req)
+		return
+	}
+
+	pages.RenderPageTemplate(rw, "login-two-factor", map[string]any{
+		"ServiceName": h.conf.ServiceName,
+		"Redirect":    req.URL.Query().Get("redirect"),
+		"Deadline":    status.deadline,
+		"CanSkip":     !status.overdue(time.Now()),
+	})
+}
+
+func (h *HttpServer) LoginTwoFactorSkipPost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	status, err := h.twoFactorStatus(req.Context(), auth.Subject)
+	if err != nil {
+		http.Error(rw, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if status.overdue(time.Now()) {
+		http.Error(rw, "403 Forbidden: Two-factor authentication must be enabled to continue", http.StatusForbidden)
+		return
+	}
+	auth.NeedTwoFactorSetup = false
+	if h.setLoginDataCookie(rw, auth) {
+		return
+	}
+	h.SafeRedirect(rw, req)
+}
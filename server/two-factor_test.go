@@ -0,0 +1,45 @@
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewTwoFactorPolicy(t *testing.T) {
+	p, err := newTwoFactorPolicy(TwoFactorConf{})
+	assert.NoError(t, err)
+	assert.False(t, p.enabled())
+	assert.Equal(t, defaultTwoFactorGracePeriod, p.grace)
+
+	p, err = newTwoFactorPolicy(TwoFactorConf{Groups: []string{"admins"}, GracePeriod: "0s"})
+	assert.NoError(t, err)
+	assert.True(t, p.enabled())
+	assert.Equal(t, time.Duration(0), p.grace)
+
+	_, err = newTwoFactorPolicy(TwoFactorConf{GracePeriod: "a week"})
+	assert.Error(t, err)
+	_, err = newTwoFactorPolicy(TwoFactorConf{GracePeriod: "-1h"})
+	assert.Error(t, err)
+}
+
+func TestTwoFactorPolicy_AppliesTo(t *testing.T) {
+	p := twoFactorPolicy{groups: []string{"admins"}}
+	assert.True(t, p.appliesTo([]string{"users", "admins"}))
+	assert.False(t, p.appliesTo([]string{"users"}))
+	assert.False(t, p.appliesTo(nil))
+	p.required = true
+	assert.True(t, p.appliesTo(nil))
+}
+
+func TestTwoFactorStatus_Overdue(t *testing.T) {
+	now := time.Now()
+	s := twoFactorStatus{required: true, deadline: now.Add(time.Hour)}
+	assert.True(t, s.pending())
+	assert.False(t, s.overdue(now))
+	assert.True(t, s.overdue(now.Add(time.Hour)))
+
+	s.enrolled = true
+	assert.False(t, s.pending())
+	assert.False(t, s.overdue(now.Add(time.Hour)))
+}
@@ -0,0 +1,336 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/logger"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/oauth2"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const upstreamStateCookie = "tulip-upstream"
+
+// UpstreamConf defines an upstream OpenID Connect provider which users can
+// login with
+type UpstreamConf struct {
+	// Name is used in the login and callback URLs
+	Name         string   `json:"name"`
+	DisplayName  string   `json:"display_name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+	// LinkByEmail links an unknown upstream identity to the only active user
+	// with the same verified email address
+	LinkByEmail bool `json:"link_by_email"`
+}
+
+// upstreamProvider discovers the provider configuration on first use so an
+// unreachable provider does not stop tulip from starting
+type upstreamProvider struct {
+	conf     UpstreamConf
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+// upstreamIdentity contains the verified claims from the upstream ID token
+type upstreamIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// upstreamState contains the state of an authorization code flow between the
+// redirect to the provider and the callback
+type upstreamState struct {
+	provider string
+	nonce    string
+	verifier string
+	redirect string
+	// link is the subject of the user linking an identity from the edit
+	// page, this is empty when logging in
+	link string
+}
+
+func newUpstreamProviders(conf []UpstreamConf) map[string]*upstreamProvider {
+	m := make(map[string]*upstreamProvider, len(conf))
+	for _, i := range conf {
+		m[i.Name] = &upstreamProvider{conf: i}
+	}
+	return m
+}
+
+func (u *upstreamProvider) discover(ctx context.Context) (*oidc.Provider, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.provider != nil {
+		return u.provider, nil
+	}
+	p, err := oidc.NewProvider(ctx, u.conf.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	u.provider = p
+	return p, nil
+}
+
+func (u *upstreamProvider) oauth2Config(p *oidc.Provider, redirectUrl string) *oauth2.Config {
+	scopes := u.conf.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email"}
+	}
+	return &oauth2.Config{
+		ClientID:     u.conf.ClientID,
+		ClientSecret: u.conf.ClientSecret,
+		Endpoint:     p.Endpoint(),
+		RedirectURL:  redirectUrl,
+		Scopes:       scopes,
+	}
+}
+
+// exchange swaps the authorization code for tokens and verifies the ID token
+func (u *upstreamProvider) exchange(ctx context.Context, redirectUrl, code, verifier, nonce string) (upstreamIdentity, error) {
+	p, err := u.discover(ctx)
+	if err != nil {
+		return upstreamIdentity{}, err
+	}
+	token, err := u.oauth2Config(p, redirectUrl).Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return upstreamIdentity{}, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	rawIdToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return upstreamIdentity{}, errors.New("missing id_token")
+	}
+	idToken, err := p.Verifier(&oidc.Config{ClientID: u.conf.ClientID}).Verify(ctx, rawIdToken)
+	if err != nil {
+		return upstreamIdentity{}, fmt.Errorf("failed to verify id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return upstreamIdentity{}, errors.New("invalid id_token nonce")
+	}
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return upstreamIdentity{}, fmt.Errorf("failed to parse id_token claims: %w", err)
+	}
+	return upstreamIdentity{
+		Issuer:        idToken.Issuer,
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+	}, nil
+}
+
+func (h *HttpServer) upstreamCallbackUrl(name string) string {
+	return h.conf.BaseUrl + "/login/upstream/" + url.PathEscape(name) + "/callback"
+}
+
+// upstreamNames maps the issuer of each provider to the display name
+func (h *HttpServer) upstreamNames() map[string]string {
+	m := make(map[string]string, len(h.conf.Upstreams))
+	for _, i := range h.conf.Upstreams {
+		m[i.Issuer] = i.DisplayName
+	}
+	return m
+}
+
+// startUpstream redirects the user to the upstream provider
+func (h *HttpServer) startUpstream(rw http.ResponseWriter, req *http.Request, name, link, redirect string) {
+	u, ok := h.upstreams[name]
+	if !ok {
+		http.NotFound(rw, req)
+		return
+	}
+	p, err := u.discover(req.Context())
+	if err != nil {
+		logger.Logger.Warn("Upstream: Failed to discover provider", "provider", name, "err", err)
+		http.Error(rw, "502 Bad Gateway: Failed to contact upstream provider", http.StatusBadGateway)
+		return
+	}
+
+	state := uuid.NewString()
+	s := upstreamState{
+		provider: name,
+		nonce:    uuid.NewString(),
+		verifier: oauth2.GenerateVerifier(),
+		redirect: redirect,
+		link:     link,
+	}
+	h.upstreamStates.Set(state, s, time.Now().Add(10*time.Minute))
+	http.SetCookie(rw, &http.Cookie{
+		Name:     upstreamStateCookie,
+		Value:    state,
+		Path:     "/login/upstream",
+		Expires:  time.Now().Add(10 * time.Minute),
+		Secure:   true,
+		HttpOnly: true,
+		// the callback is a cross-site top level navigation
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	authUrl := u.oauth2Config(p, h.upstreamCallbackUrl(name)).AuthCodeURL(state, oidc.Nonce(s.nonce), oauth2.S256ChallengeOption(s.verifier))
+	http.Redirect(rw, req, authUrl, http.StatusFound)
+}
+
+func (h *HttpServer) LoginUpstreamGet(rw http.ResponseWriter, req *http.Request, params httprouter.Params, auth UserAuth) {
+	if !auth.IsGuest() {
+		h.SafeRedirect(rw, req)
+		return
+	}
+	h.startUpstream(rw, req, params.ByName("provider"), "", req.URL.Query().Get("redirect"))
+}
+
+func (h *HttpServer) LoginUpstreamCallbackGet(rw http.ResponseWriter, req *http.Request, params httprouter.Params, auth UserAuth) {
+	http.SetCookie(rw, &http.Cookie{
+		Name:     upstreamStateCookie,
+		Path:     "/login/upstream",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// the state must match the cookie so the flow can't be completed in
+	// another browser
+	q := req.URL.Query()
+	cookie, err := req.Cookie(upstreamStateCookie)
+	if err != nil || cookie.Value != q.Get("state") {
+		http.Error(rw, "400 Bad Request: Invalid state", http.StatusBadRequest)
+		return
+	}
+	s, ok := h.upstreamStates.Get(cookie.Value)
+	h.upstreamStates.Delete(cookie.Value)
+	if !ok || s.provider != params.ByName("provider") {
+		http.Error(rw, "400 Bad Request: Invalid state", http.StatusBadRequest)
+		return
+	}
+	if q.Has("error") {
+		http.Error(rw, "403 Forbidden: The upstream provider did not complete the login", http.StatusForbidden)
+		return
+	}
+
+	u := h.upstreams[s.provider]
+	identity, err := u.exchange(req.Context(), h.upstreamCallbackUrl(s.provider), q.Get("code"), s.verifier, s.nonce)
+	if err != nil {
+		logger.Logger.Warn("Upstream: Failed to complete login", "provider", s.provider, "err", err)
+		http.Error(rw, "400 Bad Request: Failed to verify the upstream login", http.StatusBadRequest)
+		return
+	}
+
+	if s.link != "" {
+		h.linkUpstreamIdentity(rw, req, auth, s.link, identity)
+		return
+	}
+
+	if h.loginBlocked(rw, req, sql.NullTime{}) {
+		return
+	}
+
+	var subject string
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		subject, err = tx.GetUpstreamIdentity(req.Context(), database.GetUpstreamIdentityParams{
+			Issuer:          identity.Issuer,
+			UpstreamSubject: identity.Subject,
+		})
+		if !errors.Is(err, sql.ErrNoRows) {
+			return
+		}
+		subject = ""
+		if !u.conf.LinkByEmail || !identity.EmailVerified || identity.Email == "" {
+			return nil
+		}
+
+		// link to the user with the same verified email address, the identity
+		// isn't linked if several users share the address
+		users, err := tx.GetLinkableUsersByEmail(req.Context(), identity.Email)
+		if err != nil || len(users) != 1 {
+			return
+		}
+		subject = users[0]
+		return tx.AddUpstreamIdentity(req.Context(), database.AddUpstreamIdentityParams{
+			Issuer:          identity.Issuer,
+			UpstreamSubject: identity.Subject,
+			Subject:         subject,
+			Email:           identity.Email,
+			LinkedAt:        time.Now(),
+		})
+	}) {
+		return
+	}
+
+	if subject == "" {
+		originUrl, err := url.Parse(s.redirect)
+		if err != nil {
+			http.Error(rw, "400 Bad Request: Invalid redirect URL", http.StatusBadRequest)
+			return
+		}
+		http.Redirect(rw, req, PrepareRedirectUrl("/login?mismatch=4", originUrl).String(), http.StatusFound)
+		return
+	}
+
+	h.finishExternalLogin(rw, req, subject, s.redirect)
+}
+
+// linkUpstreamIdentity adds the identity to the user who started the flow
+func (h *HttpServer) linkUpstreamIdentity(rw http.ResponseWriter, req *http.Request, auth UserAuth, link string, identity upstreamIdentity) {
+	if auth.IsGuest() || auth.Subject != link {
+		http.Error(rw, "403 Forbidden: Login again to link this account", http.StatusForbidden)
+		return
+	}
+
+	var linkedTo string
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		linkedTo, err = tx.GetUpstreamIdentity(req.Context(), database.GetUpstreamIdentityParams{
+			Issuer:          identity.Issuer,
+			UpstreamSubject: identity.Subject,
+		})
+		if !errors.Is(err, sql.ErrNoRows) {
+			return
+		}
+		linkedTo = auth.Subject
+		return tx.AddUpstreamIdentity(req.Context(), database.AddUpstreamIdentityParams{
+			Issuer:          identity.Issuer,
+			UpstreamSubject: identity.Subject,
+			Subject:         auth.Subject,
+			Email:           identity.Email,
+			LinkedAt:        time.Now(),
+		})
+	}) {
+		return
+	}
+	if linkedTo != auth.Subject {
+		http.Error(rw, "400 Bad Request: This login is already linked to another account", http.StatusBadRequest)
+		return
+	}
+	http.Redirect(rw, req, "/edit", http.StatusFound)
+}
+
+func (h *HttpServer) EditUpstreamLinkPost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	h.startUpstream(rw, req, req.PostFormValue("provider"), auth.Subject, "/edit")
+}
+
+func (h *HttpServer) EditUpstreamDeletePost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	if h.DbTx(rw, func(tx *database.Queries) error {
+		return tx.DeleteUpstreamIdentity(req.Context(), database.DeleteUpstreamIdentityParams{
+			Issuer:          req.PostFormValue("issuer"),
+			UpstreamSubject: req.PostFormValue("upstream_subject"),
+			Subject:         auth.Subject,
+		})
+	}) {
+		return
+	}
+	http.Redirect(rw, req, "/edit", http.StatusFound)
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/1f349/cache"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/database/types"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// testUpstream is a local stand-in for an upstream OpenID Connect provider
+type testUpstream struct {
+	srv       *httptest.Server
+	key       *rsa.PrivateKey
+	nonce     string
+	challenge string
+}
+
+func newTestUpstream(t *testing.T) *testUpstream {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	u := &testUpstream{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(rw http.ResponseWriter, req *http.Request) {
+		_ = json.NewEncoder(rw).Encode(map[string]any{
+			"issuer":                                u.srv.URL,
+			"authorization_endpoint":                u.srv.URL + "/authorize",
+			"token_endpoint":                        u.srv.URL + "/token",
+			"jwks_uri":                              u.srv.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(rw http.ResponseWriter, req *http.Request) {
+		_ = json.NewEncoder(rw).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(rw http.ResponseWriter, req *http.Request) {
+		sum := sha256.Sum256([]byte(req.PostFormValue("code_verifier")))
+		if req.PostFormValue("code") != "test-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != u.challenge {
+			http.Error(rw, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            u.srv.URL,
+			"sub":            "upstream-user",
+			"aud":            "tulip",
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"iat":            time.Now().Unix(),
+			"nonce":          u.nonce,
+			"email":          "user@example.com",
+			"email_verified": true,
+		})
+		token.Header["kid"] = "test"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(map[string]any{
+			"access_token": "test-access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+	u.srv = httptest.NewServer(mux)
+	t.Cleanup(u.srv.Close)
+	return u
+}
+
+func (u *testUpstream) conf() UpstreamConf {
+	return UpstreamConf{
+		Name:        "test",
+		DisplayName: "Test",
+		Issuer:      u.srv.URL,
+		ClientID:    "tulip",
+	}
+}
+
+func TestUpstreamProvider_Exchange(t *testing.T) {
+	u := newTestUpstream(t)
+	p := &upstreamProvider{conf: u.conf()}
+
+	verifier := "test-verifier-test-verifier-test-verifier-1234"
+	sum := sha256.Sum256([]byte(verifier))
+	u.challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+	u.nonce = "test-nonce"
+
+	identity, err := p.exchange(context.Background(), "https://tulip.example.com/callback", "test-code", verifier, "test-nonce")
+	assert.NoError(t, err)
+	assert.Equal(t, upstreamIdentity{
+		Issuer:        u.srv.URL,
+		Subject:       "upstream-user",
+		Email:         "user@example.com",
+		EmailVerified: true,
+	}, identity)
+
+	_, err = p.exchange(context.Background(), "https://tulip.example.com/callback", "test-code", verifier, "other-nonce")
+	assert.Error(t, err)
+
+	_, err = p.exchange(context.Background(), "https://tulip.example.com/callback", "test-code", "wrong-verifier", "test-nonce")
+	assert.Error(t, err)
+}
+
+func TestHttpServer_StartUpstream(t *testing.T) {
+	u := newTestUpstream(t)
+	h := &HttpServer{
+		conf:           Conf{BaseUrl: "https://tulip.example.com"},
+		upstreams:      newUpstreamProviders([]UpstreamConf{u.conf()}),
+		upstreamStates: cache.New[string, upstreamState](),
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/login/upstream/test?redirect=/edit", nil)
+	h.LoginUpstreamGet(rec, req, httprouter.Params{{Key: "provider", Value: "test"}}, UserAuth{})
+	assert.Equal(t, http.StatusFound, rec.Code)
+
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, u.srv.URL+"/authorize", loc.Scheme+"://"+loc.Host+loc.Path)
+	q := loc.Query()
+	assert.Equal(t, "https://tulip.example.com/login/upstream/test/callback", q.Get("redirect_uri"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+
+	s, ok := h.upstreamStates.Get(q.Get("state"))
+	assert.True(t, ok)
+	assert.Equal(t, "test", s.provider)
+	assert.Equal(t, "/edit", s.redirect)
+	assert.Equal(t, s.nonce, q.Get("nonce"))
+
+	// the callback is rejected without the state cookie
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/login/upstream/test/callback?code=test-code&state="+q.Get("state"), nil)
+	h.LoginUpstreamCallbackGet(rec, req, httprouter.Params{{Key: "provider", Value: "test"}}, UserAuth{})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/login/upstream/missing", nil)
+	h.LoginUpstreamGet(rec, req, httprouter.Params{{Key: "provider", Value: "missing"}}, UserAuth{})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// completeTestUpstream runs the upstream login flow and returns the callback
+// response
+func completeTestUpstream(t *testing.T, h *HttpServer, u *testUpstream) *httptest.ResponseRecorder {
+	params := httprouter.Params{{Key: "provider", Value: "test"}}
+	rec := httptest.NewRecorder()
+	h.LoginUpstreamGet(rec, httptest.NewRequest(http.MethodGet, "/login/upstream/test", nil), params, UserAuth{})
+	require.Equal(t, http.StatusFound, rec.Code)
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	state := loc.Query().Get("state")
+	s, ok := h.upstreamStates.Get(state)
+	require.True(t, ok)
+	sum := sha256.Sum256([]byte(s.verifier))
+	u.challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+	u.nonce = s.nonce
+
+	req := httptest.NewRequest(http.MethodGet, "/login/upstream/test/callback?code=test-code&state="+state, nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	rec = httptest.NewRecorder()
+	h.LoginUpstreamCallbackGet(rec, req, params, UserAuth{})
+	return rec
+}
+
+func TestHttpServer_UpstreamLinkByEmail(t *testing.T) {
+	u := newTestUpstream(t)
+	h := newTestServer(t)
+	ctx := context.Background()
+	conf := u.conf()
+	conf.LinkByEmail = true
+	h.upstreams = newUpstreamProviders([]UpstreamConf{conf})
+	h.upstreamStates = cache.New[string, upstreamState]()
+
+	user := addTestUser(t, h, "user")
+	other, err := h.db.AddUser(ctx, database.AddUserParams{
+		Name:          "other",
+		Username:      "other",
+		Password:      "correct horse battery staple",
+		Email:         "user@example.com",
+		EmailVerified: true,
+		Role:          types.RoleMember,
+		UpdatedAt:     time.Now(),
+		Active:        true,
+	})
+	require.NoError(t, err)
+	identity := database.GetUpstreamIdentityParams{Issuer: u.srv.URL, UpstreamSubject: "upstream-user"}
+
+	// the identity isn't linked when several users have the email address
+	rec := completeTestUpstream(t, h, u)
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "/login?mismatch=4", rec.Header().Get("Location"))
+	_, err = h.db.GetUpstreamIdentity(ctx, identity)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// disabled users are ignored
+	require.NoError(t, h.db.UpdateUserRole(ctx, database.UpdateUserRoleParams{Active: false, Role: types.RoleMember, Subject: other}))
+	rec = completeTestUpstream(t, h, u)
+	assert.Equal(t, http.StatusFound, rec.Code)
+	linked, err := h.db.GetUpstreamIdentity(ctx, identity)
+	require.NoError(t, err)
+	assert.Equal(t, user, linked)
+}
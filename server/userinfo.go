@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/1f349/tulip/database"
+	"github.com/julienschmidt/httprouter"
+	"maps"
+	"net/http"
+	"time"
+)
+
+// scopeClaims lists the claims returned by the userinfo endpoint for each scope,
+// this must match the output of genUserInfoClaims
+var scopeClaims = map[string][]string{
+	"openid":    {"sub", "aud", "updated_at"},
+	"name":      {"name", "given_name", "family_name", "middle_name", "nickname"},
+	"username":  {"preferred_username", "login"},
+	"profile":   {"profile", "picture", "website", "gender", "pronouns"},
+	"email":     {"email", "email_verified"},
+	"birthdate": {"birthdate"},
+	"age":       {"age"},
+	"zoneinfo":  {"zoneinfo"},
+	"locale":    {"locale"},
+	"groups":    {"groups"},
+	"address":   {"address"},
+	"phone":     {"phone_number", "phone_number_verified"},
+}
+
+func (h *HttpServer) UserInfo(rw http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	token, err := h.validateBearerToken(req)
+	if err != nil {
+		http.Error(rw, "403 Forbidden", http.StatusForbidden)
+		return
+	}
+	userId := token.GetUserID()
+
+	claims := ParseClaims(token.GetScope())
+	if !claims["openid"] {
+		http.Error(rw, "Invalid scope", http.StatusBadRequest)
+		return
+	}
+
+	userData, groups, err := h.loadUserClaims(req.Context(), userId)
+	if err != nil {
+		http.Error(rw, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	m := genUserInfoClaims(h.conf.BaseUrl, token.GetClientID(), userData, groups, claims)
+	allClaims := genUserInfoClaims(h.conf.BaseUrl, token.GetClientID(), userData, groups, allScopes())
+	maps.Copy(m, mapClaims(h.claimMappings(token.GetClientID()), claims, allClaims, groups))
+	m["sub"], err = h.clientSubject(req.Context(), token.GetClientID(), userId)
+	if err != nil {
+		http.Error(rw, "500 Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(rw).Encode(m)
+}
+
+// genUserInfoClaims outputs the claims of the user which are allowed by the
+// scope claims, groups contains the names of the groups the user is a member of
+func genUserInfoClaims(baseUrl, clientId string, userData database.User, groups []string, claims map[string]bool) map[string]any {
+	m := map[string]any{}
+	m["sub"] = userData.Subject
+	m["aud"] = clientId
+	if claims["name"] {
+		m["name"] = userData.Name
+		m["given_name"] = userData.GivenName
+		m["family_name"] = userData.FamilyName
+		m["middle_name"] = userData.MiddleName
+		m["nickname"] = userData.Nickname
+	}
+	if claims["username"] {
+		m["preferred_username"] = userData.Username
+		m["login"] = userData.Username
+	}
+	if claims["profile"] {
+		m["profile"] = baseUrl + "/user/" + userData.Username
+		m["picture"] = userData.Picture
+		m["website"] = userData.Website
+		m["gender"] = userData.Gender
+		m["pronouns"] = userData.Pronouns.String()
+	}
+	if claims["email"] {
+		m["email"] = userData.Email
+		m["email_verified"] = userData.EmailVerified
+	}
+	if claims["birthdate"] && userData.Birthdate.Valid {
+		m["birthdate"] = userData.Birthdate.Date.String()
+	}
+	if claims["age"] {
+		m["age"] = CalculateAge(userData.Birthdate.Date.ToTime().In(userData.Zoneinfo.Location))
+	}
+	if claims["zoneinfo"] {
+		m["zoneinfo"] = userData.Zoneinfo.Location.String()
+	}
+	if claims["locale"] {
+		m["locale"] = userData.Locale.Tag.String()
+	}
+	if claims["address"] {
+		m["address"] = map[string]any{
+			"formatted":      userData.Address.Formatted(),
+			"street_address": userData.Address.StreetAddress,
+			"locality":       userData.Address.Locality,
+			"region":         userData.Address.Region,
+			"postal_code":    userData.Address.PostalCode,
+			"country":        userData.Address.Country,
+		}
+	}
+	if claims["phone"] {
+		m["phone_number"] = userData.PhoneNumber
+		// phone numbers are entered by the user and never verified
+		m["phone_number_verified"] = false
+	}
+	if claims["groups"] {
+		if groups == nil {
+			groups = []string{}
+		}
+		m["groups"] = groups
+	}
+	m["updated_at"] = time.Now().Unix()
+	return m
+}
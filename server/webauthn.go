@@ -0,0 +1,357 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/1f349/tulip/database"
+	"github.com/1f349/tulip/logger"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const webauthnSessionCookie = "tulip-webauthn"
+
+// webauthnUser implements webauthn.User, the user handle is the user subject
+type webauthnUser struct {
+	subject       string
+	name          string
+	username      string
+	emailVerified bool
+	active        bool
+	lockedUntil   sql.NullTime
+	credentials   []webauthn.Credential
+}
+
+var _ webauthn.User = &webauthnUser{}
+
+func (u *webauthnUser) WebAuthnID() []byte                         { return []byte(u.subject) }
+func (u *webauthnUser) WebAuthnName() string                       { return u.username }
+func (u *webauthnUser) WebAuthnDisplayName() string                { return u.name }
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+func (u *webauthnUser) WebAuthnIcon() string                       { return "" }
+
+// webauthnSession contains the state of a ceremony between the begin and
+// finish requests, subject is empty for a passwordless login
+type webauthnSession struct {
+	subject string
+	data    webauthn.SessionData
+}
+
+// newWebauthn creates the relying party config from the base url
+func newWebauthn(conf Conf) (*webauthn.WebAuthn, error) {
+	baseUrl, err := url.Parse(conf.BaseUrl)
+	if err != nil {
+		return nil, err
+	}
+	displayName := conf.ServiceName
+	if displayName == "" {
+		displayName = baseUrl.Host
+	}
+	return webauthn.New(&webauthn.Config{
+		RPID:          baseUrl.Hostname(),
+		RPDisplayName: displayName,
+		RPOrigins:     []string{baseUrl.Scheme + "://" + baseUrl.Host},
+	})
+}
+
+// webauthnCredentialId encodes the credential ID for storage
+func webauthnCredentialId(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+// loadWebauthnUser loads the user and their registered credentials
+func (h *HttpServer) loadWebauthnUser(ctx context.Context, subject string) (*webauthnUser, error) {
+	var user database.User
+	var rows []database.WebauthnCredential
+	err := h.DbTxError(func(tx *database.Queries) (err error) {
+		user, err = tx.GetUser(ctx, subject)
+		if err != nil {
+			return
+		}
+		rows, err = tx.GetWebauthnCredentials(ctx, subject)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u := &webauthnUser{
+		subject:       user.Subject,
+		name:          user.Name,
+		username:      user.Username,
+		emailVerified: user.EmailVerified,
+		active:        user.Active,
+		lockedUntil:   user.LockedUntil,
+		credentials:   make([]webauthn.Credential, 0, len(rows)),
+	}
+	for _, i := range rows {
+		var c webauthn.Credential
+		if err := json.Unmarshal([]byte(i.Credential), &c); err != nil {
+			return nil, err
+		}
+		u.credentials = append(u.credentials, c)
+	}
+	return u, nil
+}
+
+// startWebauthnSession saves the ceremony state and sets the session cookie
+func (h *HttpServer) startWebauthnSession(rw http.ResponseWriter, subject string, data *webauthn.SessionData) {
+	key := uuid.NewString()
+	h.webauthnSessions.Set(key, webauthnSession{subject: subject, data: *data}, time.Now().Add(5*time.Minute))
+	http.SetCookie(rw, &http.Cookie{
+		Name:     webauthnSessionCookie,
+		Value:    key,
+		Path:     "/",
+		Expires:  time.Now().Add(5 * time.Minute),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// finishWebauthnSession removes and returns the ceremony state, each session
+// can only be used once
+func (h *HttpServer) finishWebauthnSession(rw http.ResponseWriter, req *http.Request) (webauthnSession, bool) {
+	http.SetCookie(rw, &http.Cookie{
+		Name:     webauthnSessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	cookie, err := req.Cookie(webauthnSessionCookie)
+	if err != nil {
+		return webauthnSession{}, false
+	}
+	s, ok := h.webauthnSessions.Get(cookie.Value)
+	h.webauthnSessions.Delete(cookie.Value)
+	return s, ok
+}
+
+func writeJson(rw http.ResponseWriter, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(rw).Encode(v)
+}
+
+func (h *HttpServer) EditWebauthnBeginPost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	user, err := h.loadWebauthnUser(req.Context(), auth.Subject)
+	if err != nil {
+		http.Error(rw, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, len(user.credentials))
+	for i, c := range user.credentials {
+		exclusions[i] = c.Descriptor()
+	}
+	creation, session, err := h.webauthn.BeginRegistration(user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		http.Error(rw, "500 Internal Server Error: Failed to start passkey registration", http.StatusInternalServerError)
+		return
+	}
+
+	h.startWebauthnSession(rw, auth.Subject, session)
+	writeJson(rw, creation)
+}
+
+func (h *HttpServer) EditWebauthnFinishPost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	session, ok := h.finishWebauthnSession(rw, req)
+	if !ok || session.subject != auth.Subject {
+		http.Error(rw, "400 Bad Request: Invalid passkey registration session", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(req.URL.Query().Get("name"))
+	if name == "" {
+		name = "Passkey"
+	}
+
+	user, err := h.loadWebauthnUser(req.Context(), auth.Subject)
+	if err != nil {
+		http.Error(rw, "Database error", http.StatusInternalServerError)
+		return
+	}
+	cred, err := h.webauthn.FinishRegistration(user, session.data, req)
+	if err != nil {
+		logger.Logger.Debug("Failed to register passkey", "err", err)
+		http.Error(rw, "400 Bad Request: Failed to register passkey", http.StatusBadRequest)
+		return
+	}
+	credJson, err := json.Marshal(cred)
+	if err != nil {
+		http.Error(rw, "500 Internal Server Error: Failed to save passkey", http.StatusInternalServerError)
+		return
+	}
+
+	if h.DbTx(rw, func(tx *database.Queries) error {
+		return tx.AddWebauthnCredential(req.Context(), database.AddWebauthnCredentialParams{
+			ID:         webauthnCredentialId(cred.ID),
+			Subject:    auth.Subject,
+			Name:       name,
+			Credential: string(credJson),
+			CreatedAt:  time.Now(),
+		})
+	}) {
+		return
+	}
+	writeJson(rw, map[string]any{"redirect": "/edit"})
+}
+
+func (h *HttpServer) EditWebauthnDeletePost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	var required, hasOtp bool
+	var passkeys []database.WebauthnCredential
+	if h.DbTx(rw, func(tx *database.Queries) (err error) {
+		required, err = h.twoFactorRequired(req.Context(), tx, auth.Subject)
+		if err != nil {
+			return
+		}
+		hasOtp, err = tx.HasOtp(req.Context(), auth.Subject)
+		if err != nil {
+			return
+		}
+		passkeys, err = tx.GetWebauthnCredentials(req.Context(), auth.Subject)
+		return
+	}) {
+		return
+	}
+	if required && !hasOtp && len(passkeys) <= 1 {
+		http.Error(rw, "400 Bad Request: Two-factor authentication is required for this account, enable OTP or add another passkey first", http.StatusBadRequest)
+		return
+	}
+
+	if h.DbTx(rw, func(tx *database.Queries) error {
+		return tx.DeleteWebauthnCredential(req.Context(), database.DeleteWebauthnCredentialParams{
+			ID:      req.PostFormValue("id"),
+			Subject: auth.Subject,
+		})
+	}) {
+		return
+	}
+	http.Redirect(rw, req, "/edit", http.StatusFound)
+}
+
+func (h *HttpServer) LoginWebauthnBeginPost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	switch {
+	case auth.NeedOtp:
+		// second factor for a user who has entered their password
+		user, err := h.loadWebauthnUser(req.Context(), auth.Subject)
+		if err != nil {
+			http.Error(rw, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if len(user.credentials) == 0 {
+			http.Error(rw, "400 Bad Request: No passkeys registered", http.StatusBadRequest)
+			return
+		}
+		assertion, session, err := h.webauthn.BeginLogin(user)
+		if err != nil {
+			http.Error(rw, "500 Internal Server Error: Failed to start passkey login", http.StatusInternalServerError)
+			return
+		}
+		h.startWebauthnSession(rw, auth.Subject, session)
+		writeJson(rw, assertion)
+	case auth.IsGuest():
+		// passwordless login with a discoverable credential
+		assertion, session, err := h.webauthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+		if err != nil {
+			http.Error(rw, "500 Internal Server Error: Failed to start passkey login", http.StatusInternalServerError)
+			return
+		}
+		h.startWebauthnSession(rw, "", session)
+		writeJson(rw, assertion)
+	default:
+		http.Error(rw, "400 Bad Request: Already logged in", http.StatusBadRequest)
+	}
+}
+
+func (h *HttpServer) LoginWebauthnFinishPost(rw http.ResponseWriter, req *http.Request, _ httprouter.Params, auth UserAuth) {
+	session, ok := h.finishWebauthnSession(rw, req)
+	if !ok {
+		http.Error(rw, "400 Bad Request: Invalid passkey login session", http.StatusBadRequest)
+		return
+	}
+
+	var user *webauthnUser
+	var cred *webauthn.Credential
+	var persistent bool
+	var err error
+	if session.subject != "" {
+		if !auth.NeedOtp || auth.Subject != session.subject {
+			http.Error(rw, "400 Bad Request: Invalid passkey login session", http.StatusBadRequest)
+			return
+		}
+		user, err = h.loadWebauthnUser(req.Context(), auth.Subject)
+		if err != nil {
+			http.Error(rw, "Database error", http.StatusInternalServerError)
+			return
+		}
+		cred, err = h.webauthn.FinishLogin(user, session.data, req)
+		persistent = auth.Persistent
+	} else {
+		if !auth.IsGuest() {
+			http.Error(rw, "400 Bad Request: Already logged in", http.StatusBadRequest)
+			return
+		}
+		cred, err = h.webauthn.FinishDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+			user, err = h.loadWebauthnUser(req.Context(), string(userHandle))
+			return user, err
+		}, session.data, req)
+		persistent = h.sessions.persistent(req.URL.Query().Get("remember_me") != "")
+	}
+	if err != nil {
+		logger.Logger.Debug("Failed to verify passkey", "err", err)
+		http.Error(rw, "403 Forbidden: Failed to verify passkey", http.StatusForbidden)
+		return
+	}
+	if cred.Authenticator.CloneWarning {
+		http.Error(rw, "403 Forbidden: This passkey may have been cloned", http.StatusForbidden)
+		return
+	}
+	if !user.emailVerified {
+		http.Error(rw, "403 Forbidden: Email address is not verified", http.StatusForbidden)
+		return
+	}
+	if !user.active {
+		http.Error(rw, "403 Forbidden: Account is not active", http.StatusForbidden)
+		return
+	}
+	if h.loginBlocked(rw, req, user.lockedUntil) {
+		return
+	}
+
+	// save the new signature counter
+	credJson, err := json.Marshal(cred)
+	if err != nil {
+		http.Error(rw, "500 Internal Server Error: Failed to save passkey", http.StatusInternalServerError)
+		return
+	}
+	if h.DbTx(rw, func(tx *database.Queries) error {
+		return tx.UpdateWebauthnCredential(req.Context(), database.UpdateWebauthnCredentialParams{
+			Credential: string(credJson),
+			LastUsed:   sql.NullTime{Time: time.Now(), Valid: true},
+			ID:         webauthnCredentialId(cred.ID),
+			Subject:    user.subject,
+		})
+	}) {
+		return
+	}
+
+	h.guard.resetFailures(req.Context(), user.subject)
+	if h.setLoginDataCookie(rw, UserAuth{Subject: user.subject, Persistent: persistent}) {
+		return
+	}
+	writeJson(rw, map[string]any{"redirect": safeRedirectPath(req.URL.Query().Get("redirect"))})
+}